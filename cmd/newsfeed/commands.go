@@ -0,0 +1,569 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+func runImport(ctx context.Context, args []string) error {
+	fs := newFlagSet("import")
+	format := fs.String("format", "", "format of the file, csv or json (default from the file extension)")
+	dryRun := fs.Bool("dry-run", false, "only report which rows would be imported")
+	by := fs.String("by", "", "name of the curator to record as having added the articles, for rows that do not name one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = db.FormatCSV
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			*format = db.FormatJSON
+		}
+	}
+
+	r, closeFn, err := openInput(path)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	f, err := loadFeed(ctx)
+	if err != nil {
+		return err
+	}
+
+	report, err := db.ImportArticles(r, *format, f.articles, f.questions)
+	if err != nil {
+		return err
+	}
+	if cfg.ControlledVocabulary {
+		tt, _ := loadTopicTree(ctx)
+		report.CheckVocabulary(db.NewVocabulary(f.topics, tt))
+	}
+	// as in the web app, rows to be published are submitted for review if review is required, and scheduled if their publish time is still to come.
+	report.Submit(strings.TrimSpace(*by), cfg.RequireReview, time.Now())
+	for _, res := range report.Results {
+		if res.Err != nil {
+			fmt.Printf("row %d (%q): %v\n", res.Row, res.Record.Title, res.Err)
+		}
+	}
+	fmt.Printf("%d valid, %d invalid\n", report.Valid(), report.Invalid())
+
+	if *dryRun || report.Valid() == 0 {
+		return nil
+	}
+
+	for _, res := range report.Results {
+		if res.Err != nil {
+			continue
+		}
+		if err := db.AppendArticle(ctx, res.Article); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("imported %d article(s)\n", report.Valid())
+	return nil
+}
+
+func runExport(ctx context.Context, args []string) error {
+	fs := newFlagSet("export")
+	format := fs.String("format", db.FormatCSV, "csv, json or md")
+	out := fs.String("o", "-", "file to write to, or - for stdout")
+	term := fs.String("term", "", "only export articles matching the search term")
+	topic := fs.String("topic", "", "only export articles tagged with the topic or its subtopics")
+	question := fs.String("question", "", "only export articles tagged with the question, e.g. 2019-Q6")
+	heading := fs.String("heading", "Articles", "heading of a Markdown export")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := loadFeed(ctx)
+	if err != nil {
+		return err
+	}
+	var tt db.TopicTree
+	if *topic != "" {
+		tt, _ = loadTopicTree(ctx)
+	}
+	results, err := db.FilterArticles(f.articles, tt, *term, *topic, *question)
+	if err != nil {
+		return err
+	}
+
+	w, closeFn, err := openOutput(*out)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case db.FormatCSV:
+		err = db.ExportCSV(w, results)
+	case db.FormatJSON:
+		err = db.ExportJSON(w, results)
+	case "md":
+		err = db.ExportMarkdown(w, results, *heading)
+	default:
+		err = fmt.Errorf("unsupported export format %q", *format)
+	}
+	if err != nil {
+		closeFn()
+		return err
+	}
+	return closeFn()
+}
+
+func runCoverage(ctx context.Context, args []string) error {
+	fs := newFlagSet("coverage")
+	out := fs.String("o", "-", "file to write to, or - for stdout")
+	kind := fs.String("kind", "", "only list questions (question) or topics (topic)")
+	days := fs.Int("days", cfg.StaleDays, "number of days without a new article after which a question or topic is stale")
+	staleOnly := fs.Bool("stale", false, "only list stale questions and topics")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *days < 1 {
+		return errors.New("-days must be at least 1")
+	}
+
+	f, err := loadFeed(ctx)
+	if err != nil {
+		return err
+	}
+	tt, _ := loadTopicTree(ctx)
+
+	report := db.NewCoverageReport(f.articles, f.questions, tt, time.Now(), *days).Filter(*kind, *staleOnly)
+
+	w, closeFn, err := openOutput(*out)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteCSV(w); err != nil {
+		closeFn()
+		return err
+	}
+	return closeFn()
+}
+
+func runBackup(ctx context.Context, args []string) error {
+	fs := newFlagSet("backup")
+	out := fs.String("o", "-", "file to write to, or - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := loadFeed(ctx)
+	if err != nil {
+		return err
+	}
+	if !f.report.OK() {
+		fmt.Fprintf(os.Stderr, "warning: %d invalid row(s) of the Articles sheet are not in the backup\n", len(f.report.Errors))
+	}
+
+	w, closeFn, err := openOutput(*out)
+	if err != nil {
+		return err
+	}
+	if err := db.NewSnapshot(f.articles, f.questions, snapshotTopicTree(ctx)).Write(w); err != nil {
+		closeFn()
+		return err
+	}
+	return closeFn()
+}
+
+func runSnapshot(ctx context.Context, args []string) error {
+	fs := newFlagSet("snapshot")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	st, err := openSnapshotStore()
+	if err != nil {
+		return err
+	}
+	f, err := loadFeed(ctx)
+	if err != nil {
+		return err
+	}
+
+	info, err := st.Save(db.NewSnapshot(f.articles, f.questions, snapshotTopicTree(ctx)), db.SnapshotScheduled)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("saved snapshot %s\n", info.ID)
+	return nil
+}
+
+func runSnapshots(ctx context.Context, args []string) error {
+	fs := newFlagSet("snapshots")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	st, err := openSnapshotStore()
+	if err != nil {
+		return err
+	}
+	infos, err := st.List()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, info := range infos {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", info.ID, info.Created.Local().Format("Jan 2, 2006 3:04 PM"), info.Description(), info.Size)
+	}
+	return tw.Flush()
+}
+
+func runRestore(ctx context.Context, args []string) error {
+	fs := newFlagSet("restore")
+	yes := fs.Bool("yes", false, "replace the sheets; without it, only show what would change")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	st, err := openSnapshotStore()
+	if err != nil {
+		return err
+	}
+
+	// the argument is a file saved by backup, or else the ID of a snapshot in the snapshot directory.
+	var sn *db.Snapshot
+	if _, err := os.Stat(fs.Arg(0)); err == nil || fs.Arg(0) == "-" {
+		r, closeFn, err := openInput(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer closeFn()
+		if sn, err = db.ReadSnapshot(r); err != nil {
+			return err
+		}
+	} else if sn, err = st.Open(fs.Arg(0)); err != nil {
+		return err
+	}
+
+	articles, questions, _, _, err := sn.Load()
+	if err != nil {
+		return err
+	}
+
+	f, err := loadFeed(ctx)
+	if err != nil {
+		return err
+	}
+	tt := snapshotTopicTree(ctx)
+	d := sn.Diff(f.articles, f.questions, tt)
+
+	fmt.Printf("snapshot from %s: %d article(s), %d question(s)\n", sn.Created.Local().Format("Jan 2, 2006 3:04 PM"), articles.Len(), len(questions))
+	if d.Empty() {
+		fmt.Println("the snapshot is the same as the sheets; nothing to restore")
+		return nil
+	}
+	for _, rec := range d.Added {
+		fmt.Printf("+ %s (%s)\n", rec.Title, rec.Date)
+	}
+	for _, rec := range d.Removed {
+		fmt.Printf("- %s (%s)\n", rec.Title, rec.Date)
+	}
+	for _, c := range d.Changed {
+		fmt.Printf("~ %s (%s)\n", c.Snapshot.Title, c.Snapshot.Date)
+	}
+	for _, qn := range d.QuestionsAdded {
+		fmt.Printf("+ question %s\n", qn.Label())
+	}
+	for _, qn := range d.QuestionsRemoved {
+		fmt.Printf("- question %s\n", qn.Label())
+	}
+	for _, c := range d.QuestionsChanged {
+		fmt.Printf("~ question %s\n", c.Snapshot.Label())
+	}
+	if d.TopicTreeChanged {
+		fmt.Println("~ topic hierarchy")
+	}
+
+	if !*yes {
+		fmt.Println("run again with -yes to replace the Articles, Questions and Topics sheets")
+		return nil
+	}
+
+	if _, err := st.Save(db.NewSnapshot(f.articles, f.questions, tt), db.SnapshotBeforeRestore); err != nil {
+		return err
+	}
+	if err := db.BackupQuestions(ctx, questions); err != nil {
+		return err
+	}
+	if err := db.BackupArticles(ctx, articles); err != nil {
+		return err
+	}
+	if sn.TopicTree != nil {
+		if err := db.WriteNow(ctx, db.BackupTopicsWrite(sn.TopicTree)); err != nil {
+			return err
+		}
+	}
+	fmt.Println("restored")
+	return nil
+}
+
+func runValidate(ctx context.Context, args []string) error {
+	fs := newFlagSet("validate")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := loadFeed(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(f.report)
+	if !f.report.OK() {
+		return fmt.Errorf("%d invalid row(s)", len(f.report.Errors))
+	}
+	return nil
+}
+
+func runReindex(ctx context.Context, args []string) error {
+	fs := newFlagSet("reindex")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := loadFeed(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := db.BackupQuestions(ctx, f.questions); err != nil {
+		return err
+	}
+	if err := f.backupArticles(ctx); err != nil {
+		return err
+	}
+	fmt.Printf("reindexed %d article(s) and %d question(s)\n", f.articles.Len(), len(f.questions))
+	if !f.report.OK() {
+		fmt.Printf("%d invalid row(s) were left at the end of the Articles sheet; run validate for details\n", len(f.report.Errors))
+	}
+	return nil
+}
+
+func runAddQuestion(ctx context.Context, args []string) error {
+	fs := newFlagSet("add-question")
+	paper := fs.String("paper", "", "paper number, e.g. 2 for a Paper 2 question (default paper 1)")
+	source := fs.String("source", "", "school and exam that set the question, e.g. \"NJC Prelim\" (default the A-level exam)")
+	level := fs.String("level", "", "exam level, e.g. H1")
+	theme := fs.String("theme", "", "syllabus theme")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 3 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	qn, err := db.NormalizeQuestion(db.Question{Year: fs.Arg(0), Number: fs.Arg(1), Paper: *paper, Source: *source})
+	if err != nil {
+		return err
+	}
+	wording := strings.TrimSpace(fs.Arg(2))
+	if wording == "" {
+		return errors.New("the question wording is empty")
+	}
+	qn.Wording, qn.Level, qn.Theme = wording, strings.TrimSpace(*level), strings.TrimSpace(*theme)
+	key := qn.Key()
+
+	qnDB, err := db.InitQuestionsDB(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing, ok := qnDB[key]
+	switch {
+	case !ok:
+		if err := db.AppendQuestion(ctx, qn); err != nil {
+			return err
+		}
+		fmt.Printf("added %s\n", qn.Label())
+	case existing == qn:
+		fmt.Printf("%s already exists\n", qn.Label())
+	default:
+		qnDB[key] = qn
+		if err := db.BackupQuestions(ctx, qnDB); err != nil {
+			return err
+		}
+		fmt.Printf("updated %s\n", qn.Label())
+	}
+	return nil
+}
+
+func runListTopics(ctx context.Context, args []string) error {
+	fs := newFlagSet("list-topics")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := loadFeed(ctx)
+	if err != nil {
+		return err
+	}
+
+	tc := db.GetTopicsCount(f.topics)
+	sort.SliceStable(tc, func(i, j int) bool {
+		if tc[i].Value == tc[j].Value {
+			return tc[i].Key < tc[j].Key
+		}
+		return tc[i].Value > tc[j].Value
+	})
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, t := range tc {
+		fmt.Fprintf(tw, "%s\t%d\n", t.Key, t.Value)
+	}
+	return tw.Flush()
+}
+
+func runRenameTopic(ctx context.Context, args []string) error {
+	fs := newFlagSet("rename-topic")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	from := db.Topic(strings.TrimSpace(fs.Arg(0)))
+	to := db.Topic(strings.Title(strings.TrimSpace(fs.Arg(1))))
+	if from == "" || to == "" {
+		return errors.New("topic names cannot be empty")
+	}
+
+	f, err := loadFeed(ctx)
+	if err != nil {
+		return err
+	}
+
+	tt, loaded := loadTopicTree(ctx)
+
+	n := db.RenameTopic(f.articles, f.topics, from, to)
+	if n == 0 {
+		return fmt.Errorf("no articles are tagged with %q", from)
+	}
+	if err := f.backupArticles(ctx); err != nil {
+		return err
+	}
+	// a hierarchy that could not be read is not written, so that the Topics sheet is not replaced with an empty one.
+	if loaded {
+		tt.Rename(from, to)
+		if err := db.WriteNow(ctx, db.BackupTopicsWrite(tt)); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("renamed %q to %q in %d article(s)\n", from, to, n)
+	return nil
+}
+
+func runCheckLinks(ctx context.Context, args []string) error {
+	fs := newFlagSet("check-links")
+	workers := fs.Int("workers", 8, "number of links to check at once")
+	timeout := fs.Duration("timeout", 15*time.Second, "time to wait for each link")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := loadFeed(ctx)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	var broken int
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, r := range db.CheckLinks(ctx, f.articles, client, *workers) {
+		if r.OK() {
+			continue
+		}
+		broken++
+		status := fmt.Sprint(r.Status)
+		if r.Err != nil {
+			status = r.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Article.DisplayDate, r.Article.URL, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if broken > 0 {
+		return fmt.Errorf("%d of %d link(s) are broken", broken, f.articles.Len())
+	}
+	fmt.Printf("all %d link(s) are working\n", f.articles.Len())
+	return nil
+}
+
+// loadTopicTree returns the topic hierarchy from the Topics sheet, and reports whether it could be read. As in the server, the sheet is optional: if it cannot be read, a warning is printed and an empty hierarchy is returned.
+func loadTopicTree(ctx context.Context) (db.TopicTree, bool) {
+	tt, err := db.InitTopicTree(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: unable to load the topic hierarchy from the Topics sheet, so it is left out - %v\n", err)
+		return tt, false
+	}
+	return tt, true
+}
+
+// snapshotTopicTree returns the topic hierarchy to save in a snapshot, or nil if it could not be read, so that restoring the snapshot leaves the Topics sheet alone.
+func snapshotTopicTree(ctx context.Context) db.TopicTree {
+	if tt, loaded := loadTopicTree(ctx); loaded {
+		return tt
+	}
+	return nil
+}
+
+// openSnapshotStore opens the snapshot directory set in the config.
+func openSnapshotStore() (*db.SnapshotStore, error) {
+	return db.OpenSnapshotStore(cfg.Snapshots.Dir, cfg.RetentionPolicy())
+}
+
+// openInput opens the file at path for reading, or stdin if path is "-".
+func openInput(path string) (io.Reader, func() error, error) {
+	if path == "-" {
+		return os.Stdin, func() error { return nil }, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// openOutput creates the file at path for writing, or returns stdout if path is "-".
+func openOutput(path string) (io.Writer, func() error, error) {
+	if path == "-" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
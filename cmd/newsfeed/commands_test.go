@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+const (
+	testSheetID    = "test-sheet"
+	testOldSheetID = "test-old-sheet"
+)
+
+// testArticles are the rows of the Articles sheet the commands are run against.
+var testArticles = [][]interface{}{
+	{"Older article", "https://example.com/older", "Science\nTechnology", "2019 6", "2019 6 How far is science a force for good?", "Jan 2, 2021"},
+	{"Newer article", "https://example.com/newer", "Media\nTechnology", "", "", "Feb 3, 2021"},
+}
+
+var testQuestions = [][]interface{}{
+	{"2019 6", "2019", "6", "How far is science a force for good?"},
+	{"2020 1", "2020", "1", "Is censorship ever justified?"},
+}
+
+// brokenTopics is a Sheets client whose Topics sheet cannot be read, as when it has not been created.
+type brokenTopics struct {
+	*db.FakeSheets
+}
+
+func (b brokenTopics) Get(ctx context.Context, spreadsheetID, readRange string) ([][]interface{}, error) {
+	if strings.HasPrefix(readRange, "Topics") {
+		return nil, errors.New("unable to parse range: Topics")
+	}
+	return b.FakeSheets.Get(ctx, spreadsheetID, readRange)
+}
+
+// useTestSheets configures the commands to use a FakeSheets seeded with testArticles, testQuestions and the given Topics sheet for the duration of the test.
+func useTestSheets(t *testing.T, topics [][]interface{}) *db.FakeSheets {
+	t.Helper()
+
+	old := cfg
+	cfg = db.DefaultConfig()
+	cfg.SheetID = testSheetID
+	cfg.OldSheetID = testOldSheetID
+	db.Configure(cfg)
+
+	fake := db.NewFakeSheets()
+	fake.SetValues(testSheetID, "Articles", testArticles)
+	fake.SetValues(testSheetID, "Questions", testQuestions)
+	fake.SetValues(testSheetID, "Topics", topics)
+	fake.SetValues(testOldSheetID, "feed", nil)
+	db.UseSheetsClient(fake)
+
+	t.Cleanup(func() {
+		cfg = old
+		db.Configure(old)
+		db.UseSheetsClient(nil)
+	})
+	return fake
+}
+
+// run runs the command with the arguments, and returns what it printed to stdout.
+func run(t *testing.T, name string, args ...string) (string, error) {
+	t.Helper()
+
+	out, err := ioutil.TempFile(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = out, out
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+
+	var runErr error
+	for _, c := range commands {
+		if c.name == name {
+			runErr = c.run(context.Background(), args)
+		}
+	}
+	b, err := ioutil.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), runErr
+}
+
+// articleTopics returns the Topics cell of each row of the Articles sheet.
+func articleTopics(fake *db.FakeSheets) []string {
+	var cells []string
+	for _, row := range fake.Values(testSheetID, "Articles") {
+		cells = append(cells, row[2].(string))
+	}
+	return cells
+}
+
+func TestListTopics(t *testing.T) {
+	useTestSheets(t, nil)
+
+	got, err := run(t, "list-topics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// topics are listed by number of articles, then by name.
+	lines := strings.Fields(got)
+	want := []string{"Technology", "2", "Media", "1", "Science", "1"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRenameTopic(t *testing.T) {
+	fake := useTestSheets(t, [][]interface{}{{"Technology", "Science"}, {"Science", ""}})
+
+	got, err := run(t, "rename-topic", "Technology", "tech")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, `renamed "Technology" to "Tech" in 2 article(s)`) {
+		t.Errorf("got %q, want the renamed articles counted", got)
+	}
+	if cells := articleTopics(fake); !reflect.DeepEqual(cells, []string{"Media\nTech", "Science\nTech"}) {
+		t.Errorf("got Topics cells %q, want Technology renamed", cells)
+	}
+	if rows := fake.Values(testSheetID, "Topics"); len(rows) != 2 || rows[0][0] != "Science" || rows[1][0] != "Tech" || rows[1][1] != "Science" {
+		t.Errorf("got Topics sheet %q, want Technology renamed in the hierarchy", rows)
+	}
+
+	// renaming into an existing topic merges the two.
+	if _, err := run(t, "rename-topic", "Media", "Tech"); err != nil {
+		t.Fatal(err)
+	}
+	if cells := articleTopics(fake); !reflect.DeepEqual(cells, []string{"Tech", "Science\nTech"}) {
+		t.Errorf("got Topics cells %q, want Media merged into Tech", cells)
+	}
+
+	if _, err := run(t, "rename-topic", "Sport", "Games"); err == nil {
+		t.Error("expected an error renaming a topic no article is tagged with")
+	}
+	if _, err := run(t, "rename-topic", "Tech"); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("got error %v with one argument, want the usage", err)
+	}
+}
+
+func TestRenameTopicWithoutHierarchy(t *testing.T) {
+	fake := useTestSheets(t, nil)
+	db.UseSheetsClient(brokenTopics{fake})
+
+	got, err := run(t, "rename-topic", "Technology", "Tech")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "warning: unable to load the topic hierarchy") {
+		t.Errorf("got %q, want a warning that the hierarchy was left out", got)
+	}
+	if cells := articleTopics(fake); !reflect.DeepEqual(cells, []string{"Media\nTech", "Science\nTech"}) {
+		t.Errorf("got Topics cells %q, want the articles renamed anyway", cells)
+	}
+	if rows := fake.Values(testSheetID, "Topics"); len(rows) != 0 {
+		t.Errorf("got Topics sheet %q, want it left alone", rows)
+	}
+}
+
+func TestImport(t *testing.T) {
+	fake := useTestSheets(t, nil)
+	path := filepath.Join(t.TempDir(), "articles.csv")
+	csv := `Title,URL,Topics,Questions,Wording,Date
+Censorship online,https://example.com/censor,Media,2020 1,,"Mar 4, 2021"
+Newer article,https://example.com/newer,Media,,,"Feb 3, 2021"
+`
+	if err := ioutil.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := run(t, "import", "-dry-run", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "row 3 (\"Newer article\")") || !strings.Contains(got, "1 valid, 1 invalid") {
+		t.Errorf("got %q, want the duplicate reported", got)
+	}
+	if n := len(fake.Values(testSheetID, "Articles")); n != len(testArticles) {
+		t.Fatalf("got %d rows in the Articles sheet after a dry run, want %d", n, len(testArticles))
+	}
+
+	if _, err := run(t, "import", path); err != nil {
+		t.Fatal(err)
+	}
+	rows := fake.Values(testSheetID, "Articles")
+	if len(rows) != len(testArticles)+1 || rows[len(rows)-1][1] != "https://example.com/censor" {
+		t.Errorf("got Articles sheet %q, want the valid row appended", rows)
+	}
+
+	if _, err := run(t, "import", filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Error("expected an error for a file that does not exist")
+	}
+}
+
+func TestImportNeedsReview(t *testing.T) {
+	fake := useTestSheets(t, nil)
+	cfg.RequireReview = true
+	path := filepath.Join(t.TempDir(), "articles.csv")
+	csv := `Censorship online,https://example.com/censor,Media,2020 1,,"Mar 4, 2021"
+`
+	if err := ioutil.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := run(t, "import", "-by", "Mary", path); err != nil {
+		t.Fatal(err)
+	}
+	rows := fake.Values(testSheetID, "Articles")
+	if row := rows[len(rows)-1]; len(row) < 11 || row[9] != string(db.Pending) || row[10] != "Mary" {
+		t.Errorf("got row %q, want the article pending review and added by Mary", row)
+	}
+	if rows := fake.Values(testOldSheetID, "feed"); len(rows) != 0 {
+		t.Errorf("got %q in the old feed sheet, want the article left out until it is published", rows)
+	}
+}
+
+func TestExport(t *testing.T) {
+	useTestSheets(t, [][]interface{}{{"Media", "Society"}})
+	path := filepath.Join(t.TempDir(), "export.json")
+
+	// a topic includes the articles tagged with its subtopics.
+	for _, topic := range []string{"media", "Society"} {
+		if _, err := run(t, "export", "-format", "json", "-topic", topic, "-o", path); err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), "https://example.com/newer") || strings.Contains(string(b), "https://example.com/older") {
+			t.Errorf("topic %q: got export %s, want only the article tagged with Media", topic, b)
+		}
+	}
+
+	if _, err := run(t, "export", "-format", "xlsx", "-o", path); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+	if _, err := run(t, "export", "-question", "not a question"); err == nil {
+		t.Error("expected an error for a question that is not a key")
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	useTestSheets(t, [][]interface{}{{"Technology", "Science"}})
+
+	got, err := run(t, "coverage", "-kind", "question")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "2019") || !strings.Contains(got, "2020") || strings.Contains(got, "Media") {
+		t.Errorf("got %q, want a row for each question and none for topics", got)
+	}
+
+	if _, err := run(t, "coverage", "-days", "0"); err == nil {
+		t.Error("expected an error for -days 0")
+	}
+}
@@ -0,0 +1,171 @@
+// Command newsfeed administers the article feed from the command line. It uses the same config and Google Sheets as the web app, so that routine maintenance can be scripted, e.g. from cron.
+//
+// Usage:
+//
+//	newsfeed [-config file] <command> [flags] [args]
+//
+// Run "newsfeed help" for the list of commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// command is a subcommand of the tool. run is given the arguments after the command name.
+type command struct {
+	name  string
+	usage string
+	help  string
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands []command
+
+// cfg is the config loaded at startup.
+var cfg db.Config
+
+func init() {
+	commands = []command{
+		{"import", "import [-format csv|json] [-dry-run] [-by curator] file", "Validate the articles in a CSV or JSON file and append the valid ones to the Articles sheet, submitting them for review if review is required.", runImport},
+		{"export", "export [-format csv|json|md] [-o file] [-term term] [-topic topic] [-question key]", "Export articles, optionally filtered, to a file or stdout.", runExport},
+		{"coverage", "coverage [-o file] [-kind question|topic] [-days n] [-stale]", "Write the coverage report of every question and topic, with its number of articles and latest article, as CSV to a file or stdout.", runCoverage},
+		{"backup", "backup [-o file]", "Save a snapshot of the articles, questions and topic hierarchy to a JSON file or stdout.", runBackup},
+		{"snapshot", "snapshot", "Save a snapshot to the snapshot directory, and remove old snapshots according to the retention policy.", runSnapshot},
+		{"snapshots", "snapshots", "List the snapshots in the snapshot directory.", runSnapshots},
+		{"restore", "restore [-yes] file|id", "Show what restoring a snapshot saved by backup or snapshot would change, and with -yes, replace the Articles, Questions and Topics sheets with it.", runRestore},
+		{"validate", "validate", "Check every row of the Articles sheet, and exit with status 1 if any are invalid.", runValidate},
+		{"reindex", "reindex", "Rewrite the Articles and Questions sheets in a consistent order and format.", runReindex},
+		{"add-question", "add-question [-paper n] [-source name] [-level level] [-theme theme] year number wording", "Add a past year question, or update its wording and details.", runAddQuestion},
+		{"list-topics", "list-topics", "List every topic with its number of articles.", runListTopics},
+		{"rename-topic", "rename-topic old new", "Rename a topic in every article and in the topic hierarchy, merging it into new if that topic already exists.", runRenameTopic},
+		{"check-links", "check-links [-workers n] [-timeout d]", "Check the link to every article, and exit with status 1 if any are broken.", runCheckLinks},
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("newsfeed: ")
+
+	flag.Usage = usage
+	configPath := flag.String("config", db.DefaultConfigPath, "path to the JSON config file; settings in the environment take precedence")
+	flag.Parse()
+
+	if flag.NArg() == 0 || flag.Arg(0) == "help" {
+		usage()
+		return
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == flag.Arg(0) {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		log.Printf("unknown command %q", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	cfg, err = db.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db.Configure(cfg)
+
+	// for offline development, read and write a local JSON file instead of Google Sheets.
+	if cfg.OfflineSheets != "" {
+		fake, err := db.LoadFakeSheets(cfg.OfflineSheets)
+		if err != nil {
+			log.Fatal(err)
+		}
+		db.UseSheetsClient(fake)
+	}
+
+	if err := cmd.run(context.Background(), flag.Args()[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		log.Fatalf("%s: %v", cmd.name, err)
+	}
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: newsfeed [-config file] <command> [flags] [args]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %s\n        %s\n", c.usage, c.help)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// newFlagSet returns a FlagSet for the command whose usage message shows the command's usage line.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		for _, c := range commands {
+			if c.name == name {
+				fmt.Fprintf(fs.Output(), "Usage: newsfeed %s\n\n%s\n", c.usage, c.help)
+			}
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// feed holds the databases loaded from Google Sheets.
+type feed struct {
+	articles  *db.ArticlesDBByDate
+	questions db.QuestionsDB
+	topics    db.TopicsMap
+	counter   db.QuestionCounter
+	report    *db.ValidationReport
+	sync      *db.SheetSync
+}
+
+// loadFeed loads the databases from Google Sheets, as the web app does when it starts.
+func loadFeed(ctx context.Context) (*feed, error) {
+	qnDB, err := db.InitQuestionsDB(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load questions: %w", err)
+	}
+
+	// the Sources sheet is optional, so articles are named with the default publications if it cannot be read.
+	sources, err := db.InitSources(ctx)
+	if err != nil {
+		log.Printf("Unable to load the news sources from the Sources sheet - %v", err)
+	}
+	db.SetSources(sources)
+
+	f := &feed{
+		articles:  db.NewArticlesDBByDate(),
+		questions: qnDB,
+		topics:    db.InitTopicsMap(),
+		counter:   db.InitQuestionCounter(),
+	}
+	f.report, err = f.articles.InitArticlesDB(ctx, qnDB, f.topics, f.counter)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load articles: %w", err)
+	}
+
+	// syncing once records the rows that could not be loaded, so that backupArticles keeps them in the sheet.
+	f.sync = db.NewSheetSync(f.articles, f.report)
+	if _, err := f.sync.Sync(ctx, f.articles, f.questions, f.topics, f.counter); err != nil {
+		return nil, fmt.Errorf("unable to read the Articles sheet: %w", err)
+	}
+	return f, nil
+}
+
+// backupArticles rewrites the Articles sheet from the articles database. Rows of the sheet that could not be loaded are kept, so that they can still be corrected.
+func (f *feed) backupArticles(ctx context.Context) error {
+	return db.WriteNow(ctx, f.sync.BackupArticlesWrite(f.articles))
+}
@@ -0,0 +1,234 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Period is the length of each bucket of a TimeSeries.
+type Period string
+
+// Periods a TimeSeries can be counted in. Weeks start on Monday.
+const (
+	Day   Period = "day"
+	Week  Period = "week"
+	Month Period = "month"
+)
+
+// Start returns the start of the period containing t, in t's location.
+func (p Period) Start(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch p {
+	case Week:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case Month:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return day
+	}
+}
+
+// Next returns the start of the period after the one starting at start.
+func (p Period) Next(start time.Time) time.Time {
+	switch p {
+	case Week:
+		return start.AddDate(0, 0, 7)
+	case Month:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// Bucket is the number of articles published in the period starting at Start.
+type Bucket struct {
+	Start time.Time
+	Count int
+}
+
+// TimeSeries is the number of articles published in each of a run of consecutive periods, oldest first.
+type TimeSeries struct {
+	Name    string
+	Period  Period
+	Buckets []Bucket
+}
+
+// newTimeSeries returns a TimeSeries of n empty buckets, the last of which contains now.
+func newTimeSeries(name string, period Period, n int, now time.Time) TimeSeries {
+	ts := TimeSeries{Name: name, Period: period, Buckets: make([]Bucket, n)}
+	start := period.Start(now)
+	for i := n - 1; i >= 0; i-- {
+		ts.Buckets[i].Start = start
+		start = period.Start(start.Add(-time.Hour))
+	}
+	return ts
+}
+
+// add counts an article published at t, if t falls in one of the buckets.
+func (ts TimeSeries) add(t time.Time) {
+	if len(ts.Buckets) == 0 || t.Before(ts.Buckets[0].Start) {
+		return
+	}
+	i := sort.Search(len(ts.Buckets), func(i int) bool { return ts.Buckets[i].Start.After(t) }) - 1
+	if i == len(ts.Buckets)-1 && !t.Before(ts.Period.Next(ts.Buckets[i].Start)) {
+		return
+	}
+	ts.Buckets[i].Count++
+}
+
+// Total returns the number of articles in the TimeSeries.
+func (ts TimeSeries) Total() int {
+	var n int
+	for _, b := range ts.Buckets {
+		n += b.Count
+	}
+	return n
+}
+
+// Average returns the average number of articles per period.
+func (ts TimeSeries) Average() float64 {
+	if len(ts.Buckets) == 0 {
+		return 0
+	}
+	return float64(ts.Total()) / float64(len(ts.Buckets))
+}
+
+// articleTime returns the time the article was published, in loc.
+func articleTime(a Article, loc *time.Location) time.Time {
+	return time.Unix(a.Date, 0).In(loc)
+}
+
+// ArticlesOverTime returns the number of articles in the database published in each of the last n periods up to and including the one containing now.
+func ArticlesOverTime(database *ArticlesDBByDate, period Period, n int, now time.Time) TimeSeries {
+	ts := newTimeSeries("Articles", period, n, now)
+	for _, a := range *database {
+		ts.add(articleTime(a, now.Location()))
+	}
+	return ts
+}
+
+// TopicsOverTime returns a TimeSeries for each of the limit topics tagged on the most articles published in the last n periods, most tagged first, counting the articles tagged with the topic in each period.
+func TopicsOverTime(database *ArticlesDBByDate, period Period, n int, now time.Time, limit int) []TimeSeries {
+	series := make(map[Topic]TimeSeries)
+	for _, a := range *database {
+		t := articleTime(a, now.Location())
+		for _, topic := range a.Topics {
+			ts, ok := series[topic]
+			if !ok {
+				ts = newTimeSeries(string(topic), period, n, now)
+				series[topic] = ts
+			}
+			ts.add(t)
+		}
+	}
+
+	ranked := make([]TimeSeries, 0, len(series))
+	for _, ts := range series {
+		ranked = append(ranked, ts)
+	}
+	return rankTimeSeries(ranked, limit)
+}
+
+// NoCurator is the name CuratorsOverTime gives to the articles that do not record the curator who added them, such as those added before curators had their own logins.
+const NoCurator = "Not recorded"
+
+// CuratorsOverTime returns a TimeSeries for each curator who added articles published in the last n periods, most articles first, counting the articles they added in each period. Articles that do not record who added them are counted under NoCurator.
+func CuratorsOverTime(database *ArticlesDBByDate, period Period, n int, now time.Time) []TimeSeries {
+	series := make(map[string]TimeSeries)
+	for _, a := range *database {
+		name := strings.TrimSpace(a.AddedBy)
+		if name == "" {
+			name = NoCurator
+		}
+		ts, ok := series[name]
+		if !ok {
+			ts = newTimeSeries(name, period, n, now)
+			series[name] = ts
+		}
+		ts.add(articleTime(a, now.Location()))
+	}
+
+	ranked := make([]TimeSeries, 0, len(series))
+	for _, ts := range series {
+		ranked = append(ranked, ts)
+	}
+	return rankTimeSeries(ranked, len(ranked))
+}
+
+// rankTimeSeries returns up to limit of the series that count any articles, most articles first.
+func rankTimeSeries(series []TimeSeries, limit int) []TimeSeries {
+	ranked := series[:0]
+	for _, ts := range series {
+		if ts.Total() > 0 {
+			ranked = append(ranked, ts)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Total() != ranked[j].Total() {
+			return ranked[i].Total() > ranked[j].Total()
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// CountTags returns the number of articles in the database tagged with each topic and past year question, counted as InitArticlesDB counts them, such as for ranking the tags of only the published articles. Questions in qnDB that no article is tagged with are counted as 0.
+func CountTags(database *ArticlesDBByDate, qnDB QuestionsDB) (TopicsMap, QuestionCounter) {
+	tm, qc := InitTopicsMap(), InitQuestionCounter()
+	for _, a := range *database {
+		for _, t := range a.Topics {
+			tm.Increment(t)
+		}
+		for _, qn := range a.Questions {
+			qc.Increment(qn.Label())
+		}
+	}
+	qc.GetZeroArticleQns(qnDB)
+	return tm, qc
+}
+
+// SourceCount is the number of articles from a source, the publication named by the Sources registry.
+type SourceCount struct {
+	Source string
+	Count  int
+}
+
+// ArticlesBySource returns the number of articles in the database published since the given time from each source, most articles first.
+func ArticlesBySource(database *ArticlesDBByDate, since time.Time) []SourceCount {
+	counts := make(map[string]int)
+	for _, a := range *database {
+		if time.Unix(a.Date, 0).Before(since) {
+			continue
+		}
+		counts[articleSource(a)]++
+	}
+	return rankSources(counts)
+}
+
+// articleSource returns the source of the article, naming it from its URL if it was not parsed with ParseArticle.
+func articleSource(a Article) string {
+	if a.Source != "" {
+		return a.Source
+	}
+	return SourceName(a.URL)
+}
+
+// rankSources returns the counts of each source, most articles first.
+func rankSources(counts map[string]int) []SourceCount {
+	sources := make([]SourceCount, 0, len(counts))
+	for s, n := range counts {
+		sources = append(sources, SourceCount{s, n})
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].Count != sources[j].Count {
+			return sources[i].Count > sources[j].Count
+		}
+		return strings.ToLower(sources[i].Source) < strings.ToLower(sources[j].Source)
+	})
+	return sources
+}
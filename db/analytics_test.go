@@ -0,0 +1,80 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestArticlesOverTime(t *testing.T) {
+	now := time.Date(2022, 3, 16, 15, 0, 0, 0, time.UTC) // a Wednesday
+	at := func(y int, m time.Month, d int) int64 { return time.Date(y, m, d, 9, 0, 0, 0, time.UTC).Unix() }
+	database := &ArticlesDBByDate{
+		{URL: "https://www.straitstimes.com/a", Topics: []Topic{"Media"}, Date: at(2022, 3, 16)},
+		{URL: "https://www.straitstimes.com/b", Topics: []Topic{"Media", "Arts"}, Date: at(2022, 3, 14)},
+		{URL: "https://www.bbc.com/c", Topics: []Topic{"Arts"}, Date: at(2022, 3, 13)},
+		{URL: "https://www.bbc.com/d", Topics: []Topic{"Media"}, Date: at(2022, 1, 31)},
+		{URL: "https://example.com/e", Topics: []Topic{"Science"}, Date: at(2021, 1, 1)},
+	}
+
+	weeks := ArticlesOverTime(database, Week, 3, now)
+	if got := weeks.Buckets[2].Start; !got.Equal(time.Date(2022, 3, 14, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got last week starting %v, want Monday Mar 14", got)
+	}
+	if got := [3]int{weeks.Buckets[0].Count, weeks.Buckets[1].Count, weeks.Buckets[2].Count}; got != [3]int{0, 1, 2} {
+		t.Errorf("got weekly counts %v, want [0 1 2]", got)
+	}
+
+	months := ArticlesOverTime(database, Month, 3, now)
+	if months.Buckets[0].Start.Month() != time.January || months.Buckets[0].Count != 1 || months.Buckets[2].Count != 3 {
+		t.Errorf("got monthly buckets %+v", months.Buckets)
+	}
+
+	topics := TopicsOverTime(database, Month, 3, now, 1)
+	if len(topics) != 1 || topics[0].Name != "Media" || topics[0].Total() != 3 {
+		t.Errorf("got top topic %+v, want Media with 3 articles", topics)
+	}
+
+	sources := ArticlesBySource(database, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	if want := []SourceCount{{"BBC", 2}, {"The Straits Times", 2}}; !reflect.DeepEqual(sources, want) {
+		t.Errorf("got sources %+v, want %+v", sources, want)
+	}
+}
+
+func TestCountTags(t *testing.T) {
+	q6 := Question{Year: "2019", Number: "6"}
+	q7 := Question{Year: "2019", Number: "7"}
+	database := &ArticlesDBByDate{
+		{URL: "a", Topics: []Topic{"Media", "Arts"}, Questions: []Question{q6}},
+		{URL: "b", Topics: []Topic{"Media"}, Questions: []Question{q6}},
+		{URL: "c", Topics: []Topic{"Science"}, Questions: []Question{q7}, Status: Draft},
+	}
+
+	tm, qc := CountTags(PublishedArticles(database), QuestionsDB{q6.Key(): q6, q7.Key(): q7})
+	if want := (TopicsMap{"Media": 2, "Arts": 1}); !reflect.DeepEqual(tm, want) {
+		t.Errorf("got topics %v, want %v", tm, want)
+	}
+	if want := (QuestionCounter{q6.Label(): 2, q7.Label(): 0}); !reflect.DeepEqual(qc, want) {
+		t.Errorf("got questions %v, want %v without the draft", qc, want)
+	}
+}
+
+func TestCuratorsOverTime(t *testing.T) {
+	now := time.Date(2022, 3, 16, 15, 0, 0, 0, time.UTC)
+	at := func(m time.Month) int64 { return time.Date(2022, m, 10, 9, 0, 0, 0, time.UTC).Unix() }
+	database := &ArticlesDBByDate{
+		{URL: "a", AddedBy: "Mary", Date: at(3)},
+		{URL: "b", AddedBy: "Mary", Date: at(2)},
+		{URL: "c", AddedBy: "John", Date: at(3)},
+		{URL: "d", Date: at(1)},
+		{URL: "e", AddedBy: "Ann", Date: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix()},
+	}
+
+	got := CuratorsOverTime(database, Month, 3, now)
+	if len(got) != 3 || got[0].Name != "Mary" || got[0].Total() != 2 || got[1].Name != "John" || got[2].Name != NoCurator {
+		t.Fatalf("got %+v, want Mary, John and the articles without a curator", got)
+	}
+	if got[0].Buckets[1].Count != 1 || got[0].Buckets[2].Count != 1 {
+		t.Errorf("got Mary's months %+v, want one article in each of February and March", got[0].Buckets)
+	}
+}
@@ -4,13 +4,10 @@ package db
 import (
 	"context"
 	"fmt"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
-
-	"google.golang.org/api/sheets/v4"
 )
 
 // Article is a struct representing a single entry in the articlesdb.
@@ -21,6 +18,18 @@ type Article struct {
 	Questions   []Question
 	DisplayDate string
 	Date        int64
+	// Source is the name of the publication the article is from, derived from its URL by the Sources registry.
+	Source string
+	// Summary, Note and Prompts are optional: a short summary of the article, a teacher's note on why it matters for GP, and questions for class discussion.
+	Summary string
+	Note    string
+	Prompts []string
+	// Status is the article's stage in the review workflow. AddedBy is the curator who added it, and ReviewedBy the curator who approved it for publishing, if it was reviewed.
+	Status     Status
+	AddedBy    string
+	ReviewedBy string
+	// PublishAt is the Unix time at which a scheduled article is to be published, or 0 if it is not scheduled.
+	PublishAt int64
 }
 
 // SetTopics is a wrapper around an append function to append multiple topics to the Article struct a.
@@ -78,7 +87,7 @@ func (a *Article) AddArticleToDB(db *ArticlesDBByDate, tm TopicsMap, qc Question
 	}
 
 	for _, v := range a.Questions {
-		qc.Increment(v.Year + " - Q" + v.Number)
+		qc.Increment(v.Label())
 	}
 
 	*db = append(*db, *a)
@@ -124,7 +133,7 @@ func (db ArticlesDBByDate) EditArticle(index string, article Article, tm TopicsM
 	}
 
 	for _, v := range db[i].Questions {
-		qc.Decrement(v.Year + " - Q" + v.Number)
+		qc.Decrement(v.Label())
 	}
 
 	for _, v := range article.Topics {
@@ -132,7 +141,7 @@ func (db ArticlesDBByDate) EditArticle(index string, article Article, tm TopicsM
 	}
 
 	for _, v := range article.Questions {
-		qc.Increment(v.Year + " - Q" + v.Number)
+		qc.Increment(v.Label())
 	}
 
 	db[i] = article
@@ -149,77 +158,62 @@ func (db *ArticlesDBByDate) RemoveArticle(index int, tm TopicsMap, qc QuestionCo
 	}
 
 	for _, v := range d[index].Questions {
-		qc.Decrement(v.Year + " - Q" + v.Number)
+		qc.Decrement(v.Label())
 	}
 
 	copy(d[index:], d[index+1:])
 	d[len(d)-1] = Article{}
-	*db = d
+	*db = d[:len(d)-1]
 }
 
-// InitArticlesDB initialises the articles database at first run. Data is downloaded from the incumbent Google Sheets and parsed into the app's data structure. This is meant to be executed only once.
-func (db *ArticlesDBByDate) InitArticlesDB(ctx context.Context, qnDB QuestionsDB, tm TopicsMap, qc QuestionCounter) error {
+// InitArticlesDB initialises the articles database at first run. Data is downloaded from the incumbent Google Sheets and parsed into the app's data structure. Every row is validated with ParseArticle, and rows that are empty, invalid, or repeat the URL of an earlier row are skipped and listed in the returned report, so that the app still starts with the rest. An error is returned only if the sheet cannot be read or has no data. This is meant to be executed only once.
+func (db *ArticlesDBByDate) InitArticlesDB(ctx context.Context, qnDB QuestionsDB, tm TopicsMap, qc QuestionCounter) (*ValidationReport, error) {
 	srv, err := newSheetsService(ctx)
 	if err != nil {
-		return fmt.Errorf("unable to start Sheets service: %w", err)
+		return nil, fmt.Errorf("unable to start Sheets service: %w", err)
 	}
 
 	sheetRange := "Articles"
 
-	data, err := getSheetData(srv, sheetRange)
+	data, err := getSheetData(ctx, srv, sheetRange)
 	if err != nil {
-		return fmt.Errorf("unable to get sheet data: %w", err)
+		return nil, fmt.Errorf("unable to get sheet data: %w", err)
 	}
 
 	if len(data.Values) == 0 {
-		return fmt.Errorf("no data found")
+		return nil, fmt.Errorf("no data found")
 	}
 
+	report := &ValidationReport{Checked: time.Now(), Rows: len(data.Values)}
+	seen := make(map[string]int, len(data.Values))
+
 	for i, row := range data.Values {
 		i++
-		if len(row) < 1 {
-			err := SendMail("admin@njcgpnewsfeed", "joel_poh_weinan@moe.edu.sg", "jwn.poh@gmail.com", "NJC GP Newsfeed DB Error", fmt.Sprintf("Check Articles DB at row %d", i))
-			if err != nil {
-				fmt.Printf("Did not send mail - %v", err)
-			} else {
-				fmt.Println("Sent mail.")
-			}
+		rec := rowToRecord(row)
+		if rec.isBlank() {
+			report.skip(i, rec, "empty row")
 			continue
 		}
 
-		a, err := NewArticle()
+		a, err := ParseArticle(rec, qnDB)
 		if err != nil {
-			return fmt.Errorf("%w", err)
-		}
-		a.Title = fmt.Sprintf("%v", row[0])
-		a.URL = fmt.Sprintf("%v", row[1])
-		if err := a.SetDate(fmt.Sprintf("%v", row[5])); err != nil {
-			return fmt.Errorf("%w", err)
-		}
-
-		topics := strings.Split(fmt.Sprintf("%v", row[2]), "\n")
-		for _, t := range topics {
-			a.SetTopics(t)
-			tm.Increment(Topic(t))
+			report.skip(i, rec, err.Error())
+			continue
 		}
-
-		if row[3] == "" {
-			*db = append(*db, *a)
+		if first, ok := seen[a.URL]; ok {
+			report.skipDuplicate(i, rec, first)
 			continue
 		}
+		seen[a.URL] = i
 
-		qnRow := strings.Split(fmt.Sprintf("%v", row[3]), "\n")
-
-		for _, qn := range qnRow {
-			fields := strings.Split(qn, " ")
-			year := fields[0]
-			number := fields[1]
-			if err := a.SetQuestions(year, number, qnDB); err != nil {
-				return fmt.Errorf("%w", err)
-			}
-			qc.Increment(year + " - Q" + number)
+		for _, t := range a.Topics {
+			tm.Increment(t)
+		}
+		for _, qn := range a.Questions {
+			qc.Increment(qn.Label())
 		}
 		*db = append(*db, *a)
+		report.Loaded++
 	}
 
 	// check questions with zero articles.
@@ -228,7 +222,24 @@ func (db *ArticlesDBByDate) InitArticlesDB(ctx context.Context, qnDB QuestionsDB
 	// sort articles by latest date
 	sort.Sort(sort.Reverse(db))
 
-	return nil
+	return report, nil
+}
+
+// BackupArticlesWrite returns the SheetWrite that backs up the articles database to the Articles sheet.
+func BackupArticlesWrite(database *ArticlesDBByDate) SheetWrite {
+	values := make([][]interface{}, 0, len(*database))
+	for _, j := range *database {
+		values = append(values, NewRecord(j).Row())
+	}
+
+	return SheetWrite{
+		Description:   "back up articles",
+		SpreadsheetID: config.SheetID,
+		Range:         "Articles",
+		Values:        values,
+		InputOption:   "RAW",
+		Replace:       true,
+	}
 }
 
 // BackupArticles backs up the articles database to a predefined, hard-coded Google Sheet.
@@ -238,49 +249,26 @@ func BackupArticles(ctx context.Context, database *ArticlesDBByDate) error {
 		return fmt.Errorf("unable to start Sheets service: %w", err)
 	}
 
-	backupSheetID := os.Getenv("SHEET_ID")
-	backupSheetName := "Articles"
-
-	// setup values to write to sheet
-	var valueRange sheets.ValueRange
-	valueRange.Values = make([][]interface{}, 0, len(*database))
-
-	for _, j := range *database {
-		sTopics := strings.Builder{}
-		for i, k := range j.Topics {
-			if i == len(j.Topics)-1 {
-				sTopics.WriteString(string(k))
-				break
-			}
-			sTopics.WriteString(string(k) + "\n")
-		}
-
-		sQuestions := strings.Builder{}
-		sQuestionsKey := strings.Builder{}
-		for i, l := range j.Questions {
-			if i == len(j.Questions)-1 {
-				sQuestions.WriteString(fmt.Sprint(l.Year) + " " + fmt.Sprint(l.Number) + " " + l.Wording)
-				sQuestionsKey.WriteString(fmt.Sprint(l.Year) + " " + fmt.Sprint(l.Number))
-				break
-			}
-			sQuestions.WriteString(fmt.Sprint(l.Year) + " " + fmt.Sprint(l.Number) + " " + l.Wording + "\n")
-			sQuestionsKey.WriteString(fmt.Sprint(l.Year) + " " + fmt.Sprint(l.Number) + "\n")
-		}
-
-		record := make([]interface{}, 0, 5)
-		record = append(record, j.Title, j.URL, sTopics.String(), sQuestionsKey.String(), sQuestions.String(), j.DisplayDate)
-		valueRange.Values = append(valueRange.Values, record)
-	}
-
-	// write to sheet
-	_, err = srv.Spreadsheets.Values.Update(backupSheetID, backupSheetName, &valueRange).ValueInputOption("RAW").Do()
-	if err != nil {
+	if err := BackupArticlesWrite(database).apply(ctx, srv); err != nil {
 		return fmt.Errorf("unable to backup data to backup sheet: %w", err)
 	}
 
 	return nil
 }
 
+// AppendArticleWrite returns the SheetWrite that appends a new article to the Articles sheet. Rows are identified by URL, so the article is not appended twice.
+func AppendArticleWrite(article *Article) SheetWrite {
+	return SheetWrite{
+		Description:   fmt.Sprintf("append %q to the Articles sheet", article.Title),
+		SpreadsheetID: config.SheetID,
+		Range:         "Articles",
+		Values:        [][]interface{}{NewRecord(*article).Row()},
+		Append:        true,
+		InputOption:   "RAW",
+		KeyColumns:    []int{1},
+	}
+}
+
 // AppendArticle appends a new article added to the web app database to a predefined, hard-coded Google Sheet.
 func AppendArticle(ctx context.Context, article *Article) error {
 	srv, err := newSheetsService(ctx)
@@ -288,58 +276,15 @@ func AppendArticle(ctx context.Context, article *Article) error {
 		return fmt.Errorf("unable to start Sheets service: %w", err)
 	}
 
-	backupSheetID := os.Getenv("SHEET_ID")
-	backupSheetName := "Articles"
-
-	var valueRange sheets.ValueRange
-	valueRange.Values = make([][]interface{}, 0, 1)
-
-	sTopics := strings.Builder{}
-	for i, k := range article.Topics {
-		if i == len(article.Topics)-1 {
-			sTopics.WriteString(string(k))
-			break
-		}
-		sTopics.WriteString(string(k) + "\n")
-	}
-
-	sQuestions := strings.Builder{}
-	sQuestionsKey := strings.Builder{}
-	for i, l := range article.Questions {
-		if i == len(article.Questions)-1 {
-			sQuestions.WriteString(fmt.Sprint(l.Year) + " " + fmt.Sprint(l.Number) + " " + l.Wording)
-			sQuestionsKey.WriteString(fmt.Sprint(l.Year) + " " + fmt.Sprint(l.Number))
-			break
-		}
-		sQuestions.WriteString(fmt.Sprint(l.Year) + " " + fmt.Sprint(l.Number) + " " + l.Wording + "\n")
-		sQuestionsKey.WriteString(fmt.Sprint(l.Year) + " " + fmt.Sprint(l.Number) + "\n")
-	}
-
-	record := make([]interface{}, 0, 6)
-	record = append(record, article.Title, article.URL, sTopics.String(), sQuestionsKey.String(), sQuestions.String(), article.DisplayDate)
-	valueRange.Values = append(valueRange.Values, record)
-
-	_, err = srv.Spreadsheets.Values.Append(backupSheetID, backupSheetName, &valueRange).InsertDataOption("INSERT_ROWS").ValueInputOption("RAW").Do()
-	if err != nil {
+	if err := AppendArticleWrite(article).apply(ctx, srv); err != nil {
 		return fmt.Errorf("unable to append article to backup sheet: %w", err)
 	}
 
 	return nil
 }
 
-// AppendArticleToOld appends a new article added to the web app database to a predefined, hard-coded Google Sheet.
-func AppendArticleToOld(ctx context.Context, article *Article) error {
-	srv, err := newSheetsService(ctx)
-	if err != nil {
-		return fmt.Errorf("unable to start Sheets service: %w", err)
-	}
-
-	backupSheetID := os.Getenv("OLD_SHEET_ID")
-	backupSheetName := "feed"
-
-	var valueRange sheets.ValueRange
-	valueRange.Values = make([][]interface{}, 0, 1)
-
+// AppendArticleToOldWrite returns the SheetWrite that appends a new article to the feed sheet of the old spreadsheet. Rows are identified by URL, so the article is not appended twice.
+func AppendArticleToOldWrite(article *Article) SheetWrite {
 	tags := make([]interface{}, 0)
 
 	sTopics := strings.Builder{}
@@ -356,21 +301,37 @@ func AppendArticleToOld(ctx context.Context, article *Article) error {
 	sQuestions := strings.Builder{}
 	for i, l := range article.Questions {
 		if i == len(article.Questions)-1 {
-			sQuestions.WriteString(fmt.Sprintf("%s (%s - Q%s)", l.Wording, l.Year, l.Number))
-			tags = append(tags, fmt.Sprintf("%s-Q%s", l.Year, l.Number))
+			sQuestions.WriteString(fmt.Sprintf("%s (%s)", l.Wording, l.Label()))
+			tags = append(tags, l.Tag())
 			break
 		}
-		sQuestions.WriteString(fmt.Sprintf("%s (%s - Q%s)<br><br>", l.Wording, l.Year, l.Number))
-		tags = append(tags, fmt.Sprintf("%s-Q%s", l.Year, l.Number))
+		sQuestions.WriteString(fmt.Sprintf("%s (%s)<br><br>", l.Wording, l.Label()))
+		tags = append(tags, l.Tag())
 	}
 
 	record := make([]interface{}, 0, 13)
 	record = append(record, article.Title, article.URL, sTopics.String(), sQuestions.String(), article.DisplayDate)
 	record = append(record, tags...)
-	valueRange.Values = append(valueRange.Values, record)
 
-	_, err = srv.Spreadsheets.Values.Append(backupSheetID, backupSheetName, &valueRange).InsertDataOption("INSERT_ROWS").ValueInputOption("USER_ENTERED").Do()
+	return SheetWrite{
+		Description:   fmt.Sprintf("append %q to the old feed sheet", article.Title),
+		SpreadsheetID: config.OldSheetID,
+		Range:         "feed",
+		Values:        [][]interface{}{record},
+		Append:        true,
+		InputOption:   "USER_ENTERED",
+		KeyColumns:    []int{1},
+	}
+}
+
+// AppendArticleToOld appends a new article added to the web app database to a predefined, hard-coded Google Sheet.
+func AppendArticleToOld(ctx context.Context, article *Article) error {
+	srv, err := newSheetsService(ctx)
 	if err != nil {
+		return fmt.Errorf("unable to start Sheets service: %w", err)
+	}
+
+	if err := AppendArticleToOldWrite(article).apply(ctx, srv); err != nil {
 		return fmt.Errorf("unable to append article to backup sheet: %w", err)
 	}
 
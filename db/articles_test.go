@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+var testArticles = [][]interface{}{
+	{"Older article", "https://example.com/older", "Science\nTechnology", "2019 6", "2019 6 How far is science a force for good?", "Jan 2, 2021"},
+	{"Newer article", "https://example.com/newer", "Media", "", "", "Feb 3, 2021"},
+}
+
+func TestInitArticlesDB(t *testing.T) {
+	useFakeSheets(t, testArticles, testQuestions)
+	ctx := context.Background()
+
+	qnDB, err := InitQuestionsDB(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	database := NewArticlesDBByDate()
+	tm := InitTopicsMap()
+	qc := InitQuestionCounter()
+	if _, err := database.InitArticlesDB(ctx, qnDB, tm, qc); err != nil {
+		t.Fatal(err)
+	}
+
+	if database.Len() != 2 {
+		t.Fatalf("got %d articles, want 2", database.Len())
+	}
+
+	newer, older := (*database)[0], (*database)[1]
+	if newer.Title != "Newer article" || older.Title != "Older article" {
+		t.Errorf("articles not sorted by most recent: got %q, %q", newer.Title, older.Title)
+	}
+
+	if want := []Topic{"Science", "Technology"}; !reflect.DeepEqual(older.Topics, want) {
+		t.Errorf("got topics %v, want %v", older.Topics, want)
+	}
+
+	if want := []Question{qnDB["2019 6"]}; !reflect.DeepEqual(older.Questions, want) {
+		t.Errorf("got questions %v, want %v", older.Questions, want)
+	}
+
+	wantTopics := TopicsMap{"Science": 1, "Technology": 1, "Media": 1}
+	if !reflect.DeepEqual(tm, wantTopics) {
+		t.Errorf("got topics map %v, want %v", tm, wantTopics)
+	}
+
+	wantCounter := QuestionCounter{"2019 - Q6": 1, "2020 - Q1": 0}
+	if !reflect.DeepEqual(qc, wantCounter) {
+		t.Errorf("got question counter %v, want %v", qc, wantCounter)
+	}
+}
+
+func TestInitArticlesDBSkipsBadRows(t *testing.T) {
+	rows := [][]interface{}{
+		testArticles[0],
+		{},
+		{"Short row", "https://example.com/short"},
+		{"Bad date", "https://example.com/date", "Media", "", "", "2 Jan 2021"},
+		{"Bad key", "https://example.com/key", "Media", "2019", "", "Jan 2, 2021"},
+		{"Spaced key", "https://example.com/spaced", "Media", "2019  Q6 ", "", "Jan 3, 2021"},
+		{"Duplicate", "https://example.com/older", "Media", "", "", "Jan 2, 2021"},
+	}
+	useFakeSheets(t, rows, testQuestions)
+	ctx := context.Background()
+
+	qnDB, err := InitQuestionsDB(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := NewArticlesDBByDate()
+	report, err := database.InitArticlesDB(ctx, qnDB, InitTopicsMap(), InitQuestionCounter())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Rows != 7 || report.Loaded != 2 || database.Len() != 2 {
+		t.Errorf("got %d of %d rows loaded and %d articles, want 2 of 7", report.Loaded, report.Rows, database.Len())
+	}
+
+	var got []int
+	for _, e := range report.Errors {
+		got = append(got, e.Row)
+	}
+	if want := []int{2, 3, 4, 5, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got errors for rows %v, want %v: %v", got, want, report.Errors)
+	}
+}
+
+func TestInitArticlesDBEmpty(t *testing.T) {
+	useFakeSheets(t, nil, testQuestions)
+
+	database := NewArticlesDBByDate()
+	if _, err := database.InitArticlesDB(context.Background(), QuestionsDB{}, InitTopicsMap(), InitQuestionCounter()); err == nil {
+		t.Error("expected an error for an empty Articles sheet")
+	}
+}
+
+func TestBackupArticles(t *testing.T) {
+	fake := useFakeSheets(t, testArticles, testQuestions)
+	ctx := context.Background()
+
+	qnDB, err := InitQuestionsDB(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := NewArticlesDBByDate()
+	if _, err := database.InitArticlesDB(ctx, qnDB, InitTopicsMap(), InitQuestionCounter()); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.SetValues(testSheetID, "Articles", nil)
+	if err := BackupArticles(ctx, database); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]interface{}{
+		{"Newer article", "https://example.com/newer", "Media", "", "", "Feb 3, 2021"},
+		{"Older article", "https://example.com/older", "Science\nTechnology", "2019 6", "2019 6 How far is science a force for good?", "Jan 2, 2021"},
+	}
+	if got := fake.Values(testSheetID, "Articles"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestArticleNotes(t *testing.T) {
+	row := []interface{}{"Noted article", "https://example.com/noted", "Media", "", "", "Mar 4, 2021", "A short summary.", "Shows how algorithms shape the news.", "Who decides what we read?\nShould feeds be regulated?"}
+	fake := useFakeSheets(t, [][]interface{}{row, testArticles[1]}, testQuestions)
+	ctx := context.Background()
+
+	qnDB, err := InitQuestionsDB(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := NewArticlesDBByDate()
+	if _, err := database.InitArticlesDB(ctx, qnDB, InitTopicsMap(), InitQuestionCounter()); err != nil {
+		t.Fatal(err)
+	}
+
+	a := (*database)[0]
+	if a.Summary != "A short summary." || a.Note != "Shows how algorithms shape the news." || len(a.Prompts) != 2 {
+		t.Fatalf("got summary %q, note %q and prompts %q", a.Summary, a.Note, a.Prompts)
+	}
+	if got := Search("regulated", database); got.Len() != 1 {
+		t.Errorf("got %d articles searching the discussion prompts, want 1", got.Len())
+	}
+
+	fake.SetValues(testSheetID, "Articles", nil)
+	if err := BackupArticles(ctx, database); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]interface{}{row, testArticles[1]}
+	if got := fake.Values(testSheetID, "Articles"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestAppendArticle(t *testing.T) {
+	fake := useFakeSheets(t, testArticles[:1], testQuestions)
+
+	a, err := ParseArticle(Record{
+		Title:  "Newer article",
+		URL:    "https://example.com/newer",
+		Topics: []string{"media"},
+		Date:   "Feb 3, 2021",
+	}, QuestionsDB{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := AppendArticle(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fake.Values(testSheetID, "Articles"); !reflect.DeepEqual(got, testArticles) {
+		t.Errorf("got %v, want %v", got, testArticles)
+	}
+}
+
+func TestAppendArticleToOld(t *testing.T) {
+	fake := useFakeSheets(t, nil, testQuestions)
+
+	a := &Article{
+		Title:       "Older article",
+		URL:         "https://example.com/older",
+		Topics:      []Topic{"Science", "Technology"},
+		Questions:   []Question{{Year: "2019", Number: "6", Wording: "How far is science a force for good?"}},
+		DisplayDate: "Jan 2, 2021",
+	}
+	if err := AppendArticleToOld(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]interface{}{
+		{"Older article", "https://example.com/older", "Science, Technology", "How far is science a force for good? (2019 - Q6)", "Jan 2, 2021", "Science", "Technology", "2019-Q6"},
+	}
+	if got := fake.Values(testOldSheetID, "feed"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
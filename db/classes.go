@@ -0,0 +1,374 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Limits on classes and the reading assigned to them.
+const (
+	MaxClassNameLength       = 40
+	MaxClassSize             = 60
+	MaxAssignmentTitleLength = 100
+	MaxAssignmentArticles    = 30
+)
+
+// DueDateFormat is the layout of the due dates of assignments in the Assignments sheet, as sent by the date input of the assignment form.
+const DueDateFormat = "2006-01-02"
+
+var (
+	// ErrInvalidClass is returned when a class is given an empty or too long name, or a class list with too many students or email addresses that are not of students.
+	ErrInvalidClass = errors.New("invalid class")
+	// ErrInvalidAssignment is returned when an assignment has no title, articles or due date, or too many articles.
+	ErrInvalidAssignment = errors.New("invalid assignment")
+)
+
+// Class is a teaching group, such as a civics class, with the school email addresses of its students in lower case.
+type Class struct {
+	Name     string
+	Students []string
+}
+
+// NewClass returns the class with the given name and class list, in which the students' email addresses are separated by new lines, commas, semicolons or spaces. It returns ErrInvalidClass if the name is empty or too long, the class has more than MaxClassSize students, or an email address is not at one of the student domains.
+func NewClass(name, classList string) (Class, error) {
+	c := Class{Name: strings.Join(strings.Fields(name), " ")}
+	if c.Name == "" || len([]rune(c.Name)) > MaxClassNameLength {
+		return Class{}, fmt.Errorf("%w name %q: it must have 1 to %d characters", ErrInvalidClass, c.Name, MaxClassNameLength)
+	}
+
+	var invalid []string
+	for _, email := range strings.FieldsFunc(classList, func(r rune) bool { return r == ',' || r == ';' || r == ' ' || r == '\t' || r == '\n' || r == '\r' }) {
+		email = NormalizeEmail(email)
+		if !config.IsStudentEmail(email) {
+			invalid = append(invalid, email)
+			continue
+		}
+		c.Students = setURL(c.Students, email, true)
+	}
+	if len(invalid) > 0 {
+		return Class{}, fmt.Errorf("%w list: not the email address of a student: %s", ErrInvalidClass, strings.Join(invalid, ", "))
+	}
+	if len(c.Students) > MaxClassSize {
+		return Class{}, fmt.Errorf("%w list: it has %d students, more than %d", ErrInvalidClass, len(c.Students), MaxClassSize)
+	}
+	sort.Strings(c.Students)
+	return c, nil
+}
+
+// ClassList returns the students' email addresses one per line, as they are entered in the class form.
+func (c Class) ClassList() string {
+	return strings.Join(c.Students, "\n")
+}
+
+// Has reports whether the student with the given email address is in the class.
+func (c Class) Has(email string) bool {
+	return hasURL(c.Students, NormalizeEmail(email))
+}
+
+// Classes are the classes taught with the feed, by name. They are backed by the Classes sheet, which has a row for each student in a class.
+type Classes []Class
+
+// Find returns the class with the given name, ignoring case, or nil if there is none.
+func (cs Classes) Find(name string) *Class {
+	name = strings.Join(strings.Fields(name), " ")
+	for i := range cs {
+		if strings.EqualFold(cs[i].Name, name) {
+			return &cs[i]
+		}
+	}
+	return nil
+}
+
+// Set returns the classes with c added, or in place of the class with the same name, sorted by name.
+func (cs Classes) Set(c Class) Classes {
+	results := cs.Remove(c.Name)
+	results = append(results, c)
+	sort.Slice(results, func(i, j int) bool { return strings.ToLower(results[i].Name) < strings.ToLower(results[j].Name) })
+	return results
+}
+
+// Remove returns the classes without the class with the given name.
+func (cs Classes) Remove(name string) Classes {
+	results := make(Classes, 0, len(cs))
+	for _, c := range cs {
+		if !strings.EqualFold(c.Name, strings.Join(strings.Fields(name), " ")) {
+			results = append(results, c)
+		}
+	}
+	return results
+}
+
+// Of returns the names of the classes the student with the given email address is in.
+func (cs Classes) Of(email string) []string {
+	var names []string
+	for _, c := range cs {
+		if c.Has(email) {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// Assignment is reading assigned to a class, a list of articles by URL to be read by the due date. Query is the search the articles were picked from, if any, so that the assignment can be built again from newer articles.
+type Assignment struct {
+	ID      string
+	Class   string
+	Title   string
+	Query   string
+	URLs    []string
+	Due     time.Time
+	SetBy   string
+	Created time.Time
+}
+
+// NewAssignment returns the reading assigned to class by setBy at now, to be read by the due date in DueDateFormat. Duplicate and empty URLs are dropped. It returns ErrInvalidAssignment if the title is empty or too long, there are no or more than MaxAssignmentArticles articles, or the due date is not a date.
+func NewAssignment(class, title, query string, urls []string, due, setBy string, now time.Time) (Assignment, error) {
+	a := Assignment{
+		ID:      fmt.Sprintf("%x", now.UnixNano()),
+		Class:   class,
+		Title:   strings.Join(strings.Fields(title), " "),
+		Query:   strings.TrimSpace(query),
+		SetBy:   setBy,
+		Created: now,
+	}
+	if a.Title == "" || len([]rune(a.Title)) > MaxAssignmentTitleLength {
+		return Assignment{}, fmt.Errorf("%w title %q: it must have 1 to %d characters", ErrInvalidAssignment, a.Title, MaxAssignmentTitleLength)
+	}
+
+	for _, url := range urls {
+		if url = strings.TrimSpace(url); url != "" {
+			a.URLs = setURL(a.URLs, url, true)
+		}
+	}
+	if len(a.URLs) == 0 || len(a.URLs) > MaxAssignmentArticles {
+		return Assignment{}, fmt.Errorf("%w: it must have 1 to %d articles, not %d", ErrInvalidAssignment, MaxAssignmentArticles, len(a.URLs))
+	}
+
+	d, err := time.ParseInLocation(DueDateFormat, strings.TrimSpace(due), time.Local)
+	if err != nil {
+		return Assignment{}, fmt.Errorf("%w due date %q", ErrInvalidAssignment, due)
+	}
+	a.Due = d
+	return a, nil
+}
+
+// DisplayDue returns the due date of the assignment as it is shown on the site.
+func (a Assignment) DisplayDue() string {
+	return a.Due.Format("Mon, Jan 2, 2006")
+}
+
+// Overdue reports whether the assignment was due before the day of now.
+func (a Assignment) Overdue(now time.Time) bool {
+	return now.After(a.Due.AddDate(0, 0, 1))
+}
+
+// Progress is how far a student in a class has got with an assignment. Read holds whether they have marked each of the assignment's articles as read, in the same order.
+type Progress struct {
+	Email string
+	Read  []bool
+	Done  int
+}
+
+// Complete reports whether the student has read every article of the assignment.
+func (p Progress) Complete() bool {
+	return len(p.Read) > 0 && p.Done == len(p.Read)
+}
+
+// Progress returns the published articles of the assignment, and how far each student in class has got with them according to the articles they have marked as read. Students without an account have read none of them.
+func (a Assignment) Progress(class Class, st Students, database *ArticlesDBByDate) (*ArticlesDBByDate, []Progress) {
+	articles := ArticlesByURL(database, a.URLs)
+
+	progress := make([]Progress, 0, len(class.Students))
+	for _, email := range class.Students {
+		p := Progress{Email: email, Read: make([]bool, articles.Len())}
+		if s, ok := st[email]; ok {
+			for i, article := range *articles {
+				if s.HasRead(article.URL) {
+					p.Read[i] = true
+					p.Done++
+				}
+			}
+		}
+		progress = append(progress, p)
+	}
+	return articles, progress
+}
+
+// Assignments are the reading assigned to classes, oldest first. They are backed by the Assignments sheet.
+type Assignments []Assignment
+
+// Find returns the assignment with the given ID, or nil if there is none.
+func (as Assignments) Find(id string) *Assignment {
+	for i := range as {
+		if as[i].ID == id {
+			return &as[i]
+		}
+	}
+	return nil
+}
+
+// Remove returns the assignments without the one with the given ID.
+func (as Assignments) Remove(id string) Assignments {
+	results := make(Assignments, 0, len(as))
+	for _, a := range as {
+		if a.ID != id {
+			results = append(results, a)
+		}
+	}
+	return results
+}
+
+// ForClasses returns the reading assigned to any of the named classes, soonest due first.
+func (as Assignments) ForClasses(names ...string) Assignments {
+	var results Assignments
+	for _, a := range as {
+		for _, name := range names {
+			if strings.EqualFold(a.Class, name) {
+				results = append(results, a)
+				break
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Due.Before(results[j].Due) })
+	return results
+}
+
+// WithoutClass returns the assignments without the reading assigned to the named class, as when the class is deleted.
+func (as Assignments) WithoutClass(name string) Assignments {
+	results := make(Assignments, 0, len(as))
+	for _, a := range as {
+		if !strings.EqualFold(a.Class, name) {
+			results = append(results, a)
+		}
+	}
+	return results
+}
+
+// InitClasses reads the classes from the Classes sheet, whose rows are class name and student email address. A row of a class without an email address keeps an empty class. Rows without a class name are skipped.
+func InitClasses(ctx context.Context) (Classes, error) {
+	srv, err := newSheetsService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start Sheets service: %w", err)
+	}
+
+	data, err := getSheetData(ctx, srv, "Classes")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get sheet data: %w", err)
+	}
+
+	var cs Classes
+	for _, row := range data.Values {
+		cell := func(i int) string {
+			if i < len(row) {
+				return strings.TrimSpace(fmt.Sprint(row[i]))
+			}
+			return ""
+		}
+		if cell(0) == "" {
+			continue
+		}
+
+		c := cs.Find(cell(0))
+		if c == nil {
+			cs = cs.Set(Class{Name: strings.Join(strings.Fields(cell(0)), " ")})
+			c = cs.Find(cell(0))
+		}
+		if email := NormalizeEmail(cell(1)); email != "" {
+			c.Students = setURL(c.Students, email, true)
+		}
+	}
+	return cs, nil
+}
+
+// BackupClassesWrite returns the SheetWrite that backs up every class to the Classes sheet.
+func BackupClassesWrite(cs Classes) SheetWrite {
+	values := make([][]interface{}, 0)
+	for _, c := range cs {
+		if len(c.Students) == 0 {
+			values = append(values, []interface{}{c.Name, ""})
+		}
+		for _, email := range c.Students {
+			values = append(values, []interface{}{c.Name, email})
+		}
+	}
+
+	return SheetWrite{
+		Description:   "back up the classes",
+		SpreadsheetID: config.SheetID,
+		Range:         "Classes",
+		Values:        values,
+		InputOption:   "RAW",
+		Replace:       true,
+	}
+}
+
+// InitAssignments reads the reading assigned to classes from the Assignments sheet, whose rows are ID, class, title, due date, search query, article URLs one per line, curator who set it, and time set. Rows without an ID, class, articles or a valid due date are skipped.
+func InitAssignments(ctx context.Context) (Assignments, error) {
+	srv, err := newSheetsService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start Sheets service: %w", err)
+	}
+
+	data, err := getSheetData(ctx, srv, "Assignments")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get sheet data: %w", err)
+	}
+
+	as := make(Assignments, 0, len(data.Values))
+	for _, row := range data.Values {
+		cell := func(i int) string {
+			if i < len(row) {
+				return strings.TrimSpace(fmt.Sprint(row[i]))
+			}
+			return ""
+		}
+
+		a := Assignment{
+			ID:    cell(0),
+			Class: cell(1),
+			Title: cell(2),
+			Query: cell(4),
+			URLs:  trimAll(strings.Split(cell(5), "\n")),
+			SetBy: cell(6),
+		}
+		due, err := time.ParseInLocation(DueDateFormat, cell(3), time.Local)
+		if a.ID == "" || a.Class == "" || len(a.URLs) == 0 || err != nil {
+			continue
+		}
+		a.Due = due
+		a.Created, _ = time.Parse(time.RFC3339, cell(7))
+		as = append(as, a)
+	}
+	return as, nil
+}
+
+// BackupAssignmentsWrite returns the SheetWrite that backs up the reading assigned to every class to the Assignments sheet.
+func BackupAssignmentsWrite(as Assignments) SheetWrite {
+	values := make([][]interface{}, 0, len(as))
+	for _, a := range as {
+		values = append(values, []interface{}{
+			a.ID,
+			a.Class,
+			a.Title,
+			a.Due.Format(DueDateFormat),
+			a.Query,
+			strings.Join(a.URLs, "\n"),
+			a.SetBy,
+			a.Created.Format(time.RFC3339),
+		})
+	}
+
+	return SheetWrite{
+		Description:   "back up the assignments",
+		SpreadsheetID: config.SheetID,
+		Range:         "Assignments",
+		Values:        values,
+		InputOption:   "RAW",
+		Replace:       true,
+	}
+}
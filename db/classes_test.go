@@ -0,0 +1,60 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAssignmentProgress(t *testing.T) {
+	old := config
+	cfg := DefaultConfig()
+	cfg.StudentDomains = []string{"students.example.edu"}
+	Configure(cfg)
+	t.Cleanup(func() { Configure(old) })
+
+	if _, err := NewClass("Civics 21A", "ann@students.example.edu, teacher@example.edu"); !errors.Is(err, ErrInvalidClass) {
+		t.Errorf("got %v for a class list with a teacher's email address, want ErrInvalidClass", err)
+	}
+	class, err := NewClass(" Civics  21A ", "Bob@students.example.edu\nann@students.example.edu; bob@students.example.edu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if class.Name != "Civics 21A" || len(class.Students) != 2 || class.Students[0] != "ann@students.example.edu" {
+		t.Errorf("got class %+v, want Civics 21A with ann and bob", class)
+	}
+
+	now := time.Date(2021, 3, 1, 9, 0, 0, 0, time.Local)
+	if _, err := NewAssignment(class.Name, "Week 9", "", nil, "2021-03-05", "Joel", now); !errors.Is(err, ErrInvalidAssignment) {
+		t.Errorf("got %v for an assignment without articles, want ErrInvalidAssignment", err)
+	}
+	a, err := NewAssignment(class.Name, "Week 9", "ageing", []string{"https://example.com/a", " https://example.com/b", "https://example.com/a", "https://example.com/draft"}, "2021-03-05", "Joel", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Overdue(now.AddDate(0, 0, 4)) || !a.Overdue(now.AddDate(0, 0, 5)) {
+		t.Errorf("got the assignment due %v overdue on the wrong day", a.Due)
+	}
+
+	st := make(Students)
+	st.Get("ann@students.example.edu").SetRead("https://example.com/a", true)
+	st.Get("ann@students.example.edu").SetRead("https://example.com/b", true)
+	st.Get("ann@students.example.edu").SetRead("https://example.com/draft", true)
+	database := &ArticlesDBByDate{{URL: "https://example.com/a"}, {URL: "https://example.com/b"}, {URL: "https://example.com/draft", Status: Draft}}
+
+	articles, progress := a.Progress(class, st, database)
+	if articles.Len() != 2 {
+		t.Errorf("got %d articles, want the 2 published ones", articles.Len())
+	}
+	if len(progress) != 2 || !progress[0].Complete() || progress[1].Complete() || progress[1].Done != 0 {
+		t.Errorf("got progress %+v, want ann to be done and bob, without an account, to have read nothing", progress)
+	}
+
+	as := Assignments{a}
+	if got := as.ForClasses(Classes{class}.Of("ANN@students.example.edu")...); len(got) != 1 {
+		t.Errorf("got %d assignments for ann, want 1", len(got))
+	}
+	if got := as.WithoutClass("civics 21a"); len(got) != 0 {
+		t.Errorf("got %d assignments after deleting the class, want 0", len(got))
+	}
+}
@@ -0,0 +1,289 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// classifierNeighbours is the number of most similar articles whose tags are suggested for a new article.
+const classifierNeighbours = 10
+
+// stopWords are common words that say nothing about an article's topic, and are left out of a Document.
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true, "you": true, "all": true,
+	"any": true, "can": true, "had": true, "her": true, "was": true, "one": true, "our": true, "out": true,
+	"has": true, "have": true, "his": true, "how": true, "its": true, "who": true, "did": true, "yet": true,
+	"this": true, "that": true, "with": true, "from": true, "they": true, "will": true, "would": true,
+	"there": true, "their": true, "what": true, "about": true, "which": true, "when": true, "were": true,
+	"been": true, "more": true, "than": true, "into": true, "also": true, "some": true, "could": true,
+	"them": true, "only": true, "other": true, "after": true, "over": true, "said": true, "says": true,
+	"just": true, "like": true, "while": true, "where": true, "your": true, "these": true, "those": true,
+	"such": true, "should": true, "being": true, "why": true, "may": true, "new": true, "now": true,
+}
+
+var wordPattern = regexp.MustCompile(`[a-z]+`)
+
+// Document is the bag of words of an article's text, counting how often each word appears.
+type Document map[string]int
+
+// NewDocument returns the Document of text. Words are lower cased, and short words and stop words are left out.
+func NewDocument(text string) Document {
+	doc := make(Document)
+	for _, w := range wordPattern.FindAllString(strings.ToLower(text), -1) {
+		if len(w) < 3 || stopWords[w] {
+			continue
+		}
+		doc[w]++
+	}
+	return doc
+}
+
+// Add adds the words of other to the document.
+func (doc Document) Add(other Document) {
+	for w, n := range other {
+		doc[w] += n
+	}
+}
+
+// TagSuggestion is a tag suggested for an article by a TagClassifier. Tag is written in the form used in the add article form's tags field, and Confidence, between 0 and 1, is the share of the most similar articles tagged with it, weighted by similarity.
+type TagSuggestion struct {
+	Tag        string  `json:"tag"`
+	Kind       string  `json:"kind"`
+	Confidence float64 `json:"confidence"`
+}
+
+// TagClassifier suggests topics and past year questions for an article from the tags of the existing articles most similar to it, comparing the words of their titles and text weighted by TF-IDF.
+type TagClassifier struct {
+	idf      map[string]float64
+	articles []classifiedArticle
+}
+
+type classifiedArticle struct {
+	url    string
+	vector map[string]float64
+	tags   []TagSuggestion
+}
+
+// TrainTagClassifier returns a TagClassifier trained on the titles of the articles in the database, together with the text of any article in texts, which is keyed by URL.
+func TrainTagClassifier(database *ArticlesDBByDate, texts map[string]Document) *TagClassifier {
+	docs := make([]Document, 0, len(*database))
+	df := make(map[string]int)
+	for _, a := range *database {
+		doc := NewDocument(a.Title)
+		doc.Add(texts[a.URL])
+		for w := range doc {
+			df[w]++
+		}
+		docs = append(docs, doc)
+	}
+
+	c := &TagClassifier{idf: make(map[string]float64, len(df))}
+	n := float64(len(docs))
+	for w, f := range df {
+		c.idf[w] = math.Log((n+1)/(float64(f)+1)) + 1
+	}
+
+	for i, a := range *database {
+		ca := classifiedArticle{url: a.URL, vector: c.vector(docs[i])}
+		for _, t := range a.Topics {
+			ca.tags = append(ca.tags, TagSuggestion{Tag: string(t), Kind: SuggestTopic})
+		}
+		for _, qn := range a.Questions {
+			ca.tags = append(ca.tags, TagSuggestion{Tag: qn.Tag(), Kind: SuggestQuestion})
+		}
+		c.articles = append(c.articles, ca)
+	}
+	return c
+}
+
+// TrainingFingerprint returns a hash of everything TrainTagClassifier uses from the database and texts: the title, URL and tags of each article, and whether its text is in texts. A trained TagClassifier can be kept for as long as the fingerprint is the same, as the text of an article is not changed once fetched.
+func TrainingFingerprint(database *ArticlesDBByDate, texts map[string]Document) uint64 {
+	h := fnv.New64a()
+	for _, a := range *database {
+		io.WriteString(h, a.Title+"\x00"+a.URL+"\x00")
+		if _, ok := texts[a.URL]; ok {
+			io.WriteString(h, "text\x00")
+		}
+		for _, t := range a.Topics {
+			io.WriteString(h, string(t)+"\x00")
+		}
+		for _, qn := range a.Questions {
+			io.WriteString(h, qn.Tag()+"\x00")
+		}
+		io.WriteString(h, "\n")
+	}
+	return h.Sum64()
+}
+
+// vector returns the TF-IDF vector of doc, scaled to unit length. Words the classifier was not trained on are left out.
+func (c *TagClassifier) vector(doc Document) map[string]float64 {
+	v := make(map[string]float64, len(doc))
+	var norm float64
+	for w, n := range doc {
+		idf, ok := c.idf[w]
+		if !ok {
+			continue
+		}
+		v[w] = (1 + math.Log(float64(n))) * idf
+		norm += v[w] * v[w]
+	}
+
+	norm = math.Sqrt(norm)
+	for w := range v {
+		v[w] /= norm
+	}
+	return v
+}
+
+// Suggest returns up to limit tags for an article with the words in doc, most confident first. The article with the URL exclude, e.g. the article being edited, is not compared with itself.
+func (c *TagClassifier) Suggest(doc Document, exclude string, limit int) []TagSuggestion {
+	v := c.vector(doc)
+	if len(v) == 0 {
+		return nil
+	}
+
+	type neighbour struct {
+		article    *classifiedArticle
+		similarity float64
+	}
+	var neighbours []neighbour
+	for i := range c.articles {
+		a := &c.articles[i]
+		if a.url == exclude {
+			continue
+		}
+		var sim float64
+		for w, x := range v {
+			sim += x * a.vector[w]
+		}
+		if sim > 0 {
+			neighbours = append(neighbours, neighbour{a, sim})
+		}
+	}
+	sort.Slice(neighbours, func(i, j int) bool { return neighbours[i].similarity > neighbours[j].similarity })
+	if len(neighbours) > classifierNeighbours {
+		neighbours = neighbours[:classifierNeighbours]
+	}
+
+	var total float64
+	scores := make(map[TagSuggestion]float64)
+	for _, n := range neighbours {
+		total += n.similarity
+		for _, t := range n.article.tags {
+			scores[t] += n.similarity
+		}
+	}
+
+	suggestions := make([]TagSuggestion, 0, len(scores))
+	for t, score := range scores {
+		t.Confidence = score / total
+		suggestions = append(suggestions, t)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Confidence != suggestions[j].Confidence {
+			return suggestions[i].Confidence > suggestions[j].Confidence
+		}
+		return suggestions[i].Tag < suggestions[j].Tag
+	})
+
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+var (
+	hiddenElements = regexp.MustCompile(`(?is)<(script|style|noscript)[^>]*>.*?</(script|style|noscript)>`)
+	paragraphs     = regexp.MustCompile(`(?is)<p[\s>].*?</p>`)
+	metaContent    = regexp.MustCompile(`(?is)<meta[^>]*(?:property|name)="(?:og:title|og:description|description)"[^>]*content="([^"]*)"`)
+	htmlTags       = regexp.MustCompile(`(?s)<[^>]*>`)
+	spaces         = regexp.MustCompile(`\s+`)
+)
+
+// ExtractText returns the readable text of an HTML page: its title and description, followed by the text of its paragraphs.
+func ExtractText(r io.Reader) (string, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, 2<<20))
+	if err != nil {
+		return "", fmt.Errorf("unable to read page: %w", err)
+	}
+	page := hiddenElements.ReplaceAllString(string(b), " ")
+
+	var text strings.Builder
+	for _, m := range metaContent.FindAllStringSubmatch(page, -1) {
+		text.WriteString(m[1] + " ")
+	}
+	for _, p := range paragraphs.FindAllString(page, -1) {
+		text.WriteString(htmlTags.ReplaceAllString(p, " ") + " ")
+	}
+
+	return strings.TrimSpace(spaces.ReplaceAllString(html.UnescapeString(text.String()), " ")), nil
+}
+
+// FetchArticleText requests the article at url with client and returns its readable text.
+func FetchArticleText(ctx context.Context, client *http.Client, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; njcgpnewsfeed)")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("unable to fetch %s: %s", url, resp.Status)
+	}
+	return ExtractText(resp.Body)
+}
+
+// FetchArticleTexts fetches the text of every article in the database whose URL is not in have, the URLs whose text is already known, using the given number of workers, and returns the Document of each one fetched, keyed by URL. Articles that cannot be fetched are left out.
+func FetchArticleTexts(ctx context.Context, database *ArticlesDBByDate, have map[string]bool, client *http.Client, workers int) map[string]Document {
+	if workers < 1 {
+		workers = 1
+	}
+
+	fetched := make(map[string]Document)
+	jobs := make(chan string)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for url := range jobs {
+				text, err := FetchArticleText(ctx, client, url)
+				if err != nil {
+					continue
+				}
+				mu.Lock()
+				fetched[url] = NewDocument(text)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, a := range *database {
+		if !have[a.URL] {
+			jobs <- a.URL
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return fetched
+}
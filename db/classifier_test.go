@@ -0,0 +1,65 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTagClassifier(t *testing.T) {
+	database := &ArticlesDBByDate{
+		{Title: "Carbon tax to rise as emissions climb", URL: "a", Topics: []Topic{"Environment"}},
+		{Title: "Rising sea levels threaten coastal cities", URL: "b", Topics: []Topic{"Environment"}, Questions: []Question{{Year: "2020", Number: "10", Wording: ""}}},
+		{Title: "Social media giants face fines over misinformation", URL: "c", Topics: []Topic{"Media"}},
+		{Title: "Budget boosts spending on healthcare", URL: "d", Topics: []Topic{"Economy"}},
+	}
+	texts := map[string]Document{"d": NewDocument("hospitals and clinics will receive more funding")}
+
+	c := TrainTagClassifier(database, texts)
+	got := c.Suggest(NewDocument("Government plans carbon tax to cut emissions from coastal power plants"), "", 3)
+	if len(got) == 0 || got[0].Tag != "Environment" || got[0].Confidence != 1 {
+		t.Fatalf("got suggestions %+v, want Environment first with full confidence", got)
+	}
+	if got[1].Tag != "2020-Q10" || got[1].Kind != SuggestQuestion || got[1].Confidence >= 1 {
+		t.Errorf("got second suggestion %+v, want question 2020-Q10 with less confidence", got[1])
+	}
+
+	if got := c.Suggest(NewDocument("new funding for hospitals"), "", 3); len(got) != 1 || got[0].Tag != "Economy" {
+		t.Errorf("got suggestions %+v from fetched text, want Economy", got)
+	}
+	if got := c.Suggest(NewDocument("Social media fines"), "c", 3); len(got) != 0 {
+		t.Errorf("got suggestions %+v, want none with the only similar article excluded", got)
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	page := `<html><head><meta property="og:title" content="Storms &amp; floods"><script>var p = "<p>no</p>";</script></head>
+<body><nav>Menu</nav><p class="lead">Heavy <b>rain</b> fell.</p><p>Roads closed.</p></body></html>`
+
+	got, err := ExtractText(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Storms & floods Heavy rain fell. Roads closed."; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTrainingFingerprint(t *testing.T) {
+	database := &ArticlesDBByDate{{Title: "Carbon tax to rise", URL: "a", Topics: []Topic{"Environment"}}}
+	texts := map[string]Document{}
+	fp := TrainingFingerprint(database, texts)
+
+	(*database)[0].Status = Draft
+	if TrainingFingerprint(database, texts) != fp {
+		t.Error("got a new fingerprint after a change the classifier does not use")
+	}
+	texts["a"] = NewDocument("emissions")
+	withText := TrainingFingerprint(database, texts)
+	if withText == fp {
+		t.Error("got the same fingerprint after the text of an article was fetched")
+	}
+	(*database)[0].Topics = []Topic{"Economy"}
+	if TrainingFingerprint(database, texts) == withText {
+		t.Error("got the same fingerprint after an article was tagged differently")
+	}
+}
@@ -0,0 +1,349 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the settings for the app. It is loaded by LoadConfig from an optional JSON file, with environment variables taking precedence, and passed to Configure and the web server at startup.
+type Config struct {
+	// Port is the port the web server listens on.
+	Port string `json:"port"`
+	// SheetID is the ID of the Google Sheet holding the Articles and Questions sheets.
+	SheetID string `json:"sheet_id"`
+	// OldSheetID is the ID of the old Google Sheet, whose feed sheet new articles are also appended to.
+	OldSheetID string `json:"old_sheet_id"`
+	// Credentials is the JSON key of the Google service account used to access Google Sheets.
+	Credentials string `json:"credentials"`
+	// OfflineSheets, if set, is the path to a local JSON file used instead of Google Sheets.
+	OfflineSheets string `json:"offline_sheets"`
+	// OutboxPath is the path to the file that holds changes waiting to be written to Google Sheets.
+	OutboxPath string `json:"outbox_path"`
+	// Admin and Password are the login for the admin dashboard.
+	Admin    string `json:"admin"`
+	Password string `json:"password"`
+	// Curators are the logins of the other curators, as a map of name to password. Curators can do everything the admin can, and are needed to review each other's articles.
+	Curators map[string]string `json:"curators"`
+	// RequireReview, if set, only publishes an article once a curator other than the one who added it has approved it.
+	RequireReview bool `json:"require_review"`
+	// StudentDomains are the domains of the school email addresses students can sign in with, such as "students.edu.sg". Student accounts are turned off if it is empty.
+	StudentDomains []string `json:"student_domains"`
+	// PublicURL is the address students reach the app at, such as "https://gpnewsfeed.example.com", used for the sign-in links emailed to them. It is required for student accounts.
+	PublicURL string `json:"public_url"`
+	// BehindProxy, if set, says the app is reached through the hosting platform's proxy, which adds the visitor's address to the X-Forwarded-For header. Visitors are then told apart by that address, e.g. to limit their suggestions and sign-in attempts. It must not be set if visitors can reach the app directly, as they could then send any address in the header.
+	BehindProxy bool `json:"behind_proxy"`
+	// SessionSecret is the key that signs the sign-in links emailed to students and their sessions. If it is empty, a random key is used, and students have to sign in again whenever the app restarts.
+	SessionSecret string `json:"session_secret"`
+	// LaunchDate is the date the feed went live, in the "Jan 2, 2006" format, used to work out the average number of articles per day.
+	LaunchDate string `json:"launch_date"`
+	// ControlledVocabulary, if set, only allows articles to be tagged with topics already in the Vocabulary. New topics are then added in the topic manager.
+	ControlledVocabulary bool `json:"controlled_vocabulary"`
+	// StaleDays is the number of days without a new article after which a question or topic is flagged as stale in the CoverageReport.
+	StaleDays int            `json:"stale_days"`
+	SMTP      SMTPConfig     `json:"smtp"`
+	Notify    NotifyConfig   `json:"notify"`
+	Snapshots SnapshotConfig `json:"snapshots"`
+}
+
+// SMTPConfig holds the settings of the mail server used to send notifications.
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// NotifyConfig holds the addresses used to email the admins. If To is empty, notifications are written to the log instead.
+type NotifyConfig struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	ReplyTo string `json:"reply_to"`
+}
+
+// SnapshotConfig holds the settings for snapshots of the databases. Interval is how often a scheduled snapshot is taken, as a duration such as "24h". KeepLast and KeepDaily are the RetentionPolicy.
+type SnapshotConfig struct {
+	Dir       string `json:"dir"`
+	Interval  string `json:"interval"`
+	KeepLast  int    `json:"keep_last"`
+	KeepDaily int    `json:"keep_daily"`
+}
+
+// DefaultConfigPath is the config file read by default. Unlike any other path given to LoadConfig, it does not have to exist.
+const DefaultConfigPath = "config.json"
+
+// DefaultConfig returns the settings used for anything not set in the config file or the environment.
+func DefaultConfig() Config {
+	return Config{
+		Port:       "8080",
+		OutboxPath: "outbox.json",
+		LaunchDate: "Jan 15, 2021",
+		StaleDays:  90,
+		Notify: NotifyConfig{
+			From: "admin@njcgpnewsfeed",
+		},
+		Snapshots: SnapshotConfig{
+			Dir:       "snapshots",
+			Interval:  "24h",
+			KeepLast:  20,
+			KeepDaily: 30,
+		},
+	}
+}
+
+// envOverrides maps each environment variable to the setting it overrides.
+func (c *Config) envOverrides() map[string]*string {
+	return map[string]*string{
+		"PORT":              &c.Port,
+		"SHEET_ID":          &c.SheetID,
+		"OLD_SHEET_ID":      &c.OldSheetID,
+		"CREDENTIALS":       &c.Credentials,
+		"OFFLINE_SHEETS":    &c.OfflineSheets,
+		"OUTBOX_PATH":       &c.OutboxPath,
+		"ADMIN":             &c.Admin,
+		"PASSWORD":          &c.Password,
+		"PUBLIC_URL":        &c.PublicURL,
+		"SESSION_SECRET":    &c.SessionSecret,
+		"LAUNCH_DATE":       &c.LaunchDate,
+		"SMTP_HOST":         &c.SMTP.Host,
+		"SMTP_USER":         &c.SMTP.User,
+		"SMTP_PASS":         &c.SMTP.Password,
+		"NOTIFY_FROM":       &c.Notify.From,
+		"NOTIFY_EMAIL":      &c.Notify.To,
+		"NOTIFY_REPLY_TO":   &c.Notify.ReplyTo,
+		"SNAPSHOT_DIR":      &c.Snapshots.Dir,
+		"SNAPSHOT_INTERVAL": &c.Snapshots.Interval,
+	}
+}
+
+// envIntOverrides maps each numeric environment variable to the setting it overrides.
+func (c *Config) envIntOverrides() map[string]*int {
+	return map[string]*int{
+		"STALE_DAYS":          &c.StaleDays,
+		"SMTP_PORT":           &c.SMTP.Port,
+		"SNAPSHOT_KEEP_LAST":  &c.Snapshots.KeepLast,
+		"SNAPSHOT_KEEP_DAILY": &c.Snapshots.KeepDaily,
+	}
+}
+
+// envBoolOverrides maps each true/false environment variable to the setting it overrides.
+func (c *Config) envBoolOverrides() map[string]*bool {
+	return map[string]*bool{
+		"CONTROLLED_VOCABULARY": &c.ControlledVocabulary,
+		"REQUIRE_REVIEW":        &c.RequireReview,
+		"BEHIND_PROXY":          &c.BehindProxy,
+	}
+}
+
+// LoadConfig returns the default settings, overridden by the JSON config file at path if path is not empty (or is DefaultConfigPath and does not exist), and then by any of the environment variables PORT, SHEET_ID, OLD_SHEET_ID, CREDENTIALS, OFFLINE_SHEETS, OUTBOX_PATH, ADMIN, PASSWORD, CURATORS, STUDENT_DOMAINS, PUBLIC_URL, BEHIND_PROXY, SESSION_SECRET, LAUNCH_DATE, CONTROLLED_VOCABULARY, REQUIRE_REVIEW, STALE_DAYS, SMTP_HOST, SMTP_PORT, SMTP_USER, SMTP_PASS, NOTIFY_FROM, NOTIFY_EMAIL, NOTIFY_REPLY_TO, SNAPSHOT_DIR, SNAPSHOT_INTERVAL, SNAPSHOT_KEEP_LAST and SNAPSHOT_KEEP_DAILY that are set. The result is checked with Validate.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		switch {
+		case path == DefaultConfigPath && errors.Is(err, os.ErrNotExist):
+			b = []byte("{}")
+		case err != nil:
+			return cfg, fmt.Errorf("unable to read config file: %w", err)
+		}
+		if err := json.Unmarshal(b, &cfg); err != nil {
+			return cfg, fmt.Errorf("unable to parse config file %s: %w", path, err)
+		}
+	}
+
+	for k, v := range cfg.envOverrides() {
+		if env, ok := os.LookupEnv(k); ok {
+			*v = env
+		}
+	}
+	for k, v := range cfg.envIntOverrides() {
+		if env, ok := os.LookupEnv(k); ok {
+			n, err := strconv.Atoi(env)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid config: %s %q is not a number", k, env)
+			}
+			*v = n
+		}
+	}
+	// CURATORS is a comma-separated list of name:password logins.
+	if env, ok := os.LookupEnv("CURATORS"); ok {
+		cfg.Curators = make(map[string]string)
+		for _, login := range strings.Split(env, ",") {
+			if strings.TrimSpace(login) == "" {
+				continue
+			}
+			parts := strings.SplitN(login, ":", 2)
+			if len(parts) != 2 {
+				return cfg, fmt.Errorf("invalid config: CURATORS %q is not a list of name:password logins", env)
+			}
+			cfg.Curators[strings.TrimSpace(parts[0])] = parts[1]
+		}
+	}
+	// STUDENT_DOMAINS is a comma-separated list of email domains.
+	if env, ok := os.LookupEnv("STUDENT_DOMAINS"); ok {
+		cfg.StudentDomains = nil
+		for _, d := range strings.Split(env, ",") {
+			if d = strings.TrimSpace(d); d != "" {
+				cfg.StudentDomains = append(cfg.StudentDomains, d)
+			}
+		}
+	}
+	for k, v := range cfg.envBoolOverrides() {
+		if env, ok := os.LookupEnv(k); ok {
+			b, err := strconv.ParseBool(env)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid config: %s %q is not true or false", k, env)
+			}
+			*v = b
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// CanLogin reports whether user and password are the login of the admin or of one of the curators.
+func (c Config) CanLogin(user, password string) bool {
+	if user == "" || password == "" {
+		return false
+	}
+	if user == c.Admin {
+		return password == c.Password
+	}
+	p, ok := c.Curators[user]
+	return ok && p == password
+}
+
+// IsStudentEmail reports whether email is at one of the StudentDomains, or a subdomain of one, so that its owner can have a student account.
+func (c Config) IsStudentEmail(email string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 1 || strings.Count(email, "@") != 1 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, d := range c.StudentDomains {
+		d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+		if d != "" && (domain == d || strings.HasSuffix(domain, "."+d)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that every required setting is present and well formed, and returns an error listing every problem found.
+func (c Config) Validate() error {
+	var problems []string
+	require := func(value, name string) {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, name+" is required")
+		}
+	}
+
+	require(c.Port, "port (PORT)")
+	require(c.SheetID, "sheet_id (SHEET_ID)")
+	require(c.OldSheetID, "old_sheet_id (OLD_SHEET_ID)")
+	require(c.Admin, "admin (ADMIN)")
+	require(c.Password, "password (PASSWORD)")
+
+	if _, err := strconv.Atoi(c.Port); c.Port != "" && err != nil {
+		problems = append(problems, fmt.Sprintf("port (PORT) %q is not a number", c.Port))
+	}
+
+	if c.OfflineSheets == "" {
+		if strings.TrimSpace(c.Credentials) == "" {
+			problems = append(problems, "credentials (CREDENTIALS) is required unless offline_sheets (OFFLINE_SHEETS) is set")
+		} else if !json.Valid([]byte(c.Credentials)) {
+			problems = append(problems, "credentials (CREDENTIALS) is not a valid JSON service account key")
+		}
+	}
+
+	if t, err := time.Parse("Jan 2, 2006", c.LaunchDate); err != nil {
+		problems = append(problems, fmt.Sprintf("launch_date (LAUNCH_DATE) %q is not a date like \"Jan 15, 2021\"", c.LaunchDate))
+	} else if t.After(time.Now()) {
+		problems = append(problems, fmt.Sprintf("launch_date (LAUNCH_DATE) %q is in the future", c.LaunchDate))
+	}
+
+	for name, password := range c.Curators {
+		if strings.TrimSpace(name) == "" || password == "" {
+			problems = append(problems, "curators (CURATORS) must each have a name and a password")
+			break
+		}
+	}
+	if c.RequireReview && len(c.Curators) == 0 {
+		problems = append(problems, "require_review (REQUIRE_REVIEW) needs at least one curator (CURATORS) besides the admin, to approve the admin's articles")
+	}
+
+	for _, d := range c.StudentDomains {
+		if d = strings.TrimPrefix(strings.TrimSpace(d), "@"); d == "" || strings.ContainsAny(d, "@ ") || !strings.Contains(d, ".") {
+			problems = append(problems, fmt.Sprintf("student_domains (STUDENT_DOMAINS) %q is not an email domain like \"students.edu.sg\"", d))
+		}
+	}
+	if len(c.StudentDomains) > 0 {
+		require(c.PublicURL, "public_url (PUBLIC_URL) for the sign-in links of student accounts")
+	}
+	if u, err := url.Parse(c.PublicURL); c.PublicURL != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		problems = append(problems, fmt.Sprintf("public_url (PUBLIC_URL) %q is not an address like \"https://gpnewsfeed.example.com\"", c.PublicURL))
+	}
+
+	if c.StaleDays < 1 {
+		problems = append(problems, "stale_days (STALE_DAYS) must be at least 1")
+	}
+
+	if c.Notify.To != "" {
+		require(c.Notify.From, "notify.from (NOTIFY_FROM)")
+		if c.SMTP.Host == "" || c.SMTP.Port == 0 {
+			problems = append(problems, "smtp.host (SMTP_HOST) and smtp.port (SMTP_PORT) are required to send notifications to notify.to (NOTIFY_EMAIL)")
+		}
+	}
+
+	require(c.Snapshots.Dir, "snapshots.dir (SNAPSHOT_DIR)")
+	if d, err := time.ParseDuration(c.Snapshots.Interval); err != nil || d <= 0 {
+		problems = append(problems, fmt.Sprintf("snapshots.interval (SNAPSHOT_INTERVAL) %q is not a duration like \"24h\"", c.Snapshots.Interval))
+	}
+	if c.Snapshots.KeepLast < 1 {
+		problems = append(problems, "snapshots.keep_last (SNAPSHOT_KEEP_LAST) must be at least 1")
+	}
+	if c.Snapshots.KeepDaily < 0 {
+		problems = append(problems, "snapshots.keep_daily (SNAPSHOT_KEEP_DAILY) cannot be negative")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// LaunchTime returns LaunchDate as a time.Time. It returns the zero time if LaunchDate is invalid, which Validate reports.
+func (c Config) LaunchTime() time.Time {
+	t, _ := time.Parse("Jan 2, 2006", c.LaunchDate)
+	return t
+}
+
+// SnapshotInterval returns Snapshots.Interval as a time.Duration. It returns 0 if the interval is invalid, which Validate reports.
+func (c Config) SnapshotInterval() time.Duration {
+	d, _ := time.ParseDuration(c.Snapshots.Interval)
+	return d
+}
+
+// RetentionPolicy returns the RetentionPolicy for snapshots.
+func (c Config) RetentionPolicy() RetentionPolicy {
+	return RetentionPolicy{Last: c.Snapshots.KeepLast, Daily: c.Snapshots.KeepDaily}
+}
+
+var config = DefaultConfig()
+
+// Configure sets the config used by the db package, e.g. the IDs of the Google Sheets and the mail server settings. It is not safe to call Configure while other db functions are running.
+func Configure(cfg Config) {
+	config = cfg
+}
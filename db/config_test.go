@@ -0,0 +1,83 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setEnv sets the environment variables for the duration of the test.
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+
+	for k, v := range env {
+		k := k
+		old, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(k, old)
+				return
+			}
+			os.Unsetenv(k)
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	file := `{"sheet_id": "from-file", "old_sheet_id": "old", "offline_sheets": "sheets.json", "admin": "admin", "password": "file", "smtp": {"host": "smtp.example.com", "port": 587}}`
+	if err := ioutil.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setEnv(t, map[string]string{"PASSWORD": "from-env", "SMTP_PORT": "465", "CONTROLLED_VOCABULARY": "true", "CURATORS": "mary:m1, john:j:2", "REQUIRE_REVIEW": "true", "STUDENT_DOMAINS": "students.example.edu, @Example.org", "PUBLIC_URL": "https://gp.example.com", "BEHIND_PROXY": "true"})
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.SheetID != "from-file" || cfg.SMTP.Host != "smtp.example.com" {
+		t.Errorf("settings from the file not loaded: %+v", cfg)
+	}
+	if cfg.Password != "from-env" || cfg.SMTP.Port != 465 || !cfg.ControlledVocabulary {
+		t.Errorf("environment did not override the file: %+v", cfg)
+	}
+	if !cfg.BehindProxy {
+		t.Errorf("behind_proxy not loaded from the environment: %+v", cfg)
+	}
+	if !cfg.RequireReview || !cfg.CanLogin("mary", "m1") || !cfg.CanLogin("john", "j:2") || cfg.CanLogin("mary", "from-env") || !cfg.CanLogin("admin", "from-env") {
+		t.Errorf("curator logins not loaded from the environment: %+v", cfg.Curators)
+	}
+	if !cfg.IsStudentEmail(" Ann@Students.Example.edu") || !cfg.IsStudentEmail("bo@year1.example.org") || cfg.IsStudentEmail("eve@example.edu") || cfg.IsStudentEmail("eve@notexample.org") {
+		t.Errorf("student email domains not loaded from the environment: %v", cfg.StudentDomains)
+	}
+	if cfg.Port != "8080" || cfg.LaunchDate != "Jan 15, 2021" {
+		t.Errorf("defaults not applied: %+v", cfg)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SheetID = "sheet"
+	cfg.Credentials = "not json"
+	cfg.LaunchDate = "15 Jan 2021"
+	cfg.Notify.To = "admin@example.com"
+	cfg.RequireReview = true
+	cfg.StudentDomains = []string{"students@school"}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected an invalid config")
+	}
+	for _, want := range []string{"OLD_SHEET_ID", "ADMIN", "PASSWORD", "CREDENTIALS", "LAUNCH_DATE", "SMTP_HOST", "REQUIRE_REVIEW", "STUDENT_DOMAINS", "PUBLIC_URL"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s: %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "sheet_id (SHEET_ID)") {
+		t.Errorf("error mentions a setting that is set: %v", err)
+	}
+}
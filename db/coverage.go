@@ -0,0 +1,153 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of CoverageRow.
+const (
+	CoverageQuestion = "question"
+	CoverageTopic    = "topic"
+)
+
+// CoverageRow is the coverage of a past year question or topic: the number of articles tagged with it, and the most recent of them. Name is the question's Label or the topic, and Key the question's Tag or the topic, for searching.
+type CoverageRow struct {
+	Kind     string
+	Name     string
+	Key      string
+	Wording  string
+	Articles int
+	// Latest is the date of the most recent article tagged with the question or topic, and is zero if there is none.
+	Latest time.Time
+	// Stale is set if no article tagged with the question or topic was published in the StaleDays before the report was made.
+	Stale bool
+}
+
+// LatestDate returns Latest in the "Jan 2, 2006" format, or an empty string if no article is tagged with the question or topic.
+func (row CoverageRow) LatestDate() string {
+	if row.Latest.IsZero() {
+		return ""
+	}
+	return row.Latest.Format("Jan 2, 2006")
+}
+
+// CoverageReport lists every past year question and topic with its coverage, so that the admins can see which ones have few or no recent articles and plan what to curate next.
+type CoverageReport struct {
+	Made      time.Time
+	StaleDays int
+	Rows      []CoverageRow
+}
+
+// NewCoverageReport returns the CoverageReport of every question in qnDB and every topic tagged on an article in the database or placed in tt, as of now. Rows are sorted with the questions first, and then by date of the most recent article, oldest first, so that the biggest gaps come first.
+func NewCoverageReport(database *ArticlesDBByDate, qnDB QuestionsDB, tt TopicTree, now time.Time, staleDays int) *CoverageReport {
+	rep := &CoverageReport{Made: now, StaleDays: staleDays}
+
+	questions := make(map[string]*CoverageRow, len(qnDB))
+	for key, qn := range qnDB {
+		questions[key] = &CoverageRow{Kind: CoverageQuestion, Name: qn.Label(), Key: qn.Tag(), Wording: qn.Wording}
+	}
+	topics := make(map[Topic]*CoverageRow, len(tt))
+	for t := range tt {
+		topics[t] = &CoverageRow{Kind: CoverageTopic, Name: string(t), Key: string(t)}
+	}
+
+	for _, a := range *database {
+		date := time.Unix(a.Date, 0)
+		for _, qn := range a.Questions {
+			if row, ok := questions[qn.Key()]; ok {
+				row.add(date)
+			}
+		}
+		for _, t := range a.Topics {
+			row, ok := topics[t]
+			if !ok {
+				row = &CoverageRow{Kind: CoverageTopic, Name: string(t), Key: string(t)}
+				topics[t] = row
+			}
+			row.add(date)
+		}
+	}
+
+	cutoff := now.AddDate(0, 0, -staleDays)
+	for _, row := range questions {
+		rep.Rows = append(rep.Rows, *row)
+	}
+	for _, row := range topics {
+		rep.Rows = append(rep.Rows, *row)
+	}
+	for i := range rep.Rows {
+		rep.Rows[i].Stale = rep.Rows[i].Latest.Before(cutoff)
+	}
+
+	sort.Slice(rep.Rows, func(i, j int) bool {
+		a, b := rep.Rows[i], rep.Rows[j]
+		switch {
+		case a.Kind != b.Kind:
+			return a.Kind == CoverageQuestion
+		case !a.Latest.Equal(b.Latest):
+			return a.Latest.Before(b.Latest)
+		case a.Articles != b.Articles:
+			return a.Articles < b.Articles
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+	return rep
+}
+
+// add counts an article published on date.
+func (row *CoverageRow) add(date time.Time) {
+	row.Articles++
+	if date.After(row.Latest) {
+		row.Latest = date
+	}
+}
+
+// Filter returns a copy of the report with only the rows of the given kind, or of every kind if kind is empty, and only the stale rows if staleOnly is set.
+func (rep *CoverageReport) Filter(kind string, staleOnly bool) *CoverageReport {
+	filtered := &CoverageReport{Made: rep.Made, StaleDays: rep.StaleDays}
+	for _, row := range rep.Rows {
+		if (kind == "" || row.Kind == kind) && (!staleOnly || row.Stale) {
+			filtered.Rows = append(filtered.Rows, row)
+		}
+	}
+	return filtered
+}
+
+// Stale returns the number of stale questions and topics in the report.
+func (rep *CoverageReport) Stale() (questions, topics int) {
+	for _, row := range rep.Rows {
+		switch {
+		case !row.Stale:
+		case row.Kind == CoverageQuestion:
+			questions++
+		default:
+			topics++
+		}
+	}
+	return questions, topics
+}
+
+// WriteCSV writes the report to w as CSV, with a header row.
+func (rep *CoverageReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"kind", "name", "wording", "articles", "latest article", "stale"}); err != nil {
+		return fmt.Errorf("unable to write CSV header: %w", err)
+	}
+
+	for _, row := range rep.Rows {
+		record := []string{row.Kind, row.Name, row.Wording, strconv.Itoa(row.Articles), row.LatestDate(), strconv.FormatBool(row.Stale)}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("unable to write CSV record: %w", err)
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCoverageReport(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	qnDB := QuestionsDB{
+		"2019 6": {Year: "2019", Number: "6", Wording: "How far is science a force for good?"},
+		"2020 1": {Year: "2020", Number: "1", Wording: "Is censorship ever justified?"},
+	}
+	database := &ArticlesDBByDate{
+		{Title: "recent", Topics: []Topic{"Science"}, Questions: []Question{qnDB["2019 6"]}, Date: now.AddDate(0, 0, -10).Unix()},
+		{Title: "old", Topics: []Topic{"Science", "Media"}, Questions: []Question{qnDB["2019 6"]}, Date: now.AddDate(-1, 0, 0).Unix()},
+	}
+
+	rep := NewCoverageReport(database, qnDB, TopicTree{"Arts": ""}, now, 90)
+
+	var got []string
+	for _, row := range rep.Rows {
+		got = append(got, fmt.Sprintf("%s %d %v", row.Name, row.Articles, row.Stale))
+	}
+	want := []string{"2020 - Q1 0 true", "2019 - Q6 2 false", "Arts 0 true", "Media 1 true", "Science 2 false"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+	if q, tp := rep.Stale(); q != 1 || tp != 2 {
+		t.Errorf("got %d stale questions and %d stale topics, want 1 and 2", q, tp)
+	}
+	if n := len(rep.Filter(CoverageTopic, true).Rows); n != 2 {
+		t.Errorf("got %d stale topics after filtering, want 2", n)
+	}
+
+	var b bytes.Buffer
+	if err := rep.WriteCSV(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `question,2019 - Q6,How far is science a force for good?,2,"May 22, 2022",false`) {
+		t.Errorf("CSV missing the 2019 Q6 row:\n%s", b.String())
+	}
+}
@@ -0,0 +1,198 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// NewRecord flattens an Article into a Record in the Articles sheet column layout.
+func NewRecord(a Article) Record {
+	rec := Record{
+		Title:      a.Title,
+		URL:        a.URL,
+		Topics:     make([]string, 0, len(a.Topics)),
+		Questions:  make([]string, 0, len(a.Questions)),
+		Wording:    make([]string, 0, len(a.Questions)),
+		Date:       a.DisplayDate,
+		Summary:    a.Summary,
+		Note:       a.Note,
+		Prompts:    a.Prompts,
+		Status:     recordStatus(a.Status),
+		AddedBy:    a.AddedBy,
+		ReviewedBy: a.ReviewedBy,
+		PublishAt:  a.PublishTime(),
+	}
+
+	for _, t := range a.Topics {
+		rec.Topics = append(rec.Topics, string(t))
+	}
+
+	for _, q := range a.Questions {
+		rec.Questions = append(rec.Questions, q.Key())
+		rec.Wording = append(rec.Wording, q.Key()+" "+q.Wording)
+	}
+
+	return rec
+}
+
+// Cells returns the Record as a row of cells, with multiple topics, questions or discussion prompts in a cell separated by new lines. The optional columns after the date, from the summary to the publish time, are left out when they are empty, so that published articles without notes keep the original six columns.
+func (rec Record) Cells() []string {
+	cells := []string{
+		rec.Title,
+		rec.URL,
+		strings.Join(rec.Topics, "\n"),
+		strings.Join(rec.Questions, "\n"),
+		strings.Join(rec.Wording, "\n"),
+		rec.Date,
+		rec.Summary,
+		rec.Note,
+		strings.Join(rec.Prompts, "\n"),
+		rec.Status,
+		rec.AddedBy,
+		rec.ReviewedBy,
+		rec.PublishAt,
+	}
+	for len(cells) > 6 && cells[len(cells)-1] == "" {
+		cells = cells[:len(cells)-1]
+	}
+	return cells
+}
+
+// Row returns the Record as a row of values to be written to the Articles sheet.
+func (rec Record) Row() []interface{} {
+	cells := rec.Cells()
+	row := make([]interface{}, 0, len(cells))
+	for _, c := range cells {
+		row = append(row, c)
+	}
+	return row
+}
+
+// FilterArticles returns the articles in the database that match all of the given filters. term is run through Search, topic is run through SearchTopic with tt so that it includes the articles tagged with its subtopics, and question is a question key or tag such as "2019 6", "2019-Q6" or "NJC-Prelim-2022-Q3". Empty filters are ignored.
+func FilterArticles(database *ArticlesDBByDate, tt TopicTree, term, topic, question string) (*ArticlesDBByDate, error) {
+	results := database
+	if strings.TrimSpace(term) != "" {
+		results = Search(strings.TrimSpace(term), results)
+	}
+	if strings.TrimSpace(topic) != "" {
+		results = SearchTopic(Topic(strings.TrimSpace(topic)), tt, results)
+	}
+
+	var key string
+	if strings.TrimSpace(question) != "" {
+		qn, err := ParseQuestion(question)
+		if err != nil {
+			return nil, err
+		}
+		key = qn.Key()
+	}
+
+	filtered := NewArticlesDBByDate()
+	for _, a := range *results {
+		if key != "" && !hasQuestion(a, key) {
+			continue
+		}
+		*filtered = append(*filtered, a)
+	}
+	return filtered, nil
+}
+
+func hasQuestion(a Article, key string) bool {
+	for _, q := range a.Questions {
+		if strings.EqualFold(q.Key(), key) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportCSV writes the articles to w as CSV in the Articles sheet column layout, with a header row. The output can be read back in with ImportArticles.
+func ExportCSV(w io.Writer, database *ArticlesDBByDate) error {
+	cw := csv.NewWriter(w)
+	header := []string{"title", "url", "topics", "question keys", "questions", "date", "summary", "teacher note", "discussion prompts", "status", "added by", "reviewed by", "publish at"}
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("unable to write CSV header: %w", err)
+	}
+
+	for _, a := range *database {
+		record := NewRecord(a).Cells()
+		for len(record) < len(header) {
+			record = append(record, "")
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("unable to write CSV record: %w", err)
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportJSON writes the articles to w as a JSON array of Record objects. The output can be read back in with ImportArticles.
+func ExportJSON(w io.Writer, database *ArticlesDBByDate) error {
+	records := make([]Record, 0, len(*database))
+	for _, a := range *database {
+		records = append(records, NewRecord(a))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(records); err != nil {
+		return fmt.Errorf("unable to write JSON: %w", err)
+	}
+	return nil
+}
+
+// ExportMarkdown writes the articles to w as a numbered Markdown reading list under the given heading, listing each article's link, date, summary, topics, past year questions, teacher's note and discussion prompts.
+func ExportMarkdown(w io.Writer, database *ArticlesDBByDate, heading string) error {
+	var b strings.Builder
+
+	if heading != "" {
+		fmt.Fprintf(&b, "# %s\n\n", heading)
+	}
+
+	for i, a := range *database {
+		fmt.Fprintf(&b, "%d. [%s](%s) (%s)\n", i+1, markdownEscaper.Replace(a.Title), a.URL, a.DisplayDate)
+
+		if a.Summary != "" {
+			fmt.Fprintf(&b, "    - Summary: %s\n", markdownLine(a.Summary))
+		}
+
+		if len(a.Topics) > 0 {
+			topics := make([]string, 0, len(a.Topics))
+			for _, t := range a.Topics {
+				topics = append(topics, string(t))
+			}
+			fmt.Fprintf(&b, "    - Topics: %s\n", strings.Join(topics, ", "))
+		}
+
+		for _, q := range a.Questions {
+			fmt.Fprintf(&b, "    - %s: %s\n", q.Label(), q.Wording)
+		}
+
+		if a.Note != "" {
+			fmt.Fprintf(&b, "    - Why this matters for GP: %s\n", markdownLine(a.Note))
+		}
+
+		for _, p := range a.Prompts {
+			fmt.Fprintf(&b, "    - Discuss: %s\n", markdownLine(p))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	if err != nil {
+		return fmt.Errorf("unable to write Markdown: %w", err)
+	}
+	return nil
+}
+
+var markdownEscaper = strings.NewReplacer(`[`, `\[`, `]`, `\]`)
+
+// markdownLine joins the lines of s into one, so that a summary or note written over several lines stays in its list item.
+func markdownLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// exportTestArticles returns articles using every column of the Articles sheet, parsed as if they had been imported.
+func exportTestArticles(t *testing.T) *ArticlesDBByDate {
+	t.Helper()
+	records := []Record{
+		{Title: "Carbon tax to rise", URL: "https://example.com/tax", Topics: []string{"Environment", "Economy"}, Questions: []string{"2019 6"}, Date: "Mar 1, 2021"},
+		{Title: "Is [satire] *news*?", URL: "https://example.com/satire", Topics: []string{"Media"}, Questions: []string{"2019 6", "2020 1"}, Date: "Mar 2, 2021",
+			Summary: "A look at satire, with commas, \"quotes\" and\nnew lines.", Note: "Useful for media questions.", Prompts: []string{"Is satire news?", "Who decides?"},
+			Status: "draft", AddedBy: "Mary"},
+		{Title: "Budget boosts healthcare", URL: "https://example.com/budget", Topics: []string{"Economy"}, Date: "Mar 3, 2021",
+			Status: "scheduled", AddedBy: "Mary", ReviewedBy: "John", PublishAt: "Mar 7, 2031 07:30"},
+	}
+
+	database := NewArticlesDBByDate()
+	for _, rec := range records {
+		a, err := ParseArticle(rec, testQuestionsDB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.AddArticleToDB(database, InitTopicsMap(), InitQuestionCounter())
+	}
+	return database
+}
+
+// importAll imports every article written by an export, failing the test if any row is rejected.
+func importAll(t *testing.T, data []byte, format string) *ArticlesDBByDate {
+	t.Helper()
+	report, err := ImportArticles(bytes.NewReader(data), format, NewArticlesDBByDate(), testQuestionsDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range report.Results {
+		if res.Err != nil {
+			t.Errorf("row %d of the %s export not imported: %v", res.Row, format, res.Err)
+		}
+	}
+	database := NewArticlesDBByDate()
+	report.Commit(database, InitTopicsMap(), InitQuestionCounter())
+	return database
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	database := exportTestArticles(t)
+	want := make([]Record, 0, database.Len())
+	for _, a := range *database {
+		want = append(want, NewRecord(a))
+	}
+
+	exports := []struct {
+		format string
+		write  func(*bytes.Buffer) error
+	}{
+		{FormatCSV, func(b *bytes.Buffer) error { return ExportCSV(b, database) }},
+		{FormatJSON, func(b *bytes.Buffer) error { return ExportJSON(b, database) }},
+	}
+	for _, e := range exports {
+		var b bytes.Buffer
+		if err := e.write(&b); err != nil {
+			t.Fatal(err)
+		}
+
+		imported := importAll(t, b.Bytes(), e.format)
+		got := make([]Record, 0, imported.Len())
+		for _, a := range *imported {
+			got = append(got, NewRecord(a))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s export did not round trip:\ngot  %+v\nwant %+v", e.format, got, want)
+		}
+	}
+}
+
+func TestExportMarkdown(t *testing.T) {
+	database := exportTestArticles(t)
+
+	var b bytes.Buffer
+	if err := ExportMarkdown(&b, database, "Reading for 2019 Q6"); err != nil {
+		t.Fatal(err)
+	}
+	md := b.String()
+
+	for _, want := range []string{
+		"# Reading for 2019 Q6\n\n",
+		"1. [Budget boosts healthcare](https://example.com/budget) (Mar 3, 2021)\n",
+		`2. [Is \[satire\] *news*?](https://example.com/satire) (Mar 2, 2021)`,
+		"    - Summary: A look at satire, with commas, \"quotes\" and new lines.\n",
+		"    - Topics: Environment, Economy\n",
+		"    - 2020 - Q1: Is censorship ever justified?\n",
+		"    - Why this matters for GP: Useful for media questions.\n",
+		"    - Discuss: Who decides?\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown does not contain %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestFilterArticles(t *testing.T) {
+	database := exportTestArticles(t)
+	tree := TopicTree{"Economy": "Society", "Society": ""}
+
+	tests := []struct {
+		term, topic, question string
+		want                  []string
+	}{
+		{"", "", "", []string{"https://example.com/budget", "https://example.com/satire", "https://example.com/tax"}},
+		{"", "economy", "", []string{"https://example.com/budget", "https://example.com/tax"}},
+		{"", "", "2019-Q6", []string{"https://example.com/satire", "https://example.com/tax"}},
+		{"", "Economy", "2019 6", []string{"https://example.com/tax"}},
+		// a topic includes the articles tagged with its subtopics, as in SearchTopic.
+		{"", "society", "", []string{"https://example.com/budget", "https://example.com/tax"}},
+		{"tax", "Society", "", []string{"https://example.com/tax"}},
+		{"satire", "", "", []string{"https://example.com/satire"}},
+	}
+	for _, tt := range tests {
+		results, err := FilterArticles(database, tree, tt.term, tt.topic, tt.question)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, 0, results.Len())
+		for _, a := range *results {
+			got = append(got, a.URL)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("term %q, topic %q, question %q: got %v, want %v", tt.term, tt.topic, tt.question, got, tt.want)
+		}
+	}
+
+	if _, err := FilterArticles(database, tree, "", "", "not a question"); err == nil {
+		t.Error("expected an error for a question that is not a key")
+	}
+}
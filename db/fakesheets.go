@@ -0,0 +1,170 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// FakeSheets is an in-process stand-in for Google Sheets that implements SheetsClient, for use in tests and offline development. Spreadsheets are held in memory as a map of spreadsheet ID to sheet name to rows. Like the real API, values are read back as strings with trailing empty cells and rows trimmed, and an update only overwrites the cells it covers.
+type FakeSheets struct {
+	mu     sync.Mutex
+	path   string
+	sheets map[string]map[string][][]interface{}
+}
+
+// NewFakeSheets returns an empty FakeSheets.
+func NewFakeSheets() *FakeSheets {
+	return &FakeSheets{sheets: make(map[string]map[string][][]interface{})}
+}
+
+// LoadFakeSheets returns a FakeSheets backed by the JSON file at path, in the form {"spreadsheet ID": {"sheet name": [[cell, ...], ...]}}. The file is created on the first write if it does not exist, and every Update and Append is saved back to it.
+func LoadFakeSheets(path string) (*FakeSheets, error) {
+	f := NewFakeSheets()
+	f.path = path
+
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read fake sheets file: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &f.sheets); err != nil {
+		return nil, fmt.Errorf("unable to parse fake sheets file %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// SetValues replaces the contents of a sheet.
+func (f *FakeSheets) SetValues(spreadsheetID, sheet string, values [][]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sheet(spreadsheetID, sheet)
+	f.sheets[spreadsheetID][sheet] = copyValues(values)
+}
+
+// Values returns the contents of a sheet as they would be returned by Get.
+func (f *FakeSheets) Values(spreadsheetID, sheet string) [][]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return trimValues(f.sheet(spreadsheetID, sheet))
+}
+
+// Get implements SheetsClient.
+func (f *FakeSheets) Get(ctx context.Context, spreadsheetID, readRange string) ([][]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.sheets[spreadsheetID]; !ok {
+		return nil, fmt.Errorf("requested entity was not found: spreadsheet %q", spreadsheetID)
+	}
+	return trimValues(f.sheet(spreadsheetID, sheetName(readRange))), nil
+}
+
+// Update implements SheetsClient.
+func (f *FakeSheets) Update(ctx context.Context, spreadsheetID, writeRange string, values [][]interface{}, inputOption string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := sheetName(writeRange)
+	rows := f.sheet(spreadsheetID, name)
+	for i, row := range values {
+		if i == len(rows) {
+			rows = append(rows, nil)
+		}
+		for j, v := range row {
+			if j == len(rows[i]) {
+				rows[i] = append(rows[i], "")
+			}
+			rows[i][j] = fmt.Sprint(v)
+		}
+	}
+	f.sheets[spreadsheetID][name] = rows
+
+	return f.save()
+}
+
+// Append implements SheetsClient.
+func (f *FakeSheets) Append(ctx context.Context, spreadsheetID, appendRange string, values [][]interface{}, inputOption string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := sheetName(appendRange)
+	rows := trimValues(f.sheet(spreadsheetID, name))
+	f.sheets[spreadsheetID][name] = append(rows, copyValues(values)...)
+
+	return f.save()
+}
+
+// sheet returns the rows of a sheet, creating the spreadsheet and sheet if necessary. The caller must hold f.mu.
+func (f *FakeSheets) sheet(spreadsheetID, name string) [][]interface{} {
+	if _, ok := f.sheets[spreadsheetID]; !ok {
+		f.sheets[spreadsheetID] = make(map[string][][]interface{})
+	}
+	return f.sheets[spreadsheetID][name]
+}
+
+// save writes the sheets to the backing file, if there is one. The caller must hold f.mu.
+func (f *FakeSheets) save() error {
+	if f.path == "" {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(f.sheets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode fake sheets: %w", err)
+	}
+	if err := ioutil.WriteFile(f.path, b, 0644); err != nil {
+		return fmt.Errorf("unable to save fake sheets file: %w", err)
+	}
+	return nil
+}
+
+// sheetName returns the sheet name of an A1 range such as "Articles!A1:F".
+func sheetName(a1Range string) string {
+	if i := strings.Index(a1Range, "!"); i >= 0 {
+		a1Range = a1Range[:i]
+	}
+	return strings.Trim(a1Range, "'")
+}
+
+// copyValues returns a copy of values with every cell converted to a string.
+func copyValues(values [][]interface{}) [][]interface{} {
+	out := make([][]interface{}, 0, len(values))
+	for _, row := range values {
+		r := make([]interface{}, 0, len(row))
+		for _, v := range row {
+			r = append(r, fmt.Sprint(v))
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// trimValues returns a copy of values without trailing empty cells in each row or trailing empty rows.
+func trimValues(values [][]interface{}) [][]interface{} {
+	out := copyValues(values)
+	for i, row := range out {
+		n := len(row)
+		for n > 0 && row[n-1] == "" {
+			n--
+		}
+		out[i] = row[:n]
+	}
+
+	n := len(out)
+	for n > 0 && len(out[n-1]) == 0 {
+		n--
+	}
+	return out[:n]
+}
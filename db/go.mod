@@ -4,6 +4,8 @@ go 1.16
 
 require (
 	github.com/go-test/deep v1.0.8 // indirect
+	github.com/jung-kurt/gofpdf v1.16.2
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/xhit/go-simple-mail/v2 v2.12.0
 	google.golang.org/api v0.48.0
 )
@@ -0,0 +1,235 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"sort"
+	"strings"
+)
+
+// GraphNode is a topic or past year question in a TopicGraph. ID is the topic, or the question's Tag, and Cluster numbers the group of closely related nodes it belongs to, starting from 0 for the largest group.
+type GraphNode struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	Label    string `json:"label"`
+	Articles int    `json:"articles"`
+	Cluster  int    `json:"cluster"`
+}
+
+// GraphEdge links two nodes of a TopicGraph that are tagged on the same articles. Weight is the number of articles tagged with both.
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Weight int    `json:"weight"`
+}
+
+// TopicGraph is the co-occurrence graph of the topics and past year questions tagged on articles. Its edges are the non-zero entries of the co-occurrence matrix, so that students can see how issues connect.
+type TopicGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphOptions selects the part of the co-occurrence graph returned by NewTopicGraph. Questions adds the past year questions to the topics. MinWeight leaves out edges between nodes tagged together on fewer articles. MaxNodes keeps only the nodes tagged on the most articles, if it is more than 0. Focus, if set, keeps only that topic or question and the nodes linked to it, of which MaxNodes keeps the focus and the ones tagged on the most articles.
+type GraphOptions struct {
+	Questions bool
+	MinWeight int
+	MaxNodes  int
+	Focus     string
+}
+
+// NewTopicGraph returns the co-occurrence graph of the articles in the database, with the nodes most tagged first and the heaviest edges first.
+func NewTopicGraph(database *ArticlesDBByDate, opts GraphOptions) *TopicGraph {
+	nodes := make(map[string]*GraphNode)
+	weights := make(map[[2]string]int)
+
+	for _, a := range *database {
+		ids := make([]string, 0, len(a.Topics)+len(a.Questions))
+		seen := make(map[string]bool)
+		tag := func(id, kind, label string) {
+			if seen[id] {
+				return
+			}
+			seen[id] = true
+			ids = append(ids, id)
+			if nodes[id] == nil {
+				nodes[id] = &GraphNode{ID: id, Kind: kind, Label: label}
+			}
+			nodes[id].Articles++
+		}
+		for _, t := range a.Topics {
+			tag(string(t), CoverageTopic, string(t))
+		}
+		if opts.Questions {
+			for _, qn := range a.Questions {
+				tag(qn.Tag(), CoverageQuestion, qn.Label())
+			}
+		}
+
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				weights[edgeKey(ids[i], ids[j])]++
+			}
+		}
+	}
+
+	// with a focus, the nodes are picked from those linked to it, so that a rarely tagged focus is not left out for the most tagged nodes.
+	candidates := nodes
+	if opts.Focus != "" {
+		candidates = make(map[string]*GraphNode)
+		for k, w := range weights {
+			if w >= opts.MinWeight && (strings.EqualFold(k[0], opts.Focus) || strings.EqualFold(k[1], opts.Focus)) {
+				candidates[k[0]], candidates[k[1]] = nodes[k[0]], nodes[k[1]]
+			}
+		}
+	}
+	keep := make(map[string]bool, len(candidates))
+	for id := range candidates {
+		keep[id] = true
+	}
+	if opts.MaxNodes > 0 && len(candidates) > opts.MaxNodes {
+		for _, n := range sortedNodes(candidates)[opts.MaxNodes:] {
+			keep[n.ID] = strings.EqualFold(n.ID, opts.Focus)
+		}
+	}
+
+	g := &TopicGraph{}
+	for k, w := range weights {
+		if w < opts.MinWeight || !keep[k[0]] || !keep[k[1]] {
+			continue
+		}
+		if opts.Focus != "" && !strings.EqualFold(k[0], opts.Focus) && !strings.EqualFold(k[1], opts.Focus) {
+			continue
+		}
+		g.Edges = append(g.Edges, GraphEdge{k[0], k[1], w})
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.Target < b.Target
+	})
+
+	// only nodes with an edge are shown, apart from the focus, which is shown even if nothing is linked to it.
+	linked := make(map[string]bool)
+	for _, e := range g.Edges {
+		linked[e.Source], linked[e.Target] = true, true
+	}
+	for _, n := range sortedNodes(nodes) {
+		if linked[n.ID] || (opts.Focus != "" && strings.EqualFold(n.ID, opts.Focus)) {
+			g.Nodes = append(g.Nodes, *n)
+		}
+	}
+
+	g.cluster()
+	return g
+}
+
+// edgeKey returns the key of the edge between a and b, which is the same whichever way round they are given.
+func edgeKey(a, b string) [2]string {
+	if b < a {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// sortedNodes returns the nodes tagged on the most articles first.
+func sortedNodes(nodes map[string]*GraphNode) []*GraphNode {
+	sorted := make([]*GraphNode, 0, len(nodes))
+	for _, n := range nodes {
+		sorted = append(sorted, n)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Articles != sorted[j].Articles {
+			return sorted[i].Articles > sorted[j].Articles
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
+
+// cluster groups the nodes of the graph into clusters of nodes that are often tagged together, by weighted label propagation: each node repeatedly joins the cluster it shares the most articles with, until no node moves. Clusters are then numbered from the largest.
+func (g *TopicGraph) cluster() {
+	index := make(map[string]int, len(g.Nodes))
+	for i, n := range g.Nodes {
+		index[n.ID] = i
+	}
+	neighbours := make([]map[int]int, len(g.Nodes))
+	for i := range neighbours {
+		neighbours[i] = make(map[int]int)
+	}
+	for _, e := range g.Edges {
+		s, t := index[e.Source], index[e.Target]
+		neighbours[s][t] += e.Weight
+		neighbours[t][s] += e.Weight
+	}
+
+	labels := make([]int, len(g.Nodes))
+	for i := range labels {
+		labels[i] = i
+	}
+	for round := 0; round < 20; round++ {
+		var moved bool
+		for i := range g.Nodes {
+			score := make(map[int]int)
+			for j, w := range neighbours[i] {
+				score[labels[j]] += w
+			}
+			best, bestScore := labels[i], score[labels[i]]
+			for l, sc := range score {
+				if sc > bestScore || (sc == bestScore && l < best) {
+					best, bestScore = l, sc
+				}
+			}
+			if best != labels[i] {
+				labels[i], moved = best, true
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+
+	size := make(map[int]int)
+	for _, l := range labels {
+		size[l]++
+	}
+	order := make([]int, 0, len(size))
+	for l := range size {
+		order = append(order, l)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		if size[order[i]] != size[order[j]] {
+			return size[order[i]] > size[order[j]]
+		}
+		return order[i] < order[j]
+	})
+	number := make(map[int]int, len(order))
+	for i, l := range order {
+		number[l] = i
+	}
+	for i := range g.Nodes {
+		g.Nodes[i].Cluster = number[labels[i]]
+	}
+}
+
+// Clusters returns the labels of the nodes in each cluster with more than one node, largest cluster first, such as ["Technology", "Ethics", "Privacy"].
+func (g *TopicGraph) Clusters() [][]string {
+	var clusters [][]string
+	for _, n := range g.Nodes {
+		for len(clusters) <= n.Cluster {
+			clusters = append(clusters, nil)
+		}
+		clusters[n.Cluster] = append(clusters[n.Cluster], n.Label)
+	}
+
+	multi := clusters[:0]
+	for _, c := range clusters {
+		if len(c) > 1 {
+			multi = append(multi, c)
+		}
+	}
+	return multi
+}
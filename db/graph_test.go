@@ -0,0 +1,55 @@
+package db
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestTopicGraph(t *testing.T) {
+	qn := Question{Year: "2019", Number: "6", Wording: "Is technology making us less human?"}
+	database := &ArticlesDBByDate{
+		{Topics: []Topic{"Technology", "Ethics", "Privacy"}, Questions: []Question{qn}},
+		{Topics: []Topic{"Technology", "Ethics", "Privacy"}},
+		{Topics: []Topic{"Technology", "Privacy"}},
+		{Topics: []Topic{"Environment", "Climate Change"}},
+		{Topics: []Topic{"Environment", "Climate Change"}},
+		{Topics: []Topic{"Arts"}},
+	}
+
+	g := NewTopicGraph(database, GraphOptions{MinWeight: 1})
+	if len(g.Nodes) != 5 {
+		t.Errorf("got %d nodes, want 5 without the unlinked Arts topic: %+v", len(g.Nodes), g.Nodes)
+	}
+	if want := (GraphEdge{"Privacy", "Technology", 3}); g.Edges[0] != want {
+		t.Errorf("got heaviest edge %+v, want %+v", g.Edges[0], want)
+	}
+
+	clusters := g.Clusters()
+	for _, c := range clusters {
+		sort.Strings(c)
+	}
+	if want := [][]string{{"Ethics", "Privacy", "Technology"}, {"Climate Change", "Environment"}}; !reflect.DeepEqual(clusters, want) {
+		t.Errorf("got clusters %v, want %v", clusters, want)
+	}
+
+	g = NewTopicGraph(database, GraphOptions{Questions: true, MinWeight: 1, Focus: "2019-Q6"})
+	if len(g.Edges) != 3 || len(g.Nodes) != 4 {
+		t.Errorf("got %d edges and %d nodes around 2019-Q6, want 3 and 4", len(g.Edges), len(g.Nodes))
+	}
+
+	g = NewTopicGraph(database, GraphOptions{Questions: true, MinWeight: 1, MaxNodes: 2, Focus: "2019-Q6"})
+	if len(g.Nodes) != 3 || len(g.Edges) != 2 {
+		t.Errorf("got nodes %+v, want 2019-Q6 with the 2 most tagged topics linked to it", g.Nodes)
+	}
+
+	g = NewTopicGraph(database, GraphOptions{MinWeight: 3})
+	if len(g.Edges) != 1 || len(g.Nodes) != 2 {
+		t.Errorf("got edges %+v, want only the Privacy and Technology edge", g.Edges)
+	}
+
+	g = NewTopicGraph(database, GraphOptions{MinWeight: 1, MaxNodes: 2})
+	if len(g.Nodes) != 2 || g.Nodes[0].ID != "Privacy" || g.Nodes[1].ID != "Technology" {
+		t.Errorf("got nodes %+v, want the 2 most tagged topics", g.Nodes)
+	}
+}
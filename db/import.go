@@ -0,0 +1,305 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMissingField is returned when an article is missing its title, URL, date or tags.
+	ErrMissingField = errors.New("empty field(s)")
+	// ErrInvalidDate is returned when an article's date is not in the "Jan 2, 2006" format.
+	ErrInvalidDate = errors.New("invalid date")
+	// ErrInvalidQuestion is returned when a question key cannot be parsed into a year, question number, and optional paper and source.
+	ErrInvalidQuestion = errors.New("invalid question key")
+	// ErrUnknownQuestion is returned when an article is tagged with a question that is not in the questions database.
+	ErrUnknownQuestion = errors.New("question does not exist in the database")
+	// ErrQuestionExists is returned when a question is given the key of another question.
+	ErrQuestionExists = errors.New("question already exists")
+	// ErrQuestionInUse is returned when deleting a question that articles are still tagged with, without reassigning them to another question.
+	ErrQuestionInUse = errors.New("question is still in use")
+	// ErrUnknownTopic is returned when the vocabulary is controlled and an article is tagged with a topic that is not in the Vocabulary.
+	ErrUnknownTopic = errors.New("topic is not in the approved vocabulary")
+	// ErrDuplicateArticle is returned when an imported article has the same URL as one already in the database.
+	ErrDuplicateArticle = errors.New("article already exists")
+)
+
+// Record is the flat representation of an article as it is laid out in the Articles sheet by BackupArticles: title, url, topics, question keys, question wording, and date.
+type Record struct {
+	Title      string   `json:"title"`
+	URL        string   `json:"url"`
+	Topics     []string `json:"topics"`
+	Questions  []string `json:"questions"`
+	Wording    []string `json:"wording"`
+	Date       string   `json:"date"`
+	Summary    string   `json:"summary,omitempty"`
+	Note       string   `json:"note,omitempty"`
+	Prompts    []string `json:"prompts,omitempty"`
+	Status     string   `json:"status,omitempty"`
+	AddedBy    string   `json:"added_by,omitempty"`
+	ReviewedBy string   `json:"reviewed_by,omitempty"`
+	PublishAt  string   `json:"publish_at,omitempty"`
+}
+
+var (
+	questionKey    = regexp.MustCompile(`^(?:([A-Za-z][\w\s-]*?)[\s_-]+)?(\d{4})(?:[\s_-]*[pP](\d))?[\s_-]*[qQ]?(\d{1,2})$`)
+	questionNumber = regexp.MustCompile(`[qQ]\d{1,2}$`)
+	sourceSpaces   = strings.NewReplacer("-", " ", "_", " ")
+)
+
+// ParseQuestion parses a question key or tag, such as "2019 6", "2019-Q6", "2019-P2-Q5" or "NJC-Prelim-2022-Q3", into the year, question number, paper and source of a Question. The source comes before the year and the paper after it, and questions without them are A-level Paper 1 questions.
+func ParseQuestion(key string) (Question, error) {
+	m := questionKey.FindStringSubmatch(strings.TrimSpace(key))
+	if m == nil {
+		return Question{}, fmt.Errorf("%w %q", ErrInvalidQuestion, key)
+	}
+	return NormalizeQuestion(Question{Source: m[1], Year: m[2], Paper: m[3], Number: m[4]})
+}
+
+// IsQuestionTag reports whether tag is a question tag rather than a topic, i.e. it can be parsed with ParseQuestion and writes the question number with a Q, as in "2019-Q6".
+func IsQuestionTag(tag string) bool {
+	tag = strings.TrimSpace(tag)
+	return questionKey.MatchString(tag) && questionNumber.MatchString(tag)
+}
+
+// NormalizeQuestion checks the year, question number and paper of qn, and writes them and its source the same way for every question: "P2" becomes "2", Paper 1 and A-level questions have an empty Paper and Source, and dashes in the source become spaces.
+func NormalizeQuestion(qn Question) (Question, error) {
+	qn.Year = strings.TrimSpace(qn.Year)
+	qn.Number = strings.TrimLeft(strings.TrimLeft(strings.TrimSpace(qn.Number), "qQ"), "0")
+	qn.Paper = strings.TrimLeft(strings.TrimSpace(qn.Paper), "pP")
+	qn.Source = strings.Join(strings.Fields(sourceSpaces.Replace(qn.Source)), " ")
+
+	if _, err := strconv.Atoi(qn.Year); err != nil || len(qn.Year) != 4 {
+		return Question{}, fmt.Errorf("%w: the year %q is not a number", ErrInvalidQuestion, qn.Year)
+	}
+	if n, err := strconv.Atoi(qn.Number); err != nil || n > 99 {
+		return Question{}, fmt.Errorf("%w: the question number %q is not a number", ErrInvalidQuestion, qn.Number)
+	}
+	if qn.Paper == "1" {
+		qn.Paper = ""
+	}
+	if _, err := strconv.Atoi(qn.Paper); err != nil && qn.Paper != "" {
+		return Question{}, fmt.Errorf("%w: the paper %q is not a number", ErrInvalidQuestion, qn.Paper)
+	}
+	if strings.EqualFold(qn.Source, ALevel) {
+		qn.Source = ""
+	}
+	return qn, nil
+}
+
+// ParseArticle validates a Record and converts it into an Article, applying the same rules as the add article form: the title, url, date and at least one tag must be present, the date must be in the "Jan 2, 2006" format, and every question must already exist in qnDB. Topics are converted to title case.
+func ParseArticle(rec Record, qnDB QuestionsDB) (*Article, error) {
+	if strings.TrimSpace(rec.Title) == "" || strings.TrimSpace(rec.URL) == "" || strings.TrimSpace(rec.Date) == "" {
+		return nil, ErrMissingField
+	}
+
+	a, err := NewArticle()
+	if err != nil {
+		return nil, err
+	}
+
+	a.Title = strings.TrimSpace(rec.Title)
+	a.URL = strings.TrimSpace(rec.URL)
+	a.Source = SourceName(a.URL)
+	a.Summary = strings.TrimSpace(rec.Summary)
+	a.Note = strings.TrimSpace(rec.Note)
+	a.Prompts = trimAll(rec.Prompts)
+	if a.Status, err = ParseStatus(rec.Status); err != nil {
+		return nil, err
+	}
+	a.AddedBy = strings.TrimSpace(rec.AddedBy)
+	a.ReviewedBy = strings.TrimSpace(rec.ReviewedBy)
+	if a.PublishAt, err = ParsePublishTime(rec.PublishAt); err != nil {
+		return nil, err
+	}
+	if err := a.SetDate(strings.TrimSpace(rec.Date)); err != nil {
+		return nil, fmt.Errorf("%w %q", ErrInvalidDate, rec.Date)
+	}
+
+	for _, t := range rec.Topics {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		a.SetTopics(strings.Title(t))
+	}
+
+	for _, key := range rec.Questions {
+		if strings.TrimSpace(key) == "" {
+			continue
+		}
+		qn, err := qnDB.Lookup(key)
+		if err != nil {
+			return nil, err
+		}
+		a.Questions = append(a.Questions, qn)
+	}
+
+	if len(a.Topics) == 0 && len(a.Questions) == 0 {
+		return nil, ErrMissingField
+	}
+
+	return a, nil
+}
+
+// Import file formats accepted by ImportArticles.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// ImportResult is the outcome of validating a single row of an import file. Row is the 1-based row number in the file.
+type ImportResult struct {
+	Row     int
+	Record  Record
+	Article *Article
+	Err     error
+}
+
+// ImportReport holds the result of validating every row of an import file, so that it can be shown to the admin as a dry run before the valid rows are committed.
+type ImportReport struct {
+	Results []ImportResult
+}
+
+// Valid returns the number of rows that can be imported.
+func (rep *ImportReport) Valid() int {
+	var n int
+	for _, r := range rep.Results {
+		if r.Err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Invalid returns the number of rows that failed validation.
+func (rep *ImportReport) Invalid() int { return len(rep.Results) - rep.Valid() }
+
+// Commit adds every valid row in the report to the articles database and returns the number of articles added.
+func (rep *ImportReport) Commit(database *ArticlesDBByDate, tm TopicsMap, qc QuestionCounter) int {
+	var n int
+	for _, r := range rep.Results {
+		if r.Err != nil {
+			continue
+		}
+		r.Article.AddArticleToDB(database, tm, qc)
+		n++
+	}
+	return n
+}
+
+// Submit puts the valid rows through the review workflow with Article.Submit, as if curator had added each of them with the status given in its row. Rows with no status are to be published. It must be called before Commit.
+func (rep *ImportReport) Submit(curator string, requireReview bool, now time.Time) {
+	for i := range rep.Results {
+		if a := rep.Results[i].Article; a != nil && rep.Results[i].Err == nil {
+			a.Submit(a.Status, curator, requireReview, now)
+		}
+	}
+}
+
+// ImportArticles reads articles in CSV or JSON format from r and validates each row with ParseArticle. Rows whose URL is already in the database, or appears earlier in the same file, are rejected. CSV files use the Articles sheet column layout, with multiple topics or questions in a cell separated by new lines or semicolons, and may start with a header row. JSON files contain an array of Record objects. Nothing is added to the database until Commit is called on the returned report.
+func ImportArticles(r io.Reader, format string, database *ArticlesDBByDate, qnDB QuestionsDB) (*ImportReport, error) {
+	var records []Record
+	var err error
+	first := 1
+
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		records, first, err = readCSVRecords(r)
+	case FormatJSON:
+		err = json.NewDecoder(r).Decode(&records)
+	default:
+		return nil, fmt.Errorf("unsupported import format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s data: %w", format, err)
+	}
+
+	seen := make(map[string]bool, len(*database)+len(records))
+	for _, a := range *database {
+		seen[a.URL] = true
+	}
+
+	rep := &ImportReport{Results: make([]ImportResult, 0, len(records))}
+	for i, rec := range records {
+		res := ImportResult{Row: i + first, Record: rec}
+		res.Article, res.Err = ParseArticle(rec, qnDB)
+		if res.Err == nil {
+			if seen[res.Article.URL] {
+				res.Article, res.Err = nil, ErrDuplicateArticle
+			}
+			seen[strings.TrimSpace(rec.URL)] = true
+		}
+		rep.Results = append(rep.Results, res)
+	}
+
+	return rep, nil
+}
+
+// readCSVRecords reads every row of a CSV file as a Record, skipping the header row if there is one. It also returns the row number of the first record in the file.
+func readCSVRecords(r io.Reader) ([]Record, int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	first := 1
+	if len(rows) > 0 && len(rows[0]) > 0 && strings.EqualFold(strings.TrimSpace(rows[0][0]), "title") {
+		rows = rows[1:]
+		first++
+	}
+
+	records := make([]Record, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, recordFromCells(row))
+	}
+	return records, first, nil
+}
+
+// recordFromCells maps a row of cells in the Articles sheet column layout to a Record. Missing cells are left empty so that the row fails validation rather than the whole file.
+func recordFromCells(row []string) Record {
+	cell := func(i int) string {
+		if i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	return Record{
+		Title:      cell(0),
+		URL:        cell(1),
+		Topics:     splitCell(cell(2)),
+		Questions:  splitCell(cell(3)),
+		Wording:    strings.Split(strings.TrimSpace(cell(4)), "\n"),
+		Date:       cell(5),
+		Summary:    cell(6),
+		Note:       cell(7),
+		Prompts:    trimAll(strings.Split(cell(8), "\n")),
+		Status:     cell(9),
+		AddedBy:    cell(10),
+		ReviewedBy: cell(11),
+		PublishAt:  cell(12),
+	}
+}
+
+func splitCell(cell string) []string {
+	fields := strings.FieldsFunc(cell, func(r rune) bool { return r == '\n' || r == ';' })
+	out := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testQuestionsDB is the questions database of testQuestions.
+var testQuestionsDB = QuestionsDB{
+	"2019 6": {Year: "2019", Number: "6", Wording: "How far is science a force for good?"},
+	"2020 1": {Year: "2020", Number: "1", Wording: "Is censorship ever justified?"},
+}
+
+func TestParseArticle(t *testing.T) {
+	valid := Record{Title: " Carbon tax to rise ", URL: "https://example.com/tax ", Topics: []string{"environment", " "}, Questions: []string{"2019-Q6"}, Date: "Mar 1, 2021"}
+
+	tests := []struct {
+		name string
+		edit func(*Record)
+		want error
+	}{
+		{"valid", func(*Record) {}, nil},
+		{"questions only", func(rec *Record) { rec.Topics = nil }, nil},
+		{"no title", func(rec *Record) { rec.Title = " " }, ErrMissingField},
+		{"no url", func(rec *Record) { rec.URL = "" }, ErrMissingField},
+		{"no date", func(rec *Record) { rec.Date = "" }, ErrMissingField},
+		{"no tags", func(rec *Record) { rec.Topics, rec.Questions = []string{""}, nil }, ErrMissingField},
+		{"date in another format", func(rec *Record) { rec.Date = "2021-03-01" }, ErrInvalidDate},
+		{"question that is not a key", func(rec *Record) { rec.Questions = []string{"question six"} }, ErrInvalidQuestion},
+		{"question not in the database", func(rec *Record) { rec.Questions = []string{"2018 3"} }, ErrUnknownQuestion},
+	}
+	for _, tt := range tests {
+		rec := valid
+		tt.edit(&rec)
+		a, err := ParseArticle(rec, testQuestionsDB)
+		if !errors.Is(err, tt.want) || (err == nil) != (tt.want == nil) {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if a.Title != "Carbon tax to rise" || a.URL != "https://example.com/tax" || a.DisplayDate != "Mar 1, 2021" {
+			t.Errorf("%s: got article %+v, want its fields trimmed", tt.name, a)
+		}
+		if len(rec.Topics) > 0 && (len(a.Topics) != 1 || a.Topics[0] != "Environment") {
+			t.Errorf("%s: got topics %v, want [Environment]", tt.name, a.Topics)
+		}
+		if len(a.Questions) != 1 || a.Questions[0].Wording != testQuestionsDB["2019 6"].Wording {
+			t.Errorf("%s: got questions %+v, want 2019 Q6 from the database", tt.name, a.Questions)
+		}
+	}
+}
+
+func TestImportArticles(t *testing.T) {
+	database := &ArticlesDBByDate{{Title: "Already on the feed", URL: "https://example.com/old"}}
+	csv := `Title,URL,Topics,Questions,Wording,Date
+Carbon tax to rise,https://example.com/tax,"Environment;Economy",2019 6,,"Mar 1, 2021"
+Already on the feed,https://example.com/old,Media,,,"Mar 2, 2021"
+No date,https://example.com/nodate,Media,,,
+Carbon tax to rise again,https://example.com/tax,Environment,,,"Mar 3, 2021"
+Censorship,https://example.com/censor,,2020 1,,"Mar 4, 2021"
+`
+
+	report, err := ImportArticles(strings.NewReader(csv), FormatCSV, database, testQuestionsDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		row int
+		err error
+	}{{2, nil}, {3, ErrDuplicateArticle}, {4, ErrMissingField}, {5, ErrDuplicateArticle}, {6, nil}}
+	if len(report.Results) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(report.Results), len(want))
+	}
+	for i, w := range want {
+		if got := report.Results[i]; got.Row != w.row || !errors.Is(got.Err, w.err) || (got.Err == nil) != (w.err == nil) {
+			t.Errorf("got row %d with error %v, want row %d with %v", got.Row, got.Err, w.row, w.err)
+		}
+	}
+	if report.Valid() != 2 || report.Invalid() != 3 {
+		t.Errorf("got %d valid and %d invalid rows, want 2 and 3", report.Valid(), report.Invalid())
+	}
+	if got := report.Results[0].Article.Topics; len(got) != 2 || got[1] != "Economy" {
+		t.Errorf("got topics %v, want the cell split at the semicolon", got)
+	}
+
+	if database.Len() != 1 {
+		t.Fatalf("got %d articles before Commit, want the database unchanged", database.Len())
+	}
+	tm, qc := InitTopicsMap(), InitQuestionCounter()
+	if n := report.Commit(database, tm, qc); n != 2 || database.Len() != 3 || tm["Environment"] != 1 || qc[testQuestionsDB["2020 1"].Label()] != 1 {
+		t.Errorf("got %d committed, %d articles and counts %v %v, want the 2 valid rows added and counted", n, database.Len(), tm, qc)
+	}
+
+	json := `[{"title": "Censorship", "url": "https://example.com/censor", "questions": ["2020-Q1"], "date": "Mar 4, 2021"},
+{"title": "Carbon tax", "url": "https://example.com/tax2", "topics": ["Environment"], "date": "Mar 5, 2021"}]`
+	report, err = ImportArticles(strings.NewReader(json), FormatJSON, database, testQuestionsDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Results[0].Row != 1 || !errors.Is(report.Results[0].Err, ErrDuplicateArticle) || report.Results[1].Err != nil {
+		t.Errorf("got results %+v, want the committed article rejected as a duplicate and the new one valid", report.Results)
+	}
+
+	if _, err := ImportArticles(strings.NewReader(csv), "xlsx", database, testQuestionsDB); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+	if _, err := ImportArticles(strings.NewReader("{"), FormatJSON, database, testQuestionsDB); err == nil {
+		t.Error("expected an error for a file that is not JSON")
+	}
+}
+
+func TestImportSubmit(t *testing.T) {
+	json := `[{"title": "Censorship", "url": "https://example.com/censor", "questions": ["2020-Q1"], "date": "Mar 4, 2021"},
+{"title": "Carbon tax", "url": "https://example.com/tax", "topics": ["Environment"], "date": "Mar 5, 2021", "status": "draft", "added_by": "John"},
+{"title": "No date", "url": "https://example.com/nodate", "topics": ["Environment"]}]`
+	report, err := ImportArticles(strings.NewReader(json), FormatJSON, NewArticlesDBByDate(), testQuestionsDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report.Submit("Mary", true, time.Now())
+
+	if a := report.Results[0].Article; a.Status != Pending || a.AddedBy != "Mary" {
+		t.Errorf("got %q added by %q, want a row with no status pending review and added by Mary", a.Status, a.AddedBy)
+	}
+	if a := report.Results[1].Article; a.Status != Draft || a.AddedBy != "John" {
+		t.Errorf("got %q added by %q, want the draft added by John kept as it is", a.Status, a.AddedBy)
+	}
+}
@@ -0,0 +1,74 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// LinkResult is the result of checking the link to an article. Status is the HTTP status code returned, or 0 if the request failed.
+type LinkResult struct {
+	Article Article
+	Status  int
+	Err     error
+}
+
+// OK reports whether the link could be followed.
+func (lr LinkResult) OK() bool { return lr.Err == nil && lr.Status < 400 }
+
+// CheckLinks requests the URL of every article in the database with client, using the given number of workers, and returns the results in the same order as the database. Each link is first checked with a HEAD request, falling back to GET for sites that do not answer HEAD requests properly.
+func CheckLinks(ctx context.Context, database *ArticlesDBByDate, client *http.Client, workers int) []LinkResult {
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]LinkResult, len(*database))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				a := (*database)[j]
+				status, err := checkLink(ctx, client, a.URL)
+				results[j] = LinkResult{Article: a, Status: status, Err: err}
+			}
+		}()
+	}
+
+	for j := range *database {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func checkLink(ctx context.Context, client *http.Client, url string) (int, error) {
+	status, err := request(ctx, client, http.MethodHead, url)
+	if err == nil && status < 400 {
+		return status, nil
+	}
+	return request(ctx, client, http.MethodGet, url)
+}
+
+func request(ctx context.Context, client *http.Client, method, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("invalid URL: %w", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; njcgpnewsfeed link checker)")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
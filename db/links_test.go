@@ -0,0 +1,30 @@
+package db
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckLinks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/gone":
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/nohead" && r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer srv.Close()
+
+	database := &ArticlesDBByDate{{URL: srv.URL + "/ok"}, {URL: srv.URL + "/gone"}, {URL: srv.URL + "/nohead"}, {URL: "://bad"}}
+	results := CheckLinks(context.Background(), database, srv.Client(), 2)
+
+	want := []bool{true, false, true, false}
+	for i, r := range results {
+		if r.OK() != want[i] {
+			t.Errorf("%s: got ok %v (status %d, err %v), want %v", r.Article.URL, r.OK(), r.Status, r.Err, want[i])
+		}
+	}
+}
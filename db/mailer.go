@@ -1,8 +1,6 @@
 package db
 
 import (
-	"os"
-	"strconv"
 	"time"
 
 	"github.com/xhit/go-simple-mail/v2"
@@ -10,10 +8,10 @@ import (
 
 func SendMail(from, to, replyTo, subject, msg string) error {
 	server := mail.NewSMTPClient()
-	server.Host = os.Getenv("SMTP_HOST")
-	server.Port, _ = strconv.Atoi(os.Getenv("SMTP_PORT"))
-	server.Username = os.Getenv("SMTP_USER")
-	server.Password = os.Getenv("SMTP_PASS")
+	server.Host = config.SMTP.Host
+	server.Port = config.SMTP.Port
+	server.Username = config.SMTP.User
+	server.Password = config.SMTP.Password
 	server.Encryption = mail.EncryptionTLS
 	server.KeepAlive = false
 	server.ConnectTimeout = 10 * time.Second
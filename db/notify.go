@@ -0,0 +1,43 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import "log"
+
+// Notifier sends a message to the admins of the app.
+type Notifier interface {
+	Notify(subject, msg string) error
+}
+
+// MailNotifier is a Notifier that emails the admins with SendMail.
+type MailNotifier struct {
+	From    string
+	To      string
+	ReplyTo string
+}
+
+// Notify emails msg to n.To.
+func (n MailNotifier) Notify(subject, msg string) error {
+	return SendMail(n.From, n.To, n.ReplyTo, subject, msg)
+}
+
+// LogNotifier is a Notifier that writes messages to the log, for when no email address has been configured.
+type LogNotifier struct{}
+
+// Notify logs msg.
+func (LogNotifier) Notify(subject, msg string) error {
+	log.Printf("%s\n%s", subject, msg)
+	return nil
+}
+
+// NewNotifier returns a MailNotifier for the addresses in cfg.Notify, or a LogNotifier if no address has been configured.
+func NewNotifier(cfg Config) Notifier {
+	if cfg.Notify.To == "" {
+		return LogNotifier{}
+	}
+
+	replyTo := cfg.Notify.ReplyTo
+	if replyTo == "" {
+		replyTo = cfg.Notify.To
+	}
+	return MailNotifier{From: cfg.Notify.From, To: cfg.Notify.To, ReplyTo: replyTo}
+}
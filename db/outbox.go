@@ -0,0 +1,398 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Retry backoff for failed sheet writes. The delay doubles with every failed attempt, up to outboxMaxDelay.
+const (
+	outboxBaseDelay = 30 * time.Second
+	outboxMaxDelay  = time.Hour
+)
+
+// SheetWrite is a single write to Google Sheets, either overwriting a range or appending rows to it.
+type SheetWrite struct {
+	ID            string
+	Description   string
+	SpreadsheetID string
+	Range         string
+	Values        [][]interface{}
+	Append        bool
+	InputOption   string
+	// Replace, for overwrites, clears any cells left over from longer previous contents of the range, so that the range ends up holding exactly Values.
+	Replace bool
+	// KeyColumns are the columns that identify a row. Before appending, rows that already exist in the sheet with the same values in every key column are dropped, so that retrying an append which reached the sheet does not duplicate rows.
+	KeyColumns  []int
+	Attempts    int
+	LastError   string
+	Created     time.Time
+	NextAttempt time.Time
+}
+
+// apply performs the write with srv.
+func (w SheetWrite) apply(ctx context.Context, srv SheetsClient) error {
+	if !w.Append {
+		values := w.Values
+		if w.Replace {
+			existing, err := srv.Get(ctx, w.SpreadsheetID, w.Range)
+			if err != nil {
+				return fmt.Errorf("unable to read the range to be replaced: %w", err)
+			}
+			values = padValues(values, existing)
+		}
+		return srv.Update(ctx, w.SpreadsheetID, w.Range, values, w.InputOption)
+	}
+
+	values := w.Values
+	if len(w.KeyColumns) > 0 {
+		existing, err := srv.Get(ctx, w.SpreadsheetID, w.Range)
+		if err != nil {
+			return fmt.Errorf("unable to check for rows already written: %w", err)
+		}
+		values = w.unwritten(existing)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	return srv.Append(ctx, w.SpreadsheetID, w.Range, values, w.InputOption)
+}
+
+// unwritten returns the rows in w.Values that are not already in existing.
+func (w SheetWrite) unwritten(existing [][]interface{}) [][]interface{} {
+	seen := make(map[string]bool, len(existing))
+	for _, row := range existing {
+		seen[w.rowKey(row)] = true
+	}
+
+	values := make([][]interface{}, 0, len(w.Values))
+	for _, row := range w.Values {
+		if !seen[w.rowKey(row)] {
+			values = append(values, row)
+		}
+	}
+	return values
+}
+
+func (w SheetWrite) rowKey(row []interface{}) string {
+	key := make([]string, 0, len(w.KeyColumns))
+	for _, c := range w.KeyColumns {
+		var cell string
+		if c < len(row) {
+			cell = fmt.Sprint(row[c])
+		}
+		key = append(key, cell)
+	}
+	b, _ := json.Marshal(key)
+	return string(b)
+}
+
+// padValues returns values padded with empty cells to cover every cell in existing, so that writing them clears whatever is left over.
+func padValues(values, existing [][]interface{}) [][]interface{} {
+	n := len(values)
+	if len(existing) > n {
+		n = len(existing)
+	}
+
+	padded := make([][]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		var row []interface{}
+		if i < len(values) {
+			row = append(row, values[i]...)
+		}
+		if i < len(existing) {
+			for len(row) < len(existing[i]) {
+				row = append(row, "")
+			}
+		}
+		padded = append(padded, row)
+	}
+	return padded
+}
+
+// WriteNow performs w immediately rather than through an Outbox, for tools that do not run long enough to retry failed writes.
+func WriteNow(ctx context.Context, w SheetWrite) error {
+	srv, err := newSheetsService(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to start Sheets service: %w", err)
+	}
+
+	if err := w.apply(ctx, srv); err != nil {
+		return fmt.Errorf("unable to %s: %w", w.Description, err)
+	}
+	return nil
+}
+
+// Outbox is a persistent queue of writes to Google Sheets. Writes are saved to disk as soon as they are enqueued, and are retried with exponential backoff until they succeed, so that changes made in the app are not lost if Google Sheets is unavailable or the app restarts.
+type Outbox struct {
+	mu      sync.Mutex
+	path    string
+	pending []SheetWrite
+	wake    chan struct{}
+	applied func(SheetWrite)
+	// enqueued counts the writes enqueued to each range, by spreadsheet ID and range.
+	enqueued map[string]int
+}
+
+// OpenOutbox returns an Outbox saved to the JSON file at path, loading any writes left pending by a previous run. If path is empty, the outbox is kept in memory only.
+func OpenOutbox(path string) (*Outbox, error) {
+	o := &Outbox{
+		path:     path,
+		pending:  make([]SheetWrite, 0),
+		wake:     make(chan struct{}, 1),
+		enqueued: make(map[string]int),
+	}
+	if path == "" {
+		return o, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read outbox: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &o.pending); err != nil {
+		return nil, fmt.Errorf("unable to parse outbox %s: %w", path, err)
+	}
+	return o, nil
+}
+
+// OnApplied sets f to be called with each write once it has reached Google Sheets, such as SheetSync.Written. f is called from Flush without the outbox locked.
+func (o *Outbox) OnApplied(f func(SheetWrite)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.applied = f
+}
+
+// Enqueue adds a write to the outbox and wakes Run to attempt it. A write that overwrites a range replaces any pending overwrite of the same range, since only the latest contents matter.
+func (o *Outbox) Enqueue(w SheetWrite) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if w.ID == "" {
+		w.ID = newID()
+	}
+	if w.Created.IsZero() {
+		w.Created = time.Now()
+	}
+
+	if !w.Append {
+		kept := o.pending[:0]
+		for _, p := range o.pending {
+			if p.Append || p.SpreadsheetID != w.SpreadsheetID || p.Range != w.Range {
+				kept = append(kept, p)
+			}
+		}
+		o.pending = kept
+	}
+	o.pending = append(o.pending, w)
+	o.enqueued[w.SpreadsheetID+"!"+w.Range]++
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+
+	return o.save()
+}
+
+// Pending returns a copy of the writes that have not yet reached Google Sheets, oldest first.
+func (o *Outbox) Pending() []SheetWrite {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]SheetWrite(nil), o.pending...)
+}
+
+// Len returns the number of writes that have not yet reached Google Sheets.
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.pending)
+}
+
+// HasPending reports whether any write to the given range of a spreadsheet has not yet reached Google Sheets.
+func (o *Outbox) HasPending(spreadsheetID, sheetRange string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, w := range o.pending {
+		if w.SpreadsheetID == spreadsheetID && w.Range == sheetRange {
+			return true
+		}
+	}
+	return false
+}
+
+// Enqueued returns the number of writes to the given range of a spreadsheet that have been enqueued since the outbox was opened, so that a caller can tell whether the range has been changed while it was reading it.
+func (o *Outbox) Enqueued(spreadsheetID, sheetRange string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.enqueued[spreadsheetID+"!"+sheetRange]
+}
+
+// RetryNow clears the backoff on every pending write and wakes Run to attempt them immediately.
+func (o *Outbox) RetryNow() {
+	o.mu.Lock()
+	for i := range o.pending {
+		o.pending[i].NextAttempt = time.Time{}
+	}
+	o.mu.Unlock()
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Flush attempts every pending write that is due, in the order they were enqueued. A write waits while an earlier write to the same sheet is pending, whether that write is due or still backing off after failing, so that writes to a sheet are never applied out of order. Flush returns the first error encountered.
+func (o *Outbox) Flush(ctx context.Context) error {
+	o.mu.Lock()
+	due := make([]SheetWrite, 0, len(o.pending))
+	waiting := make(map[string]bool)
+	now := time.Now()
+	for _, w := range o.pending {
+		sheet := w.SpreadsheetID + "!" + w.Range
+		if waiting[sheet] {
+			continue
+		}
+		if w.NextAttempt.After(now) {
+			waiting[sheet] = true
+			continue
+		}
+		due = append(due, w)
+	}
+	applied := o.applied
+	o.mu.Unlock()
+
+	if len(due) == 0 {
+		return nil
+	}
+
+	srv, err := newSheetsService(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to start Sheets service: %w", err)
+	}
+
+	var firstErr error
+	blocked := make(map[string]bool)
+	done := make(map[string]bool)
+	failed := make(map[string]error)
+
+	for _, w := range due {
+		sheet := w.SpreadsheetID + "!" + w.Range
+		if blocked[sheet] {
+			continue
+		}
+		if err := w.apply(ctx, srv); err != nil {
+			blocked[sheet] = true
+			failed[w.ID] = err
+			if firstErr == nil {
+				firstErr = fmt.Errorf("unable to %s: %w", w.Description, err)
+			}
+			continue
+		}
+		done[w.ID] = true
+		if applied != nil {
+			applied(w)
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	kept := o.pending[:0]
+	for _, w := range o.pending {
+		if done[w.ID] {
+			continue
+		}
+		if err, ok := failed[w.ID]; ok {
+			w.Attempts++
+			w.LastError = err.Error()
+			w.NextAttempt = time.Now().Add(backoff(w.Attempts))
+		}
+		kept = append(kept, w)
+	}
+	o.pending = kept
+
+	if err := o.save(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// Run flushes the outbox whenever a write is enqueued, and otherwise every interval, until ctx is cancelled.
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := o.Flush(ctx); err != nil {
+			log.Printf("Sheets sync failed, %d change(s) pending - %v", o.Len(), err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+// save writes the pending writes to disk. The caller must hold o.mu.
+func (o *Outbox) save() error {
+	if o.path == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(o.pending)
+	if err != nil {
+		return fmt.Errorf("unable to encode outbox: %w", err)
+	}
+
+	tmp := o.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("unable to save outbox: %w", err)
+	}
+	if err := os.Rename(tmp, o.path); err != nil {
+		return fmt.Errorf("unable to save outbox: %w", err)
+	}
+	return nil
+}
+
+func backoff(attempts int) time.Duration {
+	d := outboxBaseDelay
+	for i := 1; i < attempts && d < outboxMaxDelay; i++ {
+		d *= 2
+	}
+	if d > outboxMaxDelay {
+		d = outboxMaxDelay
+	}
+	return d
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprint(time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// failingSheets fails every call while fail is set, and otherwise passes calls through to a FakeSheets.
+type failingSheets struct {
+	*FakeSheets
+	fail bool
+}
+
+func (f *failingSheets) Append(ctx context.Context, spreadsheetID, appendRange string, values [][]interface{}, inputOption string) error {
+	if f.fail {
+		return errors.New("service unavailable")
+	}
+	return f.FakeSheets.Append(ctx, spreadsheetID, appendRange, values, inputOption)
+}
+
+func TestOutboxRetriesFailedWrites(t *testing.T) {
+	fake := useFakeSheets(t, nil, nil)
+	failing := &failingSheets{FakeSheets: fake, fail: true}
+	UseSheetsClient(failing)
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	o, err := OpenOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Article{Title: "Title", URL: "https://example.com/a", Topics: []Topic{"Media"}, DisplayDate: "Jan 2, 2021"}
+	if err := o.Enqueue(AppendArticleWrite(a)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := o.Flush(ctx); err == nil {
+		t.Fatal("expected flush to fail")
+	}
+	pending := o.Pending()
+	if len(pending) != 1 || pending[0].Attempts != 1 || pending[0].LastError == "" {
+		t.Fatalf("got pending %+v, want one write with one failed attempt", pending)
+	}
+
+	// the pending write survives a restart.
+	o, err = OpenOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Len() != 1 {
+		t.Fatalf("got %d pending writes after reopening, want 1", o.Len())
+	}
+
+	failing.fail = false
+	o.RetryNow()
+	if err := o.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if o.Len() != 0 {
+		t.Errorf("got %d pending writes, want 0", o.Len())
+	}
+
+	want := [][]interface{}{NewRecord(*a).Row()}
+	if got := fake.Values(testSheetID, "Articles"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestOutboxAppendIsIdempotent(t *testing.T) {
+	fake := useFakeSheets(t, nil, nil)
+	ctx := context.Background()
+
+	a := &Article{Title: "Title", URL: "https://example.com/a", Topics: []Topic{"Media"}, DisplayDate: "Jan 2, 2021"}
+	w := AppendArticleWrite(a)
+
+	// applying the same write twice, as when a retry follows a write that reached the sheet, adds only one row.
+	for i := 0; i < 2; i++ {
+		if err := w.apply(ctx, fake); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := fake.Values(testSheetID, "Articles"); len(got) != 1 {
+		t.Errorf("got %d rows, want 1", len(got))
+	}
+}
+
+func TestOutboxCoalescesOverwrites(t *testing.T) {
+	useFakeSheets(t, nil, nil)
+
+	o, err := OpenOutbox("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	database := NewArticlesDBByDate()
+	o.Enqueue(BackupArticlesWrite(database))
+	o.Enqueue(AppendArticleWrite(&Article{Title: "Title", URL: "https://example.com/a"}))
+	*database = append(*database, Article{Title: "Title", URL: "https://example.com/a"})
+	o.Enqueue(BackupArticlesWrite(database))
+
+	pending := o.Pending()
+	if len(pending) != 2 {
+		t.Fatalf("got %d pending writes, want 2", len(pending))
+	}
+	if !pending[0].Append || pending[1].Append || len(pending[1].Values) != 1 {
+		t.Errorf("got %+v, want the append followed by the latest backup", pending)
+	}
+}
+
+func TestOutboxKeepsOrderWhileRetrying(t *testing.T) {
+	fake := useFakeSheets(t, nil, nil)
+	failing := &failingSheets{FakeSheets: fake, fail: true}
+	UseSheetsClient(failing)
+
+	ctx := context.Background()
+	o, err := OpenOutbox("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Article{Title: "First", URL: "https://example.com/a", Topics: []Topic{"Media"}, DisplayDate: "Jan 2, 2021"}
+	o.Enqueue(AppendArticleWrite(a))
+	if err := o.Flush(ctx); err == nil {
+		t.Fatal("expected flush to fail")
+	}
+
+	// the first write is backing off, and the second must not overtake it even though it is due.
+	failing.fail = false
+	b := &Article{Title: "Second", URL: "https://example.com/b", Topics: []Topic{"Media"}, DisplayDate: "Jan 3, 2021"}
+	o.Enqueue(AppendArticleWrite(b))
+	if err := o.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Values(testSheetID, "Articles"); len(got) != 0 || o.Len() != 2 {
+		t.Fatalf("got rows %v with %d pending writes, want nothing written before the first write is retried", got, o.Len())
+	}
+
+	o.RetryNow()
+	if err := o.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]interface{}{NewRecord(*a).Row(), NewRecord(*b).Row()}
+	if got := fake.Values(testSheetID, "Articles"); !reflect.DeepEqual(got, want) || o.Len() != 0 {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
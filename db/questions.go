@@ -4,17 +4,173 @@ package db
 import (
 	"context"
 	"fmt"
-	"os"
 	"sort"
-
-	"google.golang.org/api/sheets/v4"
+	"strconv"
+	"strings"
 )
 
+// ALevel is the SourceName of questions set in the A-level exam, which are stored without a Source.
+const ALevel = "A Level"
+
 // Question is a struct that represents the question object in each article entry in the ArticlesDBByDate.
 type Question struct {
-	Year    string
-	Number  string
-	Wording string
+	Year    string `json:"year"`
+	Number  string `json:"number"`
+	Wording string `json:"wording"`
+	// Paper is "2" for Paper 2 (comprehension and AQ) questions, and empty for Paper 1 essay questions.
+	Paper string `json:"paper,omitempty"`
+	// Source is the school and exam that set the question, such as "NJC Prelim", and empty for A-level questions.
+	Source string `json:"source,omitempty"`
+	// Level is the exam level of the question, such as "H1".
+	Level string `json:"level,omitempty"`
+	// Theme is the syllabus theme the question falls under, such as "Science and Technology".
+	Theme string `json:"theme,omitempty"`
+}
+
+// PaperNumber returns the number of the paper the question was set in.
+func (qn Question) PaperNumber() string {
+	if qn.Paper == "" {
+		return "1"
+	}
+	return qn.Paper
+}
+
+// SourceName returns the Source of the question, or ALevel for A-level questions.
+func (qn Question) SourceName() string {
+	if qn.Source == "" {
+		return ALevel
+	}
+	return qn.Source
+}
+
+// Key returns the key of the question in the QuestionsDB and the Articles sheet, such as "2019 6", "2019 P2 5" or "NJC Prelim 2022 3".
+func (qn Question) Key() string {
+	return strings.Join(qn.parts(qn.Number), " ")
+}
+
+// Tag returns the question as it is written in the tags field of the add article form, such as "2019-Q6", "2019-P2-Q5" or "NJC-Prelim-2022-Q3".
+func (qn Question) Tag() string {
+	return strings.Join(qn.parts("Q"+qn.Number), "-")
+}
+
+// Label returns the name of the question shown to readers and used as its QuestionCounter key, such as "2019 - Q6", "2019 - P2 Q5" or "NJC Prelim 2022 - Q3".
+func (qn Question) Label() string {
+	number := "Q" + qn.Number
+	if qn.Paper != "" {
+		number = "P" + qn.Paper + " " + number
+	}
+	return strings.TrimSpace(qn.Source+" "+qn.Year) + " - " + number
+}
+
+// parts returns the words of the source, the year, the paper if it is not Paper 1, and number.
+func (qn Question) parts(number string) []string {
+	parts := strings.Fields(qn.Source)
+	parts = append(parts, qn.Year)
+	if qn.Paper != "" {
+		parts = append(parts, "P"+qn.Paper)
+	}
+	return append(parts, number)
+}
+
+// QuestionFilter selects questions by paper, source, exam level and theme. Fields are compared regardless of case, and empty fields match every question.
+type QuestionFilter struct {
+	Paper  string
+	Source string
+	Level  string
+	Theme  string
+}
+
+// IsZero reports whether the filter matches every question.
+func (f QuestionFilter) IsZero() bool {
+	return f == QuestionFilter{}
+}
+
+// String describes the filter, e.g. "Paper 2, NJC Prelim".
+func (f QuestionFilter) String() string {
+	var parts []string
+	if f.Paper != "" {
+		parts = append(parts, "Paper "+strings.TrimPrefix(strings.ToUpper(f.Paper), "P"))
+	}
+	for _, v := range []string{f.Source, f.Level, f.Theme} {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Match reports whether qn matches the filter. The Paper field matches the PaperNumber of qn, and the Source field its SourceName.
+func (f QuestionFilter) Match(qn Question) bool {
+	match := func(want, got string) bool {
+		return want == "" || strings.EqualFold(strings.TrimSpace(want), got)
+	}
+	return match(strings.TrimPrefix(strings.ToUpper(f.Paper), "P"), qn.PaperNumber()) &&
+		match(f.Source, qn.SourceName()) &&
+		match(f.Level, qn.Level) &&
+		match(f.Theme, qn.Theme)
+}
+
+// FilterByQuestion returns the articles in the database tagged with at least one question matching f.
+func FilterByQuestion(database *ArticlesDBByDate, f QuestionFilter) *ArticlesDBByDate {
+	results := NewArticlesDBByDate()
+	for _, a := range *database {
+		for _, qn := range a.Questions {
+			if f.Match(qn) {
+				*results = append(*results, a)
+				break
+			}
+		}
+	}
+	return results
+}
+
+// QuestionFacets lists the papers, sources, exam levels and themes of the questions in qnDB, in alphabetical order, to choose from when filtering questions.
+type QuestionFacets struct {
+	Papers  []string
+	Sources []string
+	Levels  []string
+	Themes  []string
+}
+
+// NewQuestionFacets returns the QuestionFacets of the questions in qnDB.
+func NewQuestionFacets(qnDB QuestionsDB) QuestionFacets {
+	papers, sources, levels, themes := make(map[string]bool), make(map[string]bool), make(map[string]bool), make(map[string]bool)
+	for _, qn := range qnDB {
+		papers[qn.PaperNumber()] = true
+		sources[qn.SourceName()] = true
+		levels[qn.Level] = true
+		themes[qn.Theme] = true
+	}
+
+	sorted := func(set map[string]bool) []string {
+		values := make([]string, 0, len(set))
+		for v := range set {
+			if v != "" {
+				values = append(values, v)
+			}
+		}
+		sort.Strings(values)
+		return values
+	}
+	return QuestionFacets{sorted(papers), sorted(sources), sorted(levels), sorted(themes)}
+}
+
+// Lookup finds the question tagged as tag, which is parsed with ParseQuestion. Sources are compared regardless of case, so "njc-prelim-2022-q3" finds the NJC Prelim 2022 Q3 question.
+func (qnDB QuestionsDB) Lookup(tag string) (Question, error) {
+	qn, err := ParseQuestion(tag)
+	if err != nil {
+		return Question{}, err
+	}
+
+	if found, ok := qnDB[qn.Key()]; ok {
+		return found, nil
+	}
+	for k, found := range qnDB {
+		if strings.EqualFold(k, qn.Key()) {
+			return found, nil
+		}
+	}
+	return Question{}, fmt.Errorf("%w: %s", ErrUnknownQuestion, qn.Label())
 }
 
 // QuestionsDB is a map of questions for quick searching.
@@ -30,7 +186,7 @@ func InitQuestionsDB(ctx context.Context) (QuestionsDB, error) {
 	}
 
 	sheetRange := "Questions"
-	data, err := getSheetData(srv, sheetRange)
+	data, err := getSheetData(ctx, srv, sheetRange)
 	if err != nil {
 		return qnDB, fmt.Errorf("unable to get sheet data: %w", err)
 	}
@@ -40,30 +196,101 @@ func InitQuestionsDB(ctx context.Context) (QuestionsDB, error) {
 	}
 
 	for _, row := range data.Values {
-		year := fmt.Sprintf("%v", row[1])
-		number := fmt.Sprintf("%v", row[2])
-		wording := fmt.Sprintf("%v", row[3])
-
-		qn := Question{year, number, wording}
+		qn, ok := questionFromRow(row)
+		if !ok {
+			continue
+		}
 
-		key := year + " " + number
-		qnDB[key] = qn
+		qnDB[qn.Key()] = qn
 	}
 	return qnDB, nil
 }
 
+// PullQuestions adds questions that have been added to the Questions sheet since qnDB was initialised, e.g. by the command line tool, so that they can be used to tag articles and are not dropped by the next backup. It returns the number of questions added.
+func PullQuestions(ctx context.Context, qnDB QuestionsDB) (int, error) {
+	qns, err := ReadQuestions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return AddNewQuestions(qnDB, qns), nil
+}
+
+// ReadQuestions returns the valid questions in the Questions sheet, for AddNewQuestions. It is the part of PullQuestions that calls Google Sheets, so that the sheet can be read without holding a lock on qnDB.
+func ReadQuestions(ctx context.Context) ([]Question, error) {
+	srv, err := newSheetsService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start Sheets service: %w", err)
+	}
+
+	data, err := getSheetData(ctx, srv, "Questions")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get sheet data: %w", err)
+	}
+
+	qns := make([]Question, 0, len(data.Values))
+	for _, row := range data.Values {
+		if qn, ok := questionFromRow(row); ok {
+			qns = append(qns, qn)
+		}
+	}
+	return qns, nil
+}
+
+// AddNewQuestions adds the questions in qns that are not yet in qnDB, and returns the number of questions added.
+func AddNewQuestions(qnDB QuestionsDB, qns []Question) int {
+	var added int
+	for _, qn := range qns {
+		key := qn.Key()
+		if _, ok := qnDB[key]; !ok {
+			qnDB[key] = qn
+			added++
+		}
+	}
+	return added
+}
+
+// questionFromRow reads a row of the Questions sheet, which holds the key, year, question number and wording, followed by the optional paper, source, exam level and theme. It reports false for rows that are too short or do not have a valid year and question number.
+func questionFromRow(row []interface{}) (Question, bool) {
+	if len(row) < 4 {
+		return Question{}, false
+	}
+
+	cell := func(i int) string {
+		if i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(fmt.Sprintf("%v", row[i]))
+	}
+
+	qn, err := NormalizeQuestion(Question{Year: cell(1), Number: cell(2), Paper: cell(4), Source: cell(5)})
+	if err != nil {
+		return Question{}, false
+	}
+	qn.Wording, qn.Level, qn.Theme = cell(3), cell(6), cell(7)
+	return qn, true
+}
+
+// questionRow returns qn as a row of the Questions sheet. The metadata columns are left out when they are all empty.
+func questionRow(qn Question) []interface{} {
+	row := []interface{}{qn.Key(), qn.Year, qn.Number, qn.Wording}
+	if qn.Paper+qn.Source+qn.Level+qn.Theme != "" {
+		row = append(row, qn.Paper, qn.Source, qn.Level, qn.Theme)
+	}
+	return row
+}
+
 // QuestionCounter is an object to count number of articles per question.
 type QuestionCounter map[string]int
 
 // InitQuestionCounter returns an initialised QuestionCounter map.
 func InitQuestionCounter() QuestionCounter { return make(map[string]int) }
 
-// Increment increases the count for the question in the QuestionCounter map. Argument must be provided in the format qn.Year + " - Q" + qn.Number.
+// Increment increases the count for the question in the QuestionCounter map. Argument must be the Label of the question.
 func (qc QuestionCounter) Increment(key string) {
 	qc[key]++
 }
 
-// Decrement decreases the count for the question in the QuestionCounter map. Argument must be provided in the format qn.Year + " - Q" + qn.Number.
+// Decrement decreases the count for the question in the QuestionCounter map. Argument must be the Label of the question.
 func (qc QuestionCounter) Decrement(key string) {
 	qc[key]--
 	if qc[key] < 1 {
@@ -74,7 +301,7 @@ func (qc QuestionCounter) Decrement(key string) {
 // GetZeroArticleQns gets the questions that have zero articles tagged.
 func (qc QuestionCounter) GetZeroArticleQns(qnDB QuestionsDB) {
   for _, v := range qnDB {
-    qn := v.Year + " - Q" + v.Number
+    qn := v.Label()
     if _, ok := qc[qn]; !ok {
       qc[qn] = 0
     }
@@ -110,6 +337,29 @@ func RankQuestionsByArticleCount(counter QuestionCounter) QuestionsByArticleCoun
 	return qc
 }
 
+// BackupQuestionsWrite returns the SheetWrite that backs up the questions database to the Questions sheet.
+func BackupQuestionsWrite(qnDB QuestionsDB) SheetWrite {
+	keys := make([]string, 0, len(qnDB))
+	for k := range qnDB {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	values := make([][]interface{}, 0, len(qnDB))
+	for _, k := range keys {
+		values = append(values, questionRow(qnDB[k]))
+	}
+
+	return SheetWrite{
+		Description:   "back up questions",
+		SpreadsheetID: config.SheetID, // Articles DB new
+		Range:         "Questions",
+		Values:        values,
+		InputOption:   "RAW",
+		Replace:       true,
+	}
+}
+
 // BackupQuestions backs up the questions database to a predefined, hard-coded Google Sheet.
 func BackupQuestions(ctx context.Context, qnDB QuestionsDB) error {
 	srv, err := newSheetsService(ctx)
@@ -117,26 +367,26 @@ func BackupQuestions(ctx context.Context, qnDB QuestionsDB) error {
 		return fmt.Errorf("unable to start Sheets service: %w", err)
 	}
 
-	backupSheetID := os.Getenv("SHEET_ID") // Articles DB new
-	backupSheetName := "Questions"
-
-	var valueRange sheets.ValueRange
-	valueRange.Values = make([][]interface{}, 0, len(qnDB))
-
-	for k, v := range qnDB {
-		record := make([]interface{}, 0, 5)
-		record = append(record, k, v.Year, v.Number, v.Wording)
-		valueRange.Values = append(valueRange.Values, record)
-	}
-
-	_, err = srv.Spreadsheets.Values.Update(backupSheetID, backupSheetName, &valueRange).ValueInputOption("RAW").Do()
-	if err != nil {
+	if err := BackupQuestionsWrite(qnDB).apply(ctx, srv); err != nil {
 		return fmt.Errorf("unable to backup data to backup sheet: %w", err)
 	}
 
 	return nil
 }
 
+// AppendQuestionWrite returns the SheetWrite that appends a question to the Questions sheet. Rows are identified by question key and wording, so the same question is not appended twice.
+func AppendQuestionWrite(qn Question) SheetWrite {
+	return SheetWrite{
+		Description:   fmt.Sprintf("append question %s to the Questions sheet", qn.Key()),
+		SpreadsheetID: config.SheetID, // Articles DB new
+		Range:         "Questions",
+		Values:        [][]interface{}{questionRow(qn)},
+		Append:        true,
+		InputOption:   "RAW",
+		KeyColumns:    []int{0, 3},
+	}
+}
+
 // AppendQuestion appends a question to the initialised QuestionsDB.
 func AppendQuestion(ctx context.Context, qn Question) error {
 	srv, err := newSheetsService(ctx)
@@ -144,21 +394,131 @@ func AppendQuestion(ctx context.Context, qn Question) error {
 		return fmt.Errorf("unable to start Sheets service: %w", err)
 	}
 
-	backupSheetID := os.Getenv("SHEET_ID") // Articles DB new
-	backupSheetName := "Questions"
+	if err := AppendQuestionWrite(qn).apply(ctx, srv); err != nil {
+		return fmt.Errorf("unable to append question to backup sheet: %w", err)
+	}
 
-	var valueRange sheets.ValueRange
-	valueRange.Values = make([][]interface{}, 0, 1)
+	return nil
+}
 
-	record := make([]interface{}, 0, 5)
-	key := qn.Year + " " + qn.Number
-	record = append(record, key, qn.Year, qn.Number, qn.Wording)
-	valueRange.Values = append(valueRange.Values, record)
+// SortedQuestions returns the questions in qnDB, most recent year first, with A-level questions before school questions, and then by paper and question number.
+func SortedQuestions(qnDB QuestionsDB) []Question {
+	questions := make([]Question, 0, len(qnDB))
+	for _, qn := range qnDB {
+		questions = append(questions, qn)
+	}
+	sort.Slice(questions, func(i, j int) bool {
+		if questions[i].Year != questions[j].Year {
+			return questions[i].Year > questions[j].Year
+		}
+		if questions[i].Source != questions[j].Source {
+			return questions[i].Source < questions[j].Source
+		}
+		if questions[i].Paper != questions[j].Paper {
+			return questions[i].Paper < questions[j].Paper
+		}
+		a, _ := strconv.Atoi(questions[i].Number)
+		b, _ := strconv.Atoi(questions[j].Number)
+		return a < b
+	})
+	return questions
+}
+
+// QuestionUsage returns the number of articles in the database tagged with the question with the given key.
+func QuestionUsage(database *ArticlesDBByDate, key string) int {
+	var n int
+	for _, a := range *database {
+		for _, qn := range a.Questions {
+			if qn.Key() == key {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
 
-	_, err = srv.Spreadsheets.Values.Append(backupSheetID, backupSheetName, &valueRange).InsertDataOption("INSERT_ROWS").ValueInputOption("RAW").Do()
+// UpdateQuestion changes the question with the given key to qn, which may have a different year, question number, paper or source, and updates every article tagged with it and the QuestionCounter to match. It returns the number of articles changed.
+func UpdateQuestion(database *ArticlesDBByDate, qnDB QuestionsDB, qc QuestionCounter, key string, qn Question) (int, error) {
+	old, ok := qnDB[key]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownQuestion, key)
+	}
+
+	qn, err := NormalizeQuestion(qn)
 	if err != nil {
-		return fmt.Errorf("unable to append question to backup sheet: %w", err)
+		return 0, err
 	}
 
-	return nil
+	newKey := qn.Key()
+	if _, ok := qnDB[newKey]; ok && newKey != key {
+		return 0, fmt.Errorf("%w: %s", ErrQuestionExists, qn.Label())
+	}
+
+	n := replaceQuestion(database, qc, old, qn)
+	delete(qnDB, key)
+	qnDB[newKey] = qn
+	return n, nil
+}
+
+// DeleteQuestion removes the question with the given key. Articles tagged with it are tagged with the question with the key reassign instead, and if reassign is empty, the question is only deleted if no articles are tagged with it. It returns the number of articles changed.
+func DeleteQuestion(database *ArticlesDBByDate, qnDB QuestionsDB, qc QuestionCounter, key, reassign string) (int, error) {
+	qn, ok := qnDB[key]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownQuestion, key)
+	}
+
+	var n int
+	if reassign == "" {
+		if uses := QuestionUsage(database, key); uses > 0 {
+			return 0, fmt.Errorf("%w: %s is tagged on %d article(s)", ErrQuestionInUse, qn.Label(), uses)
+		}
+	} else {
+		target, ok := qnDB[reassign]
+		if !ok || reassign == key {
+			return 0, fmt.Errorf("%w: %s", ErrUnknownQuestion, reassign)
+		}
+		n = replaceQuestion(database, qc, qn, target)
+	}
+
+	delete(qnDB, key)
+	delete(qc, qn.Label())
+	return n, nil
+}
+
+// replaceQuestion tags the articles tagged with old with qn instead, without tagging an article with the same question twice, and moves their counts in the QuestionCounter. It returns the number of articles changed.
+func replaceQuestion(database *ArticlesDBByDate, qc QuestionCounter, old, qn Question) int {
+	same := func(a, b Question) bool { return a.Key() == b.Key() }
+
+	var n int
+	for i, a := range *database {
+		var found bool
+		for _, q := range a.Questions {
+			found = found || same(q, old)
+		}
+		if !found {
+			continue
+		}
+
+		questions := make([]Question, 0, len(a.Questions))
+		var tagged bool
+		for _, q := range a.Questions {
+			if same(q, old) || same(q, qn) {
+				qc.Decrement(q.Label())
+				if tagged {
+					continue
+				}
+				q, tagged = qn, true
+				qc.Increment(qn.Label())
+			}
+			questions = append(questions, q)
+		}
+		(*database)[i].Questions = questions
+		n++
+	}
+
+	if _, ok := qc[qn.Label()]; !ok {
+		qc[qn.Label()] = 0
+	}
+	return n
 }
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var testQuestions = [][]interface{}{
+	{"2019 6", "2019", "6", "How far is science a force for good?"},
+	{"2020 1", "2020", "1", "Is censorship ever justified?"},
+}
+
+func TestInitQuestionsDB(t *testing.T) {
+	useFakeSheets(t, nil, testQuestions)
+
+	qnDB, err := InitQuestionsDB(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := QuestionsDB{
+		"2019 6": {Year: "2019", Number: "6", Wording: "How far is science a force for good?"},
+		"2020 1": {Year: "2020", Number: "1", Wording: "Is censorship ever justified?"},
+	}
+	if !reflect.DeepEqual(qnDB, want) {
+		t.Errorf("got %v, want %v", qnDB, want)
+	}
+}
+
+func TestInitQuestionsDBEmpty(t *testing.T) {
+	useFakeSheets(t, nil, nil)
+
+	if _, err := InitQuestionsDB(context.Background()); err == nil {
+		t.Error("expected an error for an empty Questions sheet")
+	}
+}
+
+func TestBackupQuestions(t *testing.T) {
+	fake := useFakeSheets(t, nil, nil)
+
+	qnDB := QuestionsDB{
+		"2019 6": {Year: "2019", Number: "6", Wording: "How far is science a force for good?"},
+		"2020 1": {Year: "2020", Number: "1", Wording: "Is censorship ever justified?"},
+	}
+	if err := BackupQuestions(context.Background(), qnDB); err != nil {
+		t.Fatal(err)
+	}
+
+	got := fake.Values(testSheetID, "Questions")
+	sort.Slice(got, func(i, j int) bool { return got[i][0].(string) < got[j][0].(string) })
+	if !reflect.DeepEqual(got, testQuestions) {
+		t.Errorf("got %v, want %v", got, testQuestions)
+	}
+}
+
+func TestAppendQuestion(t *testing.T) {
+	fake := useFakeSheets(t, nil, testQuestions[:1])
+
+	if err := AppendQuestion(context.Background(), Question{Year: "2020", Number: "1", Wording: "Is censorship ever justified?"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fake.Values(testSheetID, "Questions"); !reflect.DeepEqual(got, testQuestions) {
+		t.Errorf("got %v, want %v", got, testQuestions)
+	}
+}
+
+func TestUpdateAndDeleteQuestion(t *testing.T) {
+	qnDB := QuestionsDB{
+		"2019 6": {Year: "2019", Number: "6", Wording: "How far is science a force for good?"},
+		"2020 1": {Year: "2020", Number: "1", Wording: "Is censorship ever justified?"},
+		"2021 3": {Year: "2021", Number: "3", Wording: "Typo in the key"},
+	}
+	database := &ArticlesDBByDate{
+		{Title: "a", Questions: []Question{qnDB["2019 6"], qnDB["2021 3"]}},
+		{Title: "b", Questions: []Question{qnDB["2021 3"]}},
+		{Title: "c", Questions: []Question{qnDB["2020 1"]}},
+	}
+	qc := QuestionCounter{"2019 - Q6": 1, "2021 - Q3": 2, "2020 - Q1": 1}
+
+	// re-key 2021 Q3 to 2021 Q4 and fix its wording.
+	fixed := Question{Year: "2021", Number: "4", Wording: "Should the arts be funded by the state?"}
+	if n, err := UpdateQuestion(database, qnDB, qc, "2021 3", fixed); err != nil || n != 2 {
+		t.Fatalf("got %d articles changed, error %v, want 2", n, err)
+	}
+	if _, ok := qnDB["2021 3"]; ok || qnDB["2021 4"] != fixed {
+		t.Errorf("question not re-keyed: %v", qnDB)
+	}
+	if got := (*database)[1].Questions; !reflect.DeepEqual(got, []Question{fixed}) {
+		t.Errorf("got article questions %v, want %v", got, []Question{fixed})
+	}
+	if _, err := UpdateQuestion(database, qnDB, qc, "2021 4", Question{Year: "2020", Number: "1", Wording: "clash"}); !errors.Is(err, ErrQuestionExists) {
+		t.Errorf("got error %v re-keying onto an existing question, want ErrQuestionExists", err)
+	}
+
+	// 2021 Q4 is in use, so it can only be deleted by reassigning its articles.
+	if _, err := DeleteQuestion(database, qnDB, qc, "2021 4", ""); !errors.Is(err, ErrQuestionInUse) {
+		t.Errorf("got error %v deleting a question in use, want ErrQuestionInUse", err)
+	}
+	if n, err := DeleteQuestion(database, qnDB, qc, "2021 4", "2019 6"); err != nil || n != 2 {
+		t.Fatalf("got %d articles changed, error %v, want 2", n, err)
+	}
+	if got := (*database)[0].Questions; !reflect.DeepEqual(got, []Question{qnDB["2019 6"]}) {
+		t.Errorf("got article questions %v, want 2019 Q6 once", got)
+	}
+	if want := (QuestionCounter{"2019 - Q6": 2, "2020 - Q1": 1}); !reflect.DeepEqual(qc, want) {
+		t.Errorf("got counter %v, want %v", qc, want)
+	}
+	if len(qnDB) != 2 {
+		t.Errorf("question not deleted: %v", qnDB)
+	}
+}
+
+func TestParseQuestion(t *testing.T) {
+	tests := []struct {
+		tag   string
+		want  Question
+		key   string
+		label string
+	}{
+		{"2019-Q6", Question{Year: "2019", Number: "6"}, "2019 6", "2019 - Q6"},
+		{"2019 6", Question{Year: "2019", Number: "6"}, "2019 6", "2019 - Q6"},
+		{"2019-P1-Q5", Question{Year: "2019", Number: "5"}, "2019 5", "2019 - Q5"},
+		{"2019-P2-Q05", Question{Year: "2019", Number: "5", Paper: "2"}, "2019 P2 5", "2019 - P2 Q5"},
+		{"NJC-Prelim-2022-Q3", Question{Year: "2022", Number: "3", Source: "NJC Prelim"}, "NJC Prelim 2022 3", "NJC Prelim 2022 - Q3"},
+		{"NJC Prelim 2022 P2 8", Question{Year: "2022", Number: "8", Paper: "2", Source: "NJC Prelim"}, "NJC Prelim 2022 P2 8", "NJC Prelim 2022 - P2 Q8"},
+	}
+	for _, tt := range tests {
+		got, err := ParseQuestion(tt.tag)
+		if err != nil {
+			t.Errorf("ParseQuestion(%q): %v", tt.tag, err)
+			continue
+		}
+		if got != tt.want || got.Key() != tt.key || got.Label() != tt.label {
+			t.Errorf("ParseQuestion(%q) = %+v (%q, %q), want %+v (%q, %q)", tt.tag, got, got.Key(), got.Label(), tt.want, tt.key, tt.label)
+		}
+		if again, err := ParseQuestion(got.Tag()); err != nil || again != got {
+			t.Errorf("ParseQuestion(%q) = %+v, %v, want %+v", got.Tag(), again, err, got)
+		}
+	}
+
+	for _, tag := range []string{"Covid 2020", "2019-Q123", "Top 10", "2019"} {
+		if IsQuestionTag(tag) {
+			t.Errorf("IsQuestionTag(%q) = true, want false", tag)
+		}
+	}
+
+	qnDB := QuestionsDB{
+		"2019 6":            {Year: "2019", Number: "6", Wording: "a"},
+		"2019 P2 6":         {Year: "2019", Number: "6", Paper: "2", Level: "H1", Wording: "b"},
+		"NJC Prelim 2022 3": {Year: "2022", Number: "3", Source: "NJC Prelim", Theme: "Media", Wording: "c"},
+	}
+	if qn, err := qnDB.Lookup("njc-prelim-2022-q3"); err != nil || qn.Wording != "c" {
+		t.Errorf("Lookup = %+v, %v, want the NJC Prelim question", qn, err)
+	}
+	database := &ArticlesDBByDate{
+		{Title: "a", Questions: []Question{qnDB["2019 6"]}},
+		{Title: "b", Questions: []Question{qnDB["2019 P2 6"]}},
+		{Title: "c", Questions: []Question{qnDB["NJC Prelim 2022 3"]}},
+	}
+	for _, tt := range []struct {
+		filter QuestionFilter
+		want   string
+	}{
+		{QuestionFilter{Paper: "2"}, "b"},
+		{QuestionFilter{Source: "a level", Paper: "1"}, "a"},
+		{QuestionFilter{Theme: "media"}, "c"},
+	} {
+		got := FilterByQuestion(database, tt.filter)
+		if len(*got) != 1 || (*got)[0].Title != tt.want {
+			t.Errorf("FilterByQuestion(%+v) = %v, want article %s", tt.filter, *got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+// A4 page layout for reading packs, in millimetres.
+const (
+	packMargin   = 15.0
+	packQRSize   = 28.0
+	packLineHt   = 5.5
+	packTextWd   = 210.0 - 2*packMargin - packQRSize - 5
+	packPageHt   = 297.0
+	packBottomMg = 20.0
+)
+
+// ReadingPack is a printable list of articles for a past year question or topic.
+type ReadingPack struct {
+	// Title is printed at the top of the first page, e.g. "2019 - Q6" or "#Environment".
+	Title string
+	// Description is printed below the title, e.g. the question wording.
+	Description string
+	Articles    *ArticlesDBByDate
+}
+
+// NewQuestionReadingPack returns a ReadingPack of every article tagged with the question identified by key, such as "2019 6", "2019-Q6" or "NJC-Prelim-2022-Q3".
+func NewQuestionReadingPack(database *ArticlesDBByDate, qnDB QuestionsDB, key string) (*ReadingPack, error) {
+	qn, err := qnDB.Lookup(key)
+	if err != nil {
+		return nil, err
+	}
+
+	articles, err := FilterArticles(database, nil, "", "", qn.Key())
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReadingPack{
+		Title:       qn.Label(),
+		Description: qn.Wording,
+		Articles:    articles,
+	}, nil
+}
+
+// NewTopicReadingPack returns a ReadingPack of every article tagged with topic, or with any of its subtopics in tt.
+func NewTopicReadingPack(database *ArticlesDBByDate, tt TopicTree, topic string) (*ReadingPack, error) {
+	topic = strings.TrimSpace(topic)
+	if topic == "" {
+		return nil, ErrMissingField
+	}
+
+	articles, err := FilterArticles(database, tt, "", topic, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReadingPack{
+		Title:       "#" + strings.Title(topic),
+		Description: fmt.Sprintf("Articles tagged with the topic %s.", strings.Title(topic)),
+		Articles:    articles,
+	}, nil
+}
+
+// WritePDF renders the reading pack as an A4 PDF and writes it to w. Each article is listed with its title, source, date, URL, topics, and a QR code linking to the article.
+func (rp *ReadingPack) WritePDF(w io.Writer) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(packMargin, packMargin, packMargin)
+	pdf.SetAutoPageBreak(true, packBottomMg)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	generated := time.Now().Format("Jan 2, 2006")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-packBottomMg + 5)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(0, 5, tr(fmt.Sprintf("NJC GP News Feed reading pack - %s - generated %s", rp.Title, generated)), "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 5, fmt.Sprintf("Page %d of {nb}", pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+	pdf.AliasNbPages("")
+	pdf.AddPage()
+
+	// cover details
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.SetTextColor(198, 40, 40)
+	pdf.MultiCell(0, 9, tr("Reading pack: "+rp.Title), "", "L", false)
+	pdf.SetTextColor(0, 0, 0)
+	if rp.Description != "" {
+		pdf.SetFont("Helvetica", "I", 12)
+		pdf.MultiCell(0, 6, tr(rp.Description), "", "L", false)
+	}
+	pdf.Ln(2)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.MultiCell(0, 5, fmt.Sprintf("%d article(s), most recent first.", rp.Articles.Len()), "", "L", false)
+	pdf.Ln(3)
+	pdf.Line(packMargin, pdf.GetY(), 210-packMargin, pdf.GetY())
+	pdf.Ln(4)
+
+	for i, a := range *rp.Articles {
+		if err := rp.writeArticle(pdf, tr, i+1, a); err != nil {
+			return err
+		}
+	}
+
+	if err := pdf.Output(w); err != nil {
+		return fmt.Errorf("unable to write reading pack: %w", err)
+	}
+	return nil
+}
+
+func (rp *ReadingPack) writeArticle(pdf *gofpdf.Fpdf, tr func(string) string, n int, a Article) error {
+	title := fmt.Sprintf("%d. %s", n, a.Title)
+	topics := make([]string, 0, len(a.Topics))
+	for _, t := range a.Topics {
+		topics = append(topics, string(t))
+	}
+
+	// keep each article on a single page.
+	pdf.SetFont("Helvetica", "B", 12)
+	lines := len(pdf.SplitLines([]byte(tr(title)), packTextWd))
+	height := float64(lines)*6 + 4*packLineHt
+	if height < packQRSize {
+		height = packQRSize
+	}
+	if pdf.GetY()+height > packPageHt-packBottomMg {
+		pdf.AddPage()
+	}
+
+	top := pdf.GetY()
+	left := pdf.GetX()
+
+	png, err := qrcode.Encode(a.URL, qrcode.Medium, 256)
+	if err != nil {
+		return fmt.Errorf("unable to generate QR code for %s: %w", a.URL, err)
+	}
+	name := fmt.Sprintf("qr-%d", n)
+	opts := gofpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader(name, opts, bytes.NewReader(png))
+	pdf.ImageOptions(name, 210-packMargin-packQRSize, top, packQRSize, packQRSize, false, opts, 0, a.URL)
+
+	pdf.MultiCell(packTextWd, 6, tr(title), "", "L", false)
+
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetX(left)
+	pdf.MultiCell(packTextWd, packLineHt, tr(fmt.Sprintf("Source: %s    Published: %s", articleSource(a), a.DisplayDate)), "", "L", false)
+
+	pdf.SetX(left)
+	pdf.SetTextColor(21, 101, 192)
+	pdf.SetFont("Helvetica", "U", 9)
+	pdf.CellFormat(packTextWd, packLineHt, tr(truncate(a.URL, 95)), "", 1, "L", false, 0, a.URL)
+	pdf.SetTextColor(0, 0, 0)
+
+	if len(topics) > 0 {
+		pdf.SetX(left)
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.MultiCell(packTextWd, packLineHt, tr("Topics: "+strings.Join(topics, ", ")), "", "L", false)
+	}
+
+	if pdf.GetY() < top+packQRSize {
+		pdf.SetY(top + packQRSize)
+	}
+	pdf.Ln(3)
+	pdf.SetDrawColor(200, 200, 200)
+	pdf.Line(packMargin, pdf.GetY(), 210-packMargin, pdf.GetY())
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.Ln(4)
+
+	return pdf.Error()
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
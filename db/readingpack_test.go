@@ -0,0 +1,65 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReadingPack(t *testing.T) {
+	database := exportTestArticles(t)
+
+	rp, err := NewQuestionReadingPack(database, testQuestionsDB, "2019-Q6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp.Title != "2019 - Q6" || rp.Description != testQuestionsDB["2019 6"].Wording || rp.Articles.Len() != 2 {
+		t.Errorf("got pack %q (%q) with %d articles, want 2019 - Q6 with its wording and 2 articles", rp.Title, rp.Description, rp.Articles.Len())
+	}
+	if _, err := NewQuestionReadingPack(database, testQuestionsDB, "2018 3"); !errors.Is(err, ErrUnknownQuestion) {
+		t.Errorf("got %v for a question not in the database, want ErrUnknownQuestion", err)
+	}
+
+	rp, err = NewTopicReadingPack(database, nil, " economy ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp.Title != "#Economy" || rp.Articles.Len() != 2 {
+		t.Errorf("got pack %q with %d articles, want #Economy with 2", rp.Title, rp.Articles.Len())
+	}
+	if _, err := NewTopicReadingPack(database, nil, " "); !errors.Is(err, ErrMissingField) {
+		t.Errorf("got %v for an empty topic, want ErrMissingField", err)
+	}
+}
+
+func TestReadingPackWritePDF(t *testing.T) {
+	// enough articles, with titles and notes outside Latin-1, to run over several pages.
+	articles := NewArticlesDBByDate()
+	for i := 0; i < 12; i++ {
+		*articles = append(*articles, Article{
+			Title:       fmt.Sprintf("Article %d: “smart” cities – are they worth it? 智慧城市", i),
+			URL:         fmt.Sprintf("https://example.com/%d", i),
+			DisplayDate: "Mar 1, 2021",
+			Topics:      []Topic{"Technology", "Cities"},
+			Summary:     strings.Repeat("A long summary of the article. ", 10),
+		})
+	}
+
+	for _, rp := range []*ReadingPack{
+		{Title: "#Technology", Description: "Articles tagged with the topic Technology.", Articles: articles},
+		{Title: "#Empty", Articles: NewArticlesDBByDate()},
+	} {
+		var b bytes.Buffer
+		if err := rp.WritePDF(&b); err != nil {
+			t.Fatalf("%s: %v", rp.Title, err)
+		}
+		if !bytes.HasPrefix(b.Bytes(), []byte("%PDF-")) || !bytes.Contains(b.Bytes(), []byte("%%EOF")) {
+			t.Errorf("%s: got %d bytes that are not a complete PDF", rp.Title, b.Len())
+		}
+		if pages := bytes.Count(b.Bytes(), []byte("/Type /Page\n")); rp.Articles.Len() > 0 && pages < 2 {
+			t.Errorf("%s: got %d page(s) for %d articles, want several", rp.Title, pages, rp.Articles.Len())
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PublishTimeFormat is the layout of the publish time of scheduled articles in the Articles sheet, in the server's local time.
+const PublishTimeFormat = "Jan 2, 2006 15:04"
+
+// publishTimeInput is the layout of the publish time sent by datetime-local inputs in the add and edit article forms.
+const publishTimeInput = "2006-01-02T15:04"
+
+// ParsePublishTime parses a publish time in PublishTimeFormat, or as sent by the article forms, in the server's local time, and returns it as a Unix time. An empty publish time is 0, for articles that are not scheduled.
+func ParsePublishTime(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	for _, layout := range []string{PublishTimeFormat, publishTimeInput} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("%w %q", ErrInvalidDate, s)
+}
+
+// PublishTime returns the time at which the article is to be published in PublishTimeFormat, or an empty string if it is not scheduled.
+func (a Article) PublishTime() string {
+	if a.PublishAt == 0 {
+		return ""
+	}
+	return time.Unix(a.PublishAt, 0).Format(PublishTimeFormat)
+}
+
+// PublishInput returns the time at which the article is to be published as the value of a datetime-local input, or an empty string if it is not scheduled.
+func (a Article) PublishInput() string {
+	if a.PublishAt == 0 {
+		return ""
+	}
+	return time.Unix(a.PublishAt, 0).Format(publishTimeInput)
+}
+
+// IsDue reports whether the article is scheduled to be published at or before now.
+func (a Article) IsDue(now time.Time) bool {
+	return a.Status == Scheduled && a.PublishAt <= now.Unix()
+}
+
+// HasDue reports whether any article in the database is scheduled to be published at or before now.
+func (db ArticlesDBByDate) HasDue(now time.Time) bool {
+	for _, a := range db {
+		if a.IsDue(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// PublishDue publishes the articles in the database that are scheduled to be published at or before now, and returns them.
+func (db ArticlesDBByDate) PublishDue(now time.Time) []Article {
+	var published []Article
+	for i := range db {
+		if db[i].IsDue(now) {
+			db[i].Status = Published
+			db[i].PublishAt = 0
+			published = append(published, db[i])
+		}
+	}
+	return published
+}
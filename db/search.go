@@ -26,7 +26,7 @@ func SearchAll(term string, database *ArticlesDBByDate) *ArticlesDBByDate {
 	results := NewArticlesDBByDate()
 
 	for _, i := range *database {
-		if !searchTitle(term, i) && !searchTopics(term, i) && !searchQuestions(term, i) && !searchDate(term, i) {
+		if !searchTitle(term, i) && !searchTopics(term, i) && !searchQuestions(term, i) && !searchDate(term, i) && !searchNotes(term, i) {
 			continue
 		} else {
 			*results = append(*results, i)
@@ -61,7 +61,7 @@ func SearchOR(term string, database *ArticlesDBByDate) *ArticlesDBByDate {
 	for _, t := range terms {
 		t = strings.TrimSpace(t)
 		for _, i := range *database {
-			if !searchTitle(t, i) && !searchTopics(t, i) && !searchQuestions(t, i) && !searchDate(t, i) {
+			if !searchTitle(t, i) && !searchTopics(t, i) && !searchQuestions(t, i) && !searchDate(t, i) && !searchNotes(t, i) {
 				continue
 			} else {
 				*results = append(*results, i)
@@ -81,7 +81,7 @@ func SearchNOT(term string, database *ArticlesDBByDate) *ArticlesDBByDate {
 	for _, exclude := range termsToExclude {
 		exclude = strings.TrimSpace(exclude)
 		for _, i := range *database {
-			if searchTitle(exclude, i) || searchTopics(exclude, i) || searchQuestions(exclude, i) || searchDate(exclude, i) {
+			if searchTitle(exclude, i) || searchTopics(exclude, i) || searchQuestions(exclude, i) || searchDate(exclude, i) || searchNotes(exclude, i) {
 				continue
 			} else {
 				*temp = append(*temp, i)
@@ -93,7 +93,7 @@ func SearchNOT(term string, database *ArticlesDBByDate) *ArticlesDBByDate {
 
 	for _, i := range *database {
 		t := strings.TrimSpace(terms[0])
-		if !searchTitle(t, i) && !searchTopics(t, i) && !searchQuestions(t, i) && !searchDate(t, i) {
+		if !searchTitle(t, i) && !searchTopics(t, i) && !searchQuestions(t, i) && !searchDate(t, i) && !searchNotes(t, i) {
 			continue
 		} else {
 			*results = append(*results, i)
@@ -103,6 +103,32 @@ func SearchNOT(term string, database *ArticlesDBByDate) *ArticlesDBByDate {
 	return results
 }
 
+// SearchTopic returns the articles tagged with topic, or with any of its subtopics in tt. Topics are matched regardless of case.
+func SearchTopic(topic Topic, tt TopicTree, database *ArticlesDBByDate) *ArticlesDBByDate {
+	results := NewArticlesDBByDate()
+
+	match := map[string]bool{strings.ToLower(string(topic)): true}
+	for child, parent := range tt {
+		for _, t := range []Topic{child, parent} {
+			if strings.EqualFold(string(t), string(topic)) {
+				for _, d := range tt.Descendants(t) {
+					match[strings.ToLower(string(d))] = true
+				}
+			}
+		}
+	}
+
+	for _, a := range *database {
+		for _, t := range a.Topics {
+			if match[strings.ToLower(string(t))] {
+				*results = append(*results, a)
+				break
+			}
+		}
+	}
+	return results
+}
+
 func searchTitle(term string, a Article) bool {
 	rx := regexp.MustCompile(`\b` + strings.ToLower(term) + `\b`)
 	return rx.MatchString(strings.ToLower(a.Title))
@@ -118,7 +144,6 @@ func searchTopics(term string, a Article) bool {
 
 func searchQuestions(term string, a Article) bool {
 	searchYr := regexp.MustCompile(`^\d{4}$`)
-	searchYrAndQn := regexp.MustCompile(`^\d{4}\s?-?\s?(q|Q)\d{1,2}$`)
 	searchQnNo := regexp.MustCompile(`^(q|Q)\d{1,2}$`)
 
 	switch {
@@ -132,13 +157,13 @@ func searchQuestions(term string, a Article) bool {
 		for _, j := range a.Questions {
 			return j.Number == qnNumber
 		}
-	case searchYrAndQn.MatchString(term):
-		cutQnNo := regexp.MustCompile(`(q|Q)\d{1,2}`)
-		qnNumber := strings.TrimLeft(strings.ToLower(cutQnNo.FindString(term)), "q")
-		cutYear := regexp.MustCompile(`\d{4}`)
-		year := cutYear.FindString(term)
+	case IsQuestionTag(term):
+		qn, err := ParseQuestion(term)
+		if err != nil {
+			return false
+		}
 		for _, j := range a.Questions {
-			if j.Number == qnNumber && j.Year == year {
+			if strings.EqualFold(j.Key(), qn.Key()) {
 				return true
 			}
 		}
@@ -155,3 +180,17 @@ func searchDate(term string, a Article) bool {
 	rx := regexp.MustCompile(`\b` + strings.ToLower(term) + `\b`)
 	return rx.MatchString(strings.ToLower(a.DisplayDate))
 }
+
+// searchNotes reports whether the term is found in the article's summary, teacher's note or discussion prompts.
+func searchNotes(term string, a Article) bool {
+	rx := regexp.MustCompile(`\b` + strings.ToLower(term) + `\b`)
+	if rx.MatchString(strings.ToLower(a.Summary)) || rx.MatchString(strings.ToLower(a.Note)) {
+		return true
+	}
+	for _, p := range a.Prompts {
+		if rx.MatchString(strings.ToLower(p)) {
+			return true
+		}
+	}
+	return false
+}
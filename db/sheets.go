@@ -4,21 +4,64 @@ package db
 import (
 	"context"
 	"fmt"
-	"os"
 
 	"google.golang.org/api/option"
 	"google.golang.org/api/sheets/v4"
 )
 
-func newSheetsService(ctx context.Context) (*sheets.Service, error) {
-	b := os.Getenv("CREDENTIALS")
+// SheetsClient is the subset of the Google Sheets values API used by the db package. Ranges are given in A1 notation, e.g. "Articles" for a whole sheet.
+type SheetsClient interface {
+	// Get returns the values in the range.
+	Get(ctx context.Context, spreadsheetID, readRange string) ([][]interface{}, error)
+	// Update overwrites the range with values, starting from its top left cell.
+	Update(ctx context.Context, spreadsheetID, writeRange string, values [][]interface{}, inputOption string) error
+	// Append inserts values as new rows after the last row of data in the range.
+	Append(ctx context.Context, spreadsheetID, appendRange string, values [][]interface{}, inputOption string) error
+}
+
+var sheetsClient SheetsClient
+
+// UseSheetsClient sets the client used for every call to Google Sheets, e.g. a FakeSheets for tests or offline development. Passing nil restores the default client, which connects to Google with the credentials set by Configure. It is not safe to call UseSheetsClient while other db functions are running.
+func UseSheetsClient(c SheetsClient) {
+	sheetsClient = c
+}
+
+func newSheetsService(ctx context.Context) (SheetsClient, error) {
+	if sheetsClient != nil {
+		return sheetsClient, nil
+	}
+
+	srv, err := sheets.NewService(ctx, option.WithCredentialsJSON([]byte(config.Credentials)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve Sheets client: %v", err)
+	}
+
+	return &googleSheets{srv}, nil
+}
+
+// googleSheets implements SheetsClient with the Google Sheets API.
+type googleSheets struct {
+	srv *sheets.Service
+}
 
-	srv, err := sheets.NewService(ctx, option.WithCredentialsJSON([]byte(b)))
+func (g *googleSheets) Get(ctx context.Context, spreadsheetID, readRange string) ([][]interface{}, error) {
+	resp, err := g.srv.Spreadsheets.Values.Get(spreadsheetID, readRange).Context(ctx).Do()
 	if err != nil {
-		return srv, fmt.Errorf("unable to retrieve Sheets client: %v", err)
+		return nil, err
 	}
+	return resp.Values, nil
+}
+
+func (g *googleSheets) Update(ctx context.Context, spreadsheetID, writeRange string, values [][]interface{}, inputOption string) error {
+	vr := &sheets.ValueRange{Values: values}
+	_, err := g.srv.Spreadsheets.Values.Update(spreadsheetID, writeRange, vr).ValueInputOption(inputOption).Context(ctx).Do()
+	return err
+}
 
-	return srv, nil
+func (g *googleSheets) Append(ctx context.Context, spreadsheetID, appendRange string, values [][]interface{}, inputOption string) error {
+	vr := &sheets.ValueRange{Values: values}
+	_, err := g.srv.Spreadsheets.Values.Append(spreadsheetID, appendRange, vr).InsertDataOption("INSERT_ROWS").ValueInputOption(inputOption).Context(ctx).Do()
+	return err
 }
 
 type sheetData struct {
@@ -27,18 +70,18 @@ type sheetData struct {
 	Values [][]interface{}
 }
 
-func getSheetData(srv *sheets.Service, sheetRange string) (*sheetData, error) {
+func getSheetData(ctx context.Context, srv SheetsClient, sheetRange string) (*sheetData, error) {
 	var sd sheetData
 
-	sd.ID = os.Getenv("SHEET_ID")
+	sd.ID = config.SheetID
 	sd.Range = sheetRange
 
-	resp, err := srv.Spreadsheets.Values.Get(sd.ID, sd.Range).Do()
+	values, err := srv.Get(ctx, sd.ID, sd.Range)
 	if err != nil {
 		return &sd, fmt.Errorf("unable to retrieve data from sheet: %v", err)
 	}
 
-	sd.Values = resp.Values
+	sd.Values = values
 
 	return &sd, nil
 }
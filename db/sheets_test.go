@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const (
+	testSheetID    = "test-sheet"
+	testOldSheetID = "test-old-sheet"
+)
+
+// useFakeSheets installs a FakeSheets seeded with the given Articles and Questions sheets for the duration of the test.
+func useFakeSheets(t *testing.T, articles, questions [][]interface{}) *FakeSheets {
+	t.Helper()
+
+	old := config
+	cfg := DefaultConfig()
+	cfg.SheetID = testSheetID
+	cfg.OldSheetID = testOldSheetID
+	Configure(cfg)
+	t.Cleanup(func() { Configure(old) })
+
+	fake := NewFakeSheets()
+	fake.SetValues(testSheetID, "Articles", articles)
+	fake.SetValues(testSheetID, "Questions", questions)
+	fake.SetValues(testOldSheetID, "feed", nil)
+
+	UseSheetsClient(fake)
+	t.Cleanup(func() { UseSheetsClient(nil) })
+
+	return fake
+}
+
+func TestFakeSheetsUpdate(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFakeSheets()
+	fake.SetValues("id", "Sheet1", [][]interface{}{
+		{"a", "b", "c"},
+		{"d", "e", "f"},
+		{"g", "h", "i"},
+	})
+
+	if err := fake.Update(ctx, "id", "Sheet1", [][]interface{}{{"x"}, {"y", "z"}}, "RAW"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := fake.Get(ctx, "id", "Sheet1!A1:C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]interface{}{
+		{"x", "b", "c"},
+		{"y", "z", "f"},
+		{"g", "h", "i"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFakeSheetsAppend(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFakeSheets()
+	fake.SetValues("id", "Sheet1", [][]interface{}{{"a", ""}, {}, {""}})
+
+	if err := fake.Append(ctx, "id", "Sheet1", [][]interface{}{{"b", 2}}, "RAW"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := fake.Values("id", "Sheet1")
+	want := [][]interface{}{{"a"}, {"b", "2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFakeSheetsGetUnknownSpreadsheet(t *testing.T) {
+	if _, err := NewFakeSheets().Get(context.Background(), "missing", "Articles"); err == nil {
+		t.Error("expected an error for a missing spreadsheet")
+	}
+}
+
+func TestLoadFakeSheets(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sheets.json")
+
+	fake, err := LoadFakeSheets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.Append(ctx, "id", "Questions", [][]interface{}{{"2019 6", "2019", "6", "Wording"}}, "RAW"); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := LoadFakeSheets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reloaded.Get(ctx, "id", "Questions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]interface{}{{"2019 6", "2019", "6", "Wording"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,199 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Snapshot is a copy of the articles and questions databases, the number of articles per topic, and the topic hierarchy, at a point in time, which can be saved to a file and restored later. TopicTree is nil in snapshots taken without the hierarchy, such as those from before it was saved, and restoring them leaves the hierarchy as it is.
+type Snapshot struct {
+	Created   time.Time     `json:"created"`
+	Articles  []Record      `json:"articles"`
+	Questions []Question    `json:"questions"`
+	Topics    map[Topic]int `json:"topics"`
+	TopicTree TopicTree     `json:"topic_tree,omitempty"`
+}
+
+// NewSnapshot returns a Snapshot of the articles and questions databases and the topic hierarchy as they are now. tt is nil if the hierarchy could not be read, so that restoring the snapshot does not replace it with an empty one.
+func NewSnapshot(database *ArticlesDBByDate, qnDB QuestionsDB, tt TopicTree) *Snapshot {
+	sn := &Snapshot{
+		Created:   time.Now(),
+		Articles:  make([]Record, 0, len(*database)),
+		Questions: make([]Question, 0, len(qnDB)),
+		Topics:    make(map[Topic]int),
+	}
+
+	if tt != nil {
+		sn.TopicTree = make(TopicTree, len(tt))
+		for child, parent := range tt {
+			sn.TopicTree[child] = parent
+		}
+	}
+
+	for _, a := range *database {
+		for _, t := range a.Topics {
+			sn.Topics[t]++
+		}
+	}
+
+	for _, a := range *database {
+		sn.Articles = append(sn.Articles, NewRecord(a))
+	}
+
+	keys := make([]string, 0, len(qnDB))
+	for k := range qnDB {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sn.Questions = append(sn.Questions, qnDB[k])
+	}
+
+	return sn
+}
+
+// Write writes the snapshot to w as JSON.
+func (sn *Snapshot) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(sn); err != nil {
+		return fmt.Errorf("unable to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// ReadSnapshot reads a snapshot written by Snapshot.Write, or a gzip-compressed one saved by a SnapshotStore.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read snapshot: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+
+	var sn Snapshot
+	if err := json.NewDecoder(r).Decode(&sn); err != nil {
+		return nil, fmt.Errorf("unable to read snapshot: %w", err)
+	}
+	return &sn, nil
+}
+
+// Load rebuilds the articles and questions databases, and their topic and question counts, from the snapshot. Every article is validated with ParseArticle against the snapshot's questions, and an error is returned for the first one that fails.
+func (sn *Snapshot) Load() (*ArticlesDBByDate, QuestionsDB, TopicsMap, QuestionCounter, error) {
+	qnDB := make(QuestionsDB, len(sn.Questions))
+	for _, qn := range sn.Questions {
+		qnDB[qn.Key()] = qn
+	}
+
+	database := NewArticlesDBByDate()
+	tm := InitTopicsMap()
+	qc := InitQuestionCounter()
+	for i, rec := range sn.Articles {
+		a, err := ParseArticle(rec, qnDB)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("article %d (%q) in snapshot: %w", i+1, rec.Title, err)
+		}
+		a.AddArticleToDB(database, tm, qc)
+	}
+	qc.GetZeroArticleQns(qnDB)
+
+	return database, qnDB, tm, qc, nil
+}
+
+// RecordChange is an article that differs between the current database and a snapshot.
+type RecordChange struct {
+	Current  Record
+	Snapshot Record
+}
+
+// QuestionChange is a question whose wording differs between the current database and a snapshot.
+type QuestionChange struct {
+	Current  Question
+	Snapshot Question
+}
+
+// SnapshotDiff describes what restoring a snapshot would change. Added articles and questions are in the snapshot but not in the current database, so restoring brings them back; Removed ones are only in the current database, so restoring deletes them.
+type SnapshotDiff struct {
+	Added            []Record
+	Removed          []Record
+	Changed          []RecordChange
+	QuestionsAdded   []Question
+	QuestionsRemoved []Question
+	QuestionsChanged []QuestionChange
+	// TopicTreeChanged is whether restoring the snapshot would change the topic hierarchy.
+	TopicTreeChanged bool
+}
+
+// Empty reports whether restoring the snapshot would change nothing.
+func (d SnapshotDiff) Empty() bool {
+	return len(d.Added)+len(d.Removed)+len(d.Changed)+len(d.QuestionsAdded)+len(d.QuestionsRemoved)+len(d.QuestionsChanged) == 0 && !d.TopicTreeChanged
+}
+
+// Diff compares the snapshot with the current articles and questions databases and topic hierarchy. Articles are matched by URL and questions by year and question number.
+func (sn *Snapshot) Diff(database *ArticlesDBByDate, qnDB QuestionsDB, tt TopicTree) SnapshotDiff {
+	var d SnapshotDiff
+
+	if sn.TopicTree != nil {
+		d.TopicTreeChanged = len(sn.TopicTree) != len(tt)
+		for child, parent := range sn.TopicTree {
+			if p, ok := tt[child]; !ok || p != parent {
+				d.TopicTreeChanged = true
+			}
+		}
+	}
+
+	current := recordsByURL(database)
+	inSnapshot := make(map[string]bool, len(sn.Articles))
+	for _, rec := range sn.Articles {
+		inSnapshot[rec.URL] = true
+		cur, ok := current[rec.URL]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, rec)
+		case !sameRecord(cur, rec):
+			d.Changed = append(d.Changed, RecordChange{Current: cur, Snapshot: rec})
+		}
+	}
+	for _, a := range *database {
+		if !inSnapshot[a.URL] {
+			d.Removed = append(d.Removed, NewRecord(a))
+		}
+	}
+
+	snQns := make(map[string]bool, len(sn.Questions))
+	for _, qn := range sn.Questions {
+		key := qn.Key()
+		snQns[key] = true
+		cur, ok := qnDB[key]
+		switch {
+		case !ok:
+			d.QuestionsAdded = append(d.QuestionsAdded, qn)
+		case cur.Wording != qn.Wording:
+			d.QuestionsChanged = append(d.QuestionsChanged, QuestionChange{Current: cur, Snapshot: qn})
+		}
+	}
+	keys := make([]string, 0, len(qnDB))
+	for k := range qnDB {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !snQns[k] {
+			d.QuestionsRemoved = append(d.QuestionsRemoved, qnDB[k])
+		}
+	}
+
+	return d
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	_, database, qnDB, tm, qc, _ := initSyncTest(t)
+	tt := TopicTree{"Technology": "Science", "Science": ""}
+
+	var buf bytes.Buffer
+	if err := NewSnapshot(database, qnDB, tt).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	sn, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotDB, gotQnDB, gotTM, gotQC, err := sn.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotDB, database) || !reflect.DeepEqual(gotQnDB, qnDB) {
+		t.Errorf("restored databases do not match the originals")
+	}
+	if !reflect.DeepEqual(gotTM, tm) || !reflect.DeepEqual(gotQC, qc) {
+		t.Errorf("got counts %v %v, want %v %v", gotTM, gotQC, tm, qc)
+	}
+	if !reflect.DeepEqual(sn.TopicTree, tt) {
+		t.Errorf("got topic hierarchy %v, want %v", sn.TopicTree, tt)
+	}
+	if d := sn.Diff(gotDB, gotQnDB, tt); !d.Empty() {
+		t.Errorf("got diff %+v against the same databases, want it empty", d)
+	}
+
+	tt.Rename("Technology", "Tech")
+	if d := sn.Diff(gotDB, gotQnDB, tt); !d.TopicTreeChanged {
+		t.Error("want a renamed topic in the hierarchy to be a change")
+	}
+}
+
+func TestPullQuestions(t *testing.T) {
+	fake, _, qnDB, _, _, _ := initSyncTest(t)
+
+	rows := append(fake.Values(testSheetID, "Questions"), []interface{}{"2021 3", "2021", "3", "Is art a luxury?"}, []interface{}{"short"})
+	fake.SetValues(testSheetID, "Questions", rows)
+
+	n, err := PullQuestions(context.Background(), qnDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || qnDB["2021 3"].Wording != "Is art a luxury?" {
+		t.Errorf("got %d added and %v, want the new question", n, qnDB["2021 3"])
+	}
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	_, database, qnDB, tm, qc, _ := initSyncTest(t)
+	sn := NewSnapshot(database, qnDB, nil)
+
+	a := (*database)[0]
+	a.Title = "Edited"
+	if err := database.EditArticle("0", a, tm, qc); err != nil {
+		t.Fatal(err)
+	}
+	database.RemoveArticle(1, tm, qc)
+	added, err := ParseArticle(Record{Title: "Added", URL: "https://example.com/added", Topics: []string{"Media"}, Date: "Mar 4, 2021"}, qnDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added.AddArticleToDB(database, tm, qc)
+	delete(qnDB, "2020 1")
+
+	d := sn.Diff(database, qnDB, nil)
+	if len(d.Added) != 1 || d.Added[0].Title != "Older article" {
+		t.Errorf("got added %v, want the removed article", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Title != "Added" {
+		t.Errorf("got removed %v, want the added article", d.Removed)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].Current.Title != "Edited" || d.Changed[0].Snapshot.Title != "Newer article" {
+		t.Errorf("got changed %v, want the edited article", d.Changed)
+	}
+	if len(d.QuestionsAdded) != 1 || d.QuestionsAdded[0].Year != "2020" {
+		t.Errorf("got questions added %v, want 2020 Q1", d.QuestionsAdded)
+	}
+}
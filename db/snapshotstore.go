@@ -0,0 +1,193 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reasons for taking a snapshot, recorded in its ID.
+const (
+	SnapshotScheduled     = "scheduled"
+	SnapshotManual        = "manual"
+	SnapshotBeforeDelete  = "before-delete"
+	SnapshotBeforeEdit    = "before-edit"
+	SnapshotBeforeImport  = "before-import"
+	SnapshotBeforeRename  = "before-rename"
+	SnapshotBeforeResolve = "before-resolve"
+	SnapshotBeforeRestore = "before-restore"
+)
+
+const snapshotTimeFormat = "20060102T150405Z"
+
+var snapshotName = regexp.MustCompile(`^(\d{8}T\d{6}Z)-([a-z-]+)\.json\.gz$`)
+
+// RetentionPolicy decides which snapshots a SnapshotStore keeps. The newest Last snapshots are always kept, as is the newest snapshot of each of the Daily most recent days that have one. Zero values keep nothing by that rule.
+type RetentionPolicy struct {
+	Last  int
+	Daily int
+}
+
+// SnapshotInfo describes a snapshot saved in a SnapshotStore.
+type SnapshotInfo struct {
+	ID      string
+	Created time.Time
+	Reason  string
+	Size    int64
+}
+
+// Description returns the reason for the snapshot in words.
+func (si SnapshotInfo) Description() string {
+	return strings.Replace(si.Reason, "-", " ", -1)
+}
+
+// SnapshotStore saves timestamped, gzip-compressed snapshots in a directory, and removes old ones according to its RetentionPolicy.
+type SnapshotStore struct {
+	dir    string
+	policy RetentionPolicy
+}
+
+// OpenSnapshotStore returns a SnapshotStore for the directory at dir, creating it if it does not exist.
+func OpenSnapshotStore(dir string, policy RetentionPolicy) (*SnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create snapshot directory: %w", err)
+	}
+	return &SnapshotStore{dir: dir, policy: policy}, nil
+}
+
+// Save saves the snapshot, recording reason as one of the Snapshot constants, and then prunes old snapshots.
+func (st *SnapshotStore) Save(sn *Snapshot, reason string) (SnapshotInfo, error) {
+	info := SnapshotInfo{
+		ID:      sn.Created.UTC().Format(snapshotTimeFormat) + "-" + reason,
+		Created: sn.Created.UTC().Truncate(time.Second),
+		Reason:  reason,
+	}
+	path := st.path(info.ID)
+
+	tmp, err := ioutil.TempFile(st.dir, ".snapshot-*")
+	if err != nil {
+		return info, fmt.Errorf("unable to save snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	if err := sn.Write(zw); err != nil {
+		tmp.Close()
+		return info, err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return info, fmt.Errorf("unable to save snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return info, fmt.Errorf("unable to save snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return info, fmt.Errorf("unable to save snapshot: %w", err)
+	}
+
+	if fi, err := os.Stat(path); err == nil {
+		info.Size = fi.Size()
+	}
+
+	if _, err := st.Prune(); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+// List returns the saved snapshots, newest first.
+func (st *SnapshotStore) List() ([]SnapshotInfo, error) {
+	files, err := ioutil.ReadDir(st.dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list snapshots: %w", err)
+	}
+
+	infos := make([]SnapshotInfo, 0, len(files))
+	for _, fi := range files {
+		m := snapshotName.FindStringSubmatch(fi.Name())
+		if m == nil {
+			continue
+		}
+		created, err := time.Parse(snapshotTimeFormat, m[1])
+		if err != nil {
+			continue
+		}
+		infos = append(infos, SnapshotInfo{
+			ID:      strings.TrimSuffix(fi.Name(), ".json.gz"),
+			Created: created,
+			Reason:  m[2],
+			Size:    fi.Size(),
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID > infos[j].ID })
+	return infos, nil
+}
+
+// Latest returns the newest snapshot, or false if there are none.
+func (st *SnapshotStore) Latest() (SnapshotInfo, bool, error) {
+	infos, err := st.List()
+	if err != nil || len(infos) == 0 {
+		return SnapshotInfo{}, false, err
+	}
+	return infos[0], true, nil
+}
+
+// Open reads the snapshot with the given ID.
+func (st *SnapshotStore) Open(id string) (*Snapshot, error) {
+	if !snapshotName.MatchString(id + ".json.gz") {
+		return nil, fmt.Errorf("invalid snapshot ID %q", id)
+	}
+
+	f, err := os.Open(st.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no snapshot %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	return ReadSnapshot(f)
+}
+
+// Prune removes the snapshots not kept by the retention policy, and returns the number removed.
+func (st *SnapshotStore) Prune() (int, error) {
+	infos, err := st.List()
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int
+	days := make(map[string]bool)
+	for i, info := range infos {
+		day := info.Created.Format("2006-01-02")
+		keep := i < st.policy.Last
+		if !days[day] && len(days) < st.policy.Daily {
+			days[day] = true
+			keep = true
+		}
+		if keep {
+			continue
+		}
+
+		if err := os.Remove(st.path(info.ID)); err != nil {
+			return removed, fmt.Errorf("unable to remove old snapshot: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func (st *SnapshotStore) path(id string) string {
+	return filepath.Join(st.dir, id+".json.gz")
+}
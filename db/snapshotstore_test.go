@@ -0,0 +1,61 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshotStoreRetention(t *testing.T) {
+	st, err := OpenSnapshotStore(t.TempDir(), RetentionPolicy{Last: 2, Daily: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)
+	for _, created := range []time.Time{
+		day,
+		day.Add(24 * time.Hour),
+		day.Add(48 * time.Hour),
+		day.Add(49 * time.Hour),
+		day.Add(72 * time.Hour),
+		day.Add(73 * time.Hour),
+		day.Add(74 * time.Hour),
+	} {
+		sn := &Snapshot{Created: created, Articles: []Record{}, Questions: []Question{}}
+		if _, err := st.Save(sn, SnapshotScheduled); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	infos, err := st.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the last two, plus the newest of each of the three most recent days.
+	var got []string
+	for _, info := range infos {
+		got = append(got, info.Created.Format("Jan 2 15:04"))
+	}
+	want := []string{"Mar 4 11:00", "Mar 4 10:00", "Mar 3 10:00", "Mar 2 09:00"}
+	if len(got) != len(want) {
+		t.Fatalf("got snapshots %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got snapshots %v, want %v", got, want)
+			break
+		}
+	}
+
+	sn, err := st.Open(infos[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sn.Created.Equal(day.Add(74 * time.Hour)) {
+		t.Errorf("opened snapshot from %v, want the newest", sn.Created)
+	}
+	if _, err := st.Open("../config"); err == nil {
+		t.Error("expected an error for an invalid snapshot ID")
+	}
+}
@@ -0,0 +1,267 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sources maps the domain names of news sites, such as straitstimes.com, to the names of their publications, such as The Straits Times. It is backed by the Sources sheet, which has a row of domain and name for each publication added or renamed by the admins.
+type Sources map[string]string
+
+// defaultSources are the publications most often curated, which are named without needing a row in the Sources sheet.
+var defaultSources = Sources{
+	"straitstimes.com":       "The Straits Times",
+	"businesstimes.com.sg":   "The Business Times",
+	"channelnewsasia.com":    "CNA",
+	"todayonline.com":        "TODAY",
+	"mothership.sg":          "Mothership",
+	"ricemedia.co":           "Rice Media",
+	"zaobao.com.sg":          "Lianhe Zaobao",
+	"scmp.com":               "South China Morning Post",
+	"bbc.co.uk":              "BBC",
+	"bbc.com":                "BBC",
+	"theguardian.com":        "The Guardian",
+	"nytimes.com":            "The New York Times",
+	"washingtonpost.com":     "The Washington Post",
+	"economist.com":          "The Economist",
+	"ft.com":                 "Financial Times",
+	"reuters.com":            "Reuters",
+	"apnews.com":             "AP News",
+	"aljazeera.com":          "Al Jazeera",
+	"cnn.com":                "CNN",
+	"npr.org":                "NPR",
+	"theatlantic.com":        "The Atlantic",
+	"vox.com":                "Vox",
+	"wired.com":              "Wired",
+	"time.com":               "TIME",
+	"bloomberg.com":          "Bloomberg",
+	"theconversation.com":    "The Conversation",
+	"eastasiaforum.org":      "East Asia Forum",
+	"lowyinstitute.org":      "Lowy Institute",
+	"academia.sg":            "Academia | SG",
+	"psychologytoday.com":    "Psychology Today",
+	"scientificamerican.com": "Scientific American",
+}
+
+// DefaultSources returns a copy of the publications that are named without needing a row in the Sources sheet.
+func DefaultSources() Sources {
+	sr := make(Sources, len(defaultSources))
+	for domain, name := range defaultSources {
+		sr[domain] = name
+	}
+	return sr
+}
+
+// sources is the registry used to name the source of each article. It is replaced with SetSources when the Sources sheet is read or changed.
+var (
+	sourcesMu sync.RWMutex
+	sources   = DefaultSources()
+)
+
+// SetSources makes sr the registry used to name the source of articles parsed from then on. RefreshSources renames the sources of articles already in a database.
+func SetSources(sr Sources) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	sources = sr
+}
+
+// SourceName returns the name of the publication of the article at articleURL, from the registry set with SetSources.
+func SourceName(articleURL string) string {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	return sources.Name(articleURL)
+}
+
+// InitSources reads the publications in the Sources sheet, on top of DefaultSources. Rows that are incomplete are skipped. The defaults are returned with the error if the sheet cannot be read.
+func InitSources(ctx context.Context) (Sources, error) {
+	sr := DefaultSources()
+
+	srv, err := newSheetsService(ctx)
+	if err != nil {
+		return sr, fmt.Errorf("unable to start Sheets service: %w", err)
+	}
+
+	data, err := getSheetData(ctx, srv, "Sources")
+	if err != nil {
+		return sr, fmt.Errorf("unable to get sheet data: %w", err)
+	}
+
+	for _, row := range data.Values {
+		if len(row) < 2 {
+			continue
+		}
+		sr.Set(fmt.Sprint(row[0]), fmt.Sprint(row[1]))
+	}
+	return sr, nil
+}
+
+// SourceDomain returns the host name of articleURL, which may also be a bare domain name, in lower case without the leading "www.", such as "straitstimes.com", or an empty string if it has none.
+func SourceDomain(articleURL string) string {
+	articleURL = strings.TrimSpace(articleURL)
+	if !strings.Contains(articleURL, "://") {
+		articleURL = "//" + articleURL
+	}
+	u, err := url.Parse(articleURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// Set names the publication at domain, which may be given as a URL. An empty name resets the publication to its name in DefaultSources, or removes it so that its articles are named by their domain.
+func (sr Sources) Set(domain, name string) {
+	domain = SourceDomain(domain)
+	name = strings.TrimSpace(name)
+	if domain == "" {
+		return
+	}
+	if name == "" {
+		name = defaultSources[domain]
+	}
+	if name == "" {
+		delete(sr, domain)
+		return
+	}
+	sr[domain] = name
+}
+
+// Name returns the name of the publication of the article at articleURL, or at a bare domain name. Subdomains take the name of their parent domain, so that edition.cnn.com is named CNN, and articles from domains not in the registry are named by their domain. Name returns "Unknown" if articleURL has no host name.
+func (sr Sources) Name(articleURL string) string {
+	domain := SourceDomain(articleURL)
+	if domain == "" {
+		return "Unknown"
+	}
+	for d := domain; strings.Contains(d, "."); d = d[strings.Index(d, ".")+1:] {
+		if name, ok := sr[d]; ok {
+			return name
+		}
+	}
+	return domain
+}
+
+// Copy returns a copy of sr, to be changed and then set with SetSources without racing with readers of the registry.
+func (sr Sources) Copy() Sources {
+	c := make(Sources, len(sr))
+	for domain, name := range sr {
+		c[domain] = name
+	}
+	return c
+}
+
+// BackupSourcesWrite returns the SheetWrite that backs up the publications added or renamed by the admins to the Sources sheet. Publications named as in DefaultSources are left out, so that later changes to the defaults take effect.
+func BackupSourcesWrite(sr Sources) SheetWrite {
+	domains := make([]string, 0, len(sr))
+	for domain, name := range sr {
+		if defaultSources[domain] != name {
+			domains = append(domains, domain)
+		}
+	}
+	sort.Strings(domains)
+
+	values := make([][]interface{}, 0, len(domains))
+	for _, domain := range domains {
+		values = append(values, []interface{}{domain, sr[domain]})
+	}
+
+	return SheetWrite{
+		Description:   "back up the news sources",
+		SpreadsheetID: config.SheetID, // Articles DB new
+		Range:         "Sources",
+		Values:        values,
+		InputOption:   "RAW",
+		Replace:       true,
+	}
+}
+
+// RefreshSources renames the source of every article in the database with the registry set with SetSources, after a publication has been added or renamed.
+func (db ArticlesDBByDate) RefreshSources() {
+	for i := range db {
+		db[i].Source = SourceName(db[i].URL)
+	}
+}
+
+// FilterBySource returns the articles in the database from the named source, ignoring case.
+func FilterBySource(database *ArticlesDBByDate, source string) *ArticlesDBByDate {
+	results := NewArticlesDBByDate()
+	for _, a := range *database {
+		if strings.EqualFold(articleSource(a), strings.TrimSpace(source)) {
+			*results = append(*results, a)
+		}
+	}
+	return results
+}
+
+// SourceDomains returns the number of articles in the database from each domain, most articles first, so that the admins can see which domains need naming.
+func SourceDomains(database *ArticlesDBByDate) []SourceCount {
+	counts := make(map[string]int)
+	for _, a := range *database {
+		if d := SourceDomain(a.URL); d != "" {
+			counts[d]++
+		}
+	}
+	return rankSources(counts)
+}
+
+// MaxSourceShare is the share of recent articles above which the dashboard warns that the feed relies too much on one source.
+const MaxSourceShare = 0.5
+
+// SourceDiversity describes how evenly the articles published since a given time are spread across their sources.
+type SourceDiversity struct {
+	Since    time.Time
+	Articles int
+	Sources  []SourceCount
+}
+
+// NewSourceDiversity returns the SourceDiversity of the articles in the database published since the given time.
+func NewSourceDiversity(database *ArticlesDBByDate, since time.Time) SourceDiversity {
+	d := SourceDiversity{Since: since, Sources: ArticlesBySource(database, since)}
+	for _, sc := range d.Sources {
+		d.Articles += sc.Count
+	}
+	return d
+}
+
+// Top returns the source of the most articles, or a zero SourceCount if there are no articles.
+func (d SourceDiversity) Top() SourceCount {
+	if len(d.Sources) == 0 {
+		return SourceCount{}
+	}
+	return d.Sources[0]
+}
+
+// TopShare returns the share of the articles from the top source, from 0 to 1.
+func (d SourceDiversity) TopShare() float64 {
+	if d.Articles == 0 {
+		return 0
+	}
+	return float64(d.Top().Count) / float64(d.Articles)
+}
+
+// TopPercent returns the percentage of the articles from the top source, rounded to the nearest whole number.
+func (d SourceDiversity) TopPercent() int {
+	return int(d.TopShare()*100 + 0.5)
+}
+
+// Effective returns the effective number of sources, the inverse of the Simpson index: the number of equally used sources that would be as diverse as the actual ones. It is 1 if every article is from the same source, and the number of sources if they are used equally.
+func (d SourceDiversity) Effective() float64 {
+	if d.Articles == 0 {
+		return 0
+	}
+	var sum float64
+	for _, sc := range d.Sources {
+		p := float64(sc.Count) / float64(d.Articles)
+		sum += p * p
+	}
+	return 1 / sum
+}
+
+// Concentrated reports whether more than MaxSourceShare of the articles are from the top source.
+func (d SourceDiversity) Concentrated() bool {
+	return d.TopShare() > MaxSourceShare
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSourcesName(t *testing.T) {
+	sr := DefaultSources()
+	sr.Set("https://www.ricemedia.co/", "RICE")
+	sr.Set("ricemedia.co", "")
+	sr.Set("https://www.todayonline.com/", "Today Online")
+	sr.Set("todayonline.com", "")
+	sr.Set("www.Example.org", " Example Weekly ")
+
+	tests := map[string]string{
+		"https://www.straitstimes.com/singapore/a":   "The Straits Times",
+		"https://www.bbc.co.uk/news/b":               "BBC",
+		"https://edition.cnn.com/c":                  "CNN",
+		"https://example.org/d":                      "Example Weekly",
+		"https://www.ricemedia.co/e":                 "Rice Media",
+		"https://blog.unknown-site.net/f":            "blog.unknown-site.net",
+		"not a url":                                  "Unknown",
+		"https://www.channelnewsasia.com/commentary": "CNA",
+	}
+	for articleURL, want := range tests {
+		if got := sr.Name(articleURL); got != want {
+			t.Errorf("Name(%q) = %q, want %q", articleURL, got, want)
+		}
+	}
+
+	w := BackupSourcesWrite(sr)
+	if len(w.Values) != 1 || w.Values[0][0] != "example.org" || w.Values[0][1] != "Example Weekly" {
+		t.Errorf("got backup rows %v, want only the added source", w.Values)
+	}
+}
+
+func TestSourceDiversity(t *testing.T) {
+	at := func(y int, m time.Month, d int) int64 { return time.Date(y, m, d, 9, 0, 0, 0, time.UTC).Unix() }
+	database := &ArticlesDBByDate{
+		{URL: "https://www.straitstimes.com/a", Source: "The Straits Times", Date: at(2022, 3, 16)},
+		{URL: "https://www.straitstimes.com/b", Source: "The Straits Times", Date: at(2022, 3, 14)},
+		{URL: "https://www.straitstimes.com/c", Source: "The Straits Times", Date: at(2022, 3, 13)},
+		{URL: "https://www.bbc.com/d", Source: "BBC", Date: at(2022, 3, 10)},
+		{URL: "https://www.bbc.com/e", Source: "BBC", Date: at(2021, 1, 1)},
+	}
+
+	d := NewSourceDiversity(database, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	if d.Articles != 4 || d.Top() != (SourceCount{"The Straits Times", 3}) {
+		t.Fatalf("got %d articles with top source %+v, want 4 with The Straits Times (3)", d.Articles, d.Top())
+	}
+	if !d.Concentrated() {
+		t.Errorf("got share %.2f not concentrated, want concentrated", d.TopShare())
+	}
+	if got, want := d.Effective(), 1/(0.75*0.75+0.25*0.25); math.Abs(got-want) > 1e-9 {
+		t.Errorf("got %.3f effective sources, want %.3f", got, want)
+	}
+
+	if got := FilterBySource(database, "bbc"); len(*got) != 2 {
+		t.Errorf("got %d articles from BBC, want 2", len(*got))
+	}
+}
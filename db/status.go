@@ -0,0 +1,131 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Status is the stage of an article in the review workflow. Only published articles are shown to students.
+type Status string
+
+// Statuses of an article. An article is written as a draft, submitted for review, and published once another curator approves it. Articles approved before their publish time are scheduled, and published by the server when the time comes. Archived articles are taken off the feed without being deleted.
+const (
+	Draft     Status = "draft"
+	Pending   Status = "pending review"
+	Scheduled Status = "scheduled"
+	Published Status = "published"
+	Archived  Status = "archived"
+)
+
+// Statuses lists every Status in workflow order.
+var Statuses = []Status{Draft, Pending, Scheduled, Published, Archived}
+
+var (
+	// ErrInvalidStatus is returned when an article's status is not one of the Statuses.
+	ErrInvalidStatus = errors.New("invalid status")
+	// ErrSelfReview is returned when a curator approves an article they added themselves.
+	ErrSelfReview = errors.New("an article must be approved by a different curator from the one who added it")
+	// ErrReviewRequired is returned when publishing an article that has not been submitted for review, while review is required.
+	ErrReviewRequired = errors.New("an article must be submitted for review before it is published")
+)
+
+// ParseStatus parses the status in the Articles sheet, ignoring case. An empty status is Published, so that articles added before the review workflow stay on the feed.
+func ParseStatus(s string) (Status, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "":
+		return Published, nil
+	case "pending", "review":
+		return Pending, nil
+	}
+	for _, st := range Statuses {
+		if s == string(st) {
+			return st, nil
+		}
+	}
+	return "", fmt.Errorf("%w %q", ErrInvalidStatus, s)
+}
+
+// IsPublished reports whether the article is shown to students. Articles with no status are published.
+func (a Article) IsPublished() bool {
+	return a.Status == "" || a.Status == Published
+}
+
+// recordStatus returns the status written to the Articles sheet, which is empty for published articles so that their rows keep the original columns.
+func recordStatus(st Status) string {
+	if st == Published {
+		return ""
+	}
+	return string(st)
+}
+
+// PublishedArticles returns the published articles in the database, for the pages shown to students.
+func PublishedArticles(database *ArticlesDBByDate) *ArticlesDBByDate {
+	results := NewArticlesDBByDate()
+	for _, a := range *database {
+		if a.IsPublished() {
+			*results = append(*results, a)
+		}
+	}
+	return results
+}
+
+// FilterByStatus returns the articles in the database with the given status.
+func FilterByStatus(database *ArticlesDBByDate, st Status) *ArticlesDBByDate {
+	results := NewArticlesDBByDate()
+	for _, a := range *database {
+		if a.Status == st || (st == Published && a.IsPublished()) {
+			*results = append(*results, a)
+		}
+	}
+	return results
+}
+
+// Submit gives a new article added by curator the status st chosen for it, and records curator as having added it unless it names who did. Articles to be published go to the review queue instead if requireReview is set, and articles published before their publish time at now are scheduled.
+func (a *Article) Submit(st Status, curator string, requireReview bool, now time.Time) {
+	if a.AddedBy == "" {
+		a.AddedBy = curator
+	}
+	if (st == Published || st == Scheduled) && requireReview {
+		st, a.ReviewedBy = Pending, ""
+	}
+	a.Status = st
+	if st == Published && a.PublishAt > now.Unix() {
+		a.Status = Scheduled
+	} else if st == Published {
+		a.PublishAt = 0
+	}
+}
+
+// SetStatus moves the article with the given URL to status st on behalf of curator, and returns the updated article. Approving an article pending review records curator as its reviewer, and returns ErrSelfReview if curator added it. If requireReview is set, only articles pending review can be published, and ErrReviewRequired is returned for the others. Articles published before their publish time are scheduled instead, and scheduled articles, which have already been approved, can be published straight away.
+func (db ArticlesDBByDate) SetStatus(url string, st Status, curator string, requireReview bool) (*Article, error) {
+	i := db.IndexOf(url)
+	if i < 0 {
+		return nil, fmt.Errorf("unable to find the article %s", url)
+	}
+	a := &db[i]
+
+	if st == Published && a.Status != Scheduled && a.PublishAt > time.Now().Unix() {
+		st = Scheduled
+	}
+	if (st == Published || st == Scheduled) && !a.IsPublished() && a.Status != Scheduled {
+		switch {
+		case a.Status == Pending && a.AddedBy != "" && strings.EqualFold(a.AddedBy, curator):
+			return nil, ErrSelfReview
+		case a.Status == Pending:
+			a.ReviewedBy = curator
+		case requireReview:
+			return nil, ErrReviewRequired
+		default:
+			a.ReviewedBy = ""
+		}
+	}
+	if st == Published {
+		a.PublishAt = 0
+	}
+	a.Status = st
+	return a, nil
+}
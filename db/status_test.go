@@ -0,0 +1,103 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSetStatus(t *testing.T) {
+	database := ArticlesDBByDate{
+		{URL: "https://example.com/a", Status: Pending, AddedBy: "mary"},
+		{URL: "https://example.com/b", Status: Draft, AddedBy: "mary"},
+		{URL: "https://example.com/c"},
+	}
+
+	if _, err := database.SetStatus("https://example.com/a", Published, "Mary", true); !errors.Is(err, ErrSelfReview) {
+		t.Errorf("got %v approving one's own article, want ErrSelfReview", err)
+	}
+	a, err := database.SetStatus("https://example.com/a", Published, "john", true)
+	if err != nil || a.Status != Published || a.ReviewedBy != "john" {
+		t.Errorf("got %+v, %v approving another curator's article, want it published and reviewed by john", a, err)
+	}
+
+	if _, err := database.SetStatus("https://example.com/b", Published, "john", true); !errors.Is(err, ErrReviewRequired) {
+		t.Errorf("got %v publishing a draft, want ErrReviewRequired", err)
+	}
+	if _, err := database.SetStatus("https://example.com/b", Published, "mary", false); err != nil {
+		t.Errorf("got %v publishing a draft without review, want nil", err)
+	}
+
+	if _, err := database.SetStatus("https://example.com/c", Archived, "mary", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := PublishedArticles(&database); got.Len() != 2 {
+		t.Errorf("got %d published articles, want 2", got.Len())
+	}
+
+	if st, err := ParseStatus(" Pending Review "); err != nil || st != Pending {
+		t.Errorf("ParseStatus() = %q, %v, want %q", st, err, Pending)
+	}
+	if _, err := ParseStatus("live"); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("got %v parsing an unknown status, want ErrInvalidStatus", err)
+	}
+}
+
+func TestPublishDue(t *testing.T) {
+	now := time.Now()
+	database := ArticlesDBByDate{
+		{URL: "https://example.com/a", Status: Pending, AddedBy: "mary", PublishAt: now.Add(48 * time.Hour).Unix()},
+		{URL: "https://example.com/b", Status: Scheduled, PublishAt: now.Add(-time.Minute).Unix()},
+		{URL: "https://example.com/c", Status: Draft, PublishAt: now.Add(-time.Minute).Unix()},
+	}
+
+	a, err := database.SetStatus("https://example.com/a", Published, "john", true)
+	if err != nil || a.Status != Scheduled || a.ReviewedBy != "john" {
+		t.Fatalf("got %+v, %v approving an article before its publish time, want it scheduled and reviewed by john", a, err)
+	}
+	if got := PublishedArticles(&database); got.Len() != 0 {
+		t.Errorf("got %d published articles before publishing the due ones, want 0", got.Len())
+	}
+
+	published := database.PublishDue(now)
+	if len(published) != 1 || published[0].URL != "https://example.com/b" || database[1].Status != Published || database[1].PublishAt != 0 {
+		t.Errorf("got %+v published, want only the scheduled article that is due", published)
+	}
+	if published := database.PublishDue(now.Add(72 * time.Hour)); len(published) != 1 || published[0].URL != "https://example.com/a" {
+		t.Errorf("got %+v published two days later, want the approved article", published)
+	}
+
+	rec := NewRecord(Article{Title: "t", URL: "u", DisplayDate: "Mar 5, 2022", Status: Scheduled, PublishAt: time.Date(2022, 3, 7, 7, 30, 0, 0, time.Local).Unix()})
+	if got, err := ParsePublishTime(rec.PublishAt); err != nil || got != time.Date(2022, 3, 7, 7, 30, 0, 0, time.Local).Unix() {
+		t.Errorf("got %d, %v parsing %q back, want the same time", got, err, rec.PublishAt)
+	}
+	if _, err := ParsePublishTime("next Monday"); !errors.Is(err, ErrInvalidDate) {
+		t.Errorf("got %v parsing an invalid publish time, want ErrInvalidDate", err)
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour).Unix()
+	tests := []struct {
+		name          string
+		a             Article
+		st            Status
+		requireReview bool
+		want          Article
+	}{
+		{"published", Article{PublishAt: now.Add(-time.Hour).Unix()}, Published, false, Article{Status: Published, AddedBy: "mary"}},
+		{"published before its publish time", Article{PublishAt: later}, Published, false, Article{Status: Scheduled, AddedBy: "mary", PublishAt: later}},
+		{"published with review required", Article{PublishAt: later}, Published, true, Article{Status: Pending, AddedBy: "mary", PublishAt: later}},
+		{"scheduled with review required", Article{Status: Scheduled, AddedBy: "john", ReviewedBy: "ann", PublishAt: later}, Scheduled, true, Article{Status: Pending, AddedBy: "john", PublishAt: later}},
+		{"draft", Article{}, Draft, true, Article{Status: Draft, AddedBy: "mary"}},
+	}
+	for _, tt := range tests {
+		a := tt.a
+		a.Submit(tt.st, "mary", tt.requireReview, now)
+		if !reflect.DeepEqual(a, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, a, tt.want)
+		}
+	}
+}
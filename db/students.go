@@ -0,0 +1,271 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Limits on what each student can save, so that the Students sheet stays small.
+const (
+	MaxReadingLists    = 20
+	MaxListNameLength  = 60
+	MaxSavedPerStudent = 1000
+)
+
+var (
+	// ErrInvalidListName is returned when a reading list is given an empty or too long name.
+	ErrInvalidListName = errors.New("invalid reading list name")
+	// ErrTooManySaved is returned when a student has saved as many reading lists or articles as they can.
+	ErrTooManySaved = errors.New("too many saved articles or reading lists")
+)
+
+// ReadingList is a named list of articles, by URL, that a student has put together, such as the articles for an essay.
+type ReadingList struct {
+	Name string
+	URLs []string
+}
+
+// Student is a student's account, identified by their school email address. It holds the URLs of the articles they have bookmarked and marked as read, and their reading lists.
+type Student struct {
+	Email     string
+	Bookmarks []string
+	Read      []string
+	Lists     []ReadingList
+}
+
+// Students are the student accounts by email address, in lower case. They are backed by the Students sheet, which has a row for each bookmark, read marker and article in a reading list.
+type Students map[string]*Student
+
+// NormalizeEmail returns email without surrounding spaces and in lower case, as it is used to identify a student.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Get returns the account of the student with the given email address, creating it if they have none yet.
+func (st Students) Get(email string) *Student {
+	email = NormalizeEmail(email)
+	if a, ok := st[email]; ok {
+		return a
+	}
+	a := &Student{Email: email}
+	st[email] = a
+	return a
+}
+
+// saved returns the number of bookmarks, read markers and articles in reading lists of the student.
+func (a *Student) saved() int {
+	n := len(a.Bookmarks) + len(a.Read)
+	for _, l := range a.Lists {
+		n += len(l.URLs) + 1
+	}
+	return n
+}
+
+// setURL adds url to urls if on is set, or removes it, and returns the updated list.
+func setURL(urls []string, url string, on bool) []string {
+	for i, u := range urls {
+		if u == url {
+			if on {
+				return urls
+			}
+			return append(urls[:i:i], urls[i+1:]...)
+		}
+	}
+	if on {
+		urls = append(urls, url)
+	}
+	return urls
+}
+
+func hasURL(urls []string, url string) bool {
+	for _, u := range urls {
+		if u == url {
+			return true
+		}
+	}
+	return false
+}
+
+// SetBookmark bookmarks the article at url if on is set, or removes the bookmark.
+func (a *Student) SetBookmark(url string, on bool) error {
+	if on && !hasURL(a.Bookmarks, url) && a.saved() >= MaxSavedPerStudent {
+		return ErrTooManySaved
+	}
+	a.Bookmarks = setURL(a.Bookmarks, url, on)
+	return nil
+}
+
+// SetRead marks the article at url as read if on is set, or as unread.
+func (a *Student) SetRead(url string, on bool) error {
+	if on && !hasURL(a.Read, url) && a.saved() >= MaxSavedPerStudent {
+		return ErrTooManySaved
+	}
+	a.Read = setURL(a.Read, url, on)
+	return nil
+}
+
+// IsBookmarked reports whether the student has bookmarked the article at url.
+func (a *Student) IsBookmarked(url string) bool {
+	return hasURL(a.Bookmarks, url)
+}
+
+// HasRead reports whether the student has marked the article at url as read.
+func (a *Student) HasRead(url string) bool {
+	return hasURL(a.Read, url)
+}
+
+// List returns the student's reading list with the given name, ignoring case, or nil if they have none.
+func (a *Student) List(name string) *ReadingList {
+	name = strings.TrimSpace(name)
+	for i := range a.Lists {
+		if strings.EqualFold(a.Lists[i].Name, name) {
+			return &a.Lists[i]
+		}
+	}
+	return nil
+}
+
+// SetInList adds the article at url to the reading list with the given name if on is set, creating the list if needed, or removes it from the list. An empty url with on set creates an empty list, and with on unset deletes the whole list.
+func (a *Student) SetInList(name, url string, on bool) error {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" || len([]rune(name)) > MaxListNameLength {
+		return fmt.Errorf("%w %q: it must have 1 to %d characters", ErrInvalidListName, name, MaxListNameLength)
+	}
+
+	l := a.List(name)
+	if l == nil {
+		if !on {
+			return nil
+		}
+		if len(a.Lists) >= MaxReadingLists || a.saved() >= MaxSavedPerStudent {
+			return ErrTooManySaved
+		}
+		a.Lists = append(a.Lists, ReadingList{Name: name})
+		l = &a.Lists[len(a.Lists)-1]
+	}
+
+	switch {
+	case url == "" && on:
+	case url == "":
+		for i := range a.Lists {
+			if &a.Lists[i] == l {
+				a.Lists = append(a.Lists[:i:i], a.Lists[i+1:]...)
+				break
+			}
+		}
+	case on && !hasURL(l.URLs, url) && a.saved() >= MaxSavedPerStudent:
+		return ErrTooManySaved
+	default:
+		l.URLs = setURL(l.URLs, url, on)
+	}
+	return nil
+}
+
+// ArticlesByURL returns the published articles in the database at the given URLs, in the same order. URLs of articles that have since been deleted or taken off the feed are skipped.
+func ArticlesByURL(database *ArticlesDBByDate, urls []string) *ArticlesDBByDate {
+	byURL := make(map[string]Article, len(*database))
+	for _, a := range *database {
+		if a.IsPublished() {
+			byURL[a.URL] = a
+		}
+	}
+
+	results := NewArticlesDBByDate()
+	for _, url := range urls {
+		if a, ok := byURL[url]; ok {
+			*results = append(*results, a)
+		}
+	}
+	return results
+}
+
+// Kinds of rows in the Students sheet.
+const (
+	studentBookmark = "bookmark"
+	studentRead     = "read"
+	studentList     = "list"
+)
+
+// InitStudents reads the student accounts from the Students sheet, whose rows are email address, kind (bookmark, read or list), reading list name and article URL. A row of a reading list without a URL keeps an empty list. Rows that are incomplete or of an unknown kind are skipped.
+func InitStudents(ctx context.Context) (Students, error) {
+	st := make(Students)
+
+	srv, err := newSheetsService(ctx)
+	if err != nil {
+		return st, fmt.Errorf("unable to start Sheets service: %w", err)
+	}
+
+	data, err := getSheetData(ctx, srv, "Students")
+	if err != nil {
+		return st, fmt.Errorf("unable to get sheet data: %w", err)
+	}
+
+	for _, row := range data.Values {
+		cell := func(i int) string {
+			if i < len(row) {
+				return strings.TrimSpace(fmt.Sprint(row[i]))
+			}
+			return ""
+		}
+		email, url := cell(0), cell(3)
+		if email == "" {
+			continue
+		}
+
+		a := st.Get(email)
+		switch strings.ToLower(cell(1)) {
+		case studentBookmark:
+			if url != "" {
+				a.Bookmarks = setURL(a.Bookmarks, url, true)
+			}
+		case studentRead:
+			if url != "" {
+				a.Read = setURL(a.Read, url, true)
+			}
+		case studentList:
+			a.SetInList(cell(2), url, true)
+		}
+	}
+	return st, nil
+}
+
+// BackupStudentsWrite returns the SheetWrite that backs up every student account to the Students sheet.
+func BackupStudentsWrite(st Students) SheetWrite {
+	emails := make([]string, 0, len(st))
+	for email := range st {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+
+	values := make([][]interface{}, 0)
+	for _, email := range emails {
+		a := st[email]
+		for _, url := range a.Bookmarks {
+			values = append(values, []interface{}{email, studentBookmark, "", url})
+		}
+		for _, url := range a.Read {
+			values = append(values, []interface{}{email, studentRead, "", url})
+		}
+		for _, l := range a.Lists {
+			if len(l.URLs) == 0 {
+				values = append(values, []interface{}{email, studentList, l.Name, ""})
+			}
+			for _, url := range l.URLs {
+				values = append(values, []interface{}{email, studentList, l.Name, url})
+			}
+		}
+	}
+
+	return SheetWrite{
+		Description:   "back up the student accounts",
+		SpreadsheetID: config.SheetID,
+		Range:         "Students",
+		Values:        values,
+		InputOption:   "RAW",
+		Replace:       true,
+	}
+}
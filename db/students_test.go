@@ -0,0 +1,50 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStudentReadingLists(t *testing.T) {
+	st := make(Students)
+	a := st.Get(" Ann@Students.Example.edu ")
+	if st.Get("ann@students.example.edu") != a {
+		t.Fatal("got a new account for the same email in a different case, want the same one")
+	}
+
+	a.SetBookmark("https://example.com/a", true)
+	a.SetBookmark("https://example.com/a", true)
+	a.SetBookmark("https://example.com/b", true)
+	a.SetBookmark("https://example.com/a", false)
+	a.SetRead("https://example.com/b", true)
+	if len(a.Bookmarks) != 1 || !a.IsBookmarked("https://example.com/b") || !a.HasRead("https://example.com/b") {
+		t.Errorf("got bookmarks %v and read %v, want only b in both", a.Bookmarks, a.Read)
+	}
+
+	if err := a.SetInList("  Essay   on ageing ", "https://example.com/a", true); err != nil {
+		t.Fatal(err)
+	}
+	a.SetInList("essay on ageing", "https://example.com/b", true)
+	a.SetInList("Later", "", true)
+	if l := a.List("ESSAY ON AGEING"); l == nil || l.Name != "Essay on ageing" || len(l.URLs) != 2 {
+		t.Errorf("got list %+v, want Essay on ageing with 2 articles", l)
+	}
+	if err := a.SetInList(" ", "https://example.com/a", true); !errors.Is(err, ErrInvalidListName) {
+		t.Errorf("got %v adding to a list without a name, want ErrInvalidListName", err)
+	}
+
+	w := BackupStudentsWrite(st)
+	if len(w.Values) != 5 {
+		t.Errorf("got %d rows backed up, want 5: %v", len(w.Values), w.Values)
+	}
+
+	a.SetInList("Essay on ageing", "", false)
+	if len(a.Lists) != 1 || a.Lists[0].Name != "Later" {
+		t.Errorf("got lists %+v after deleting one, want only Later", a.Lists)
+	}
+
+	database := &ArticlesDBByDate{{URL: "https://example.com/a"}, {URL: "https://example.com/b", Status: Draft}}
+	if got := ArticlesByURL(database, []string{"https://example.com/b", "https://example.com/gone", "https://example.com/a"}); got.Len() != 1 || (*got)[0].URL != "https://example.com/a" {
+		t.Errorf("got %+v, want only the published article a", got)
+	}
+}
@@ -0,0 +1,280 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SuggestionStatus is the stage of a student's suggestion in the moderation queue.
+type SuggestionStatus string
+
+// Statuses of a suggestion. Suggestions wait in the moderation queue until a curator accepts them, by adding the article to the feed, or rejects them with a note.
+const (
+	SuggestionPending  SuggestionStatus = "pending"
+	SuggestionAccepted SuggestionStatus = "accepted"
+	SuggestionRejected SuggestionStatus = "rejected"
+)
+
+// Limits on suggestions, beyond which a suggestion is taken to be spam or the moderation queue to be flooded.
+const (
+	MaxReasonLength       = 500
+	MaxSuggestedTags      = 5
+	MaxSuggestedTagLength = 40
+	MaxPendingSuggestions = 200
+)
+
+var (
+	// ErrInvalidURL is returned when a suggested article's URL is not a web address.
+	ErrInvalidURL = errors.New("invalid url")
+	// ErrSpam is returned when a suggestion looks like spam, such as a reason full of links.
+	ErrSpam = errors.New("suggestion looks like spam")
+	// ErrAlreadySuggested is returned when an article is suggested again while its earlier suggestion is still waiting for moderation.
+	ErrAlreadySuggested = errors.New("article has already been suggested")
+	// ErrQueueFull is returned when too many suggestions are waiting for moderation to take any more.
+	ErrQueueFull = errors.New("too many suggestions are waiting for moderation")
+)
+
+// StudentSuggestion is an article suggested by a student, with their optional reason and suggested tags. Note is the curator's note on why a suggestion was rejected.
+type StudentSuggestion struct {
+	ID          string
+	URL         string
+	Reason      string
+	Tags        []string
+	Submitted   time.Time
+	Status      SuggestionStatus
+	Note        string
+	ModeratedBy string
+	Moderated   time.Time
+}
+
+// NewSuggestion checks a suggestion submitted with the public suggestion form at now, and returns it pending moderation. It returns ErrInvalidURL if articleURL is not a web address, and ErrSpam if the reason is too long or has links in it, or there are too many or too long tags. tags are separated by semicolons or commas.
+func NewSuggestion(articleURL, reason, tags string, now time.Time) (StudentSuggestion, error) {
+	sg := StudentSuggestion{
+		ID:        strconv.FormatInt(now.UnixNano(), 36),
+		URL:       strings.TrimSpace(articleURL),
+		Reason:    strings.TrimSpace(reason),
+		Submitted: now,
+		Status:    SuggestionPending,
+	}
+
+	u, err := url.Parse(sg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !strings.Contains(u.Hostname(), ".") {
+		return StudentSuggestion{}, fmt.Errorf("%w %q", ErrInvalidURL, sg.URL)
+	}
+
+	if len([]rune(sg.Reason)) > MaxReasonLength {
+		return StudentSuggestion{}, fmt.Errorf("%w: the reason is longer than %d characters", ErrSpam, MaxReasonLength)
+	}
+	lower := strings.ToLower(sg.Reason)
+	if strings.Contains(lower, "http://") || strings.Contains(lower, "https://") || strings.Contains(lower, "www.") {
+		return StudentSuggestion{}, fmt.Errorf("%w: the reason has links in it", ErrSpam)
+	}
+
+	for _, t := range strings.FieldsFunc(tags, func(r rune) bool { return r == ';' || r == ',' }) {
+		if t = strings.TrimSpace(t); t != "" {
+			sg.Tags = append(sg.Tags, t)
+		}
+		if len([]rune(t)) > MaxSuggestedTagLength {
+			return StudentSuggestion{}, fmt.Errorf("%w: the tag %q is longer than %d characters", ErrSpam, t, MaxSuggestedTagLength)
+		}
+	}
+	if len(sg.Tags) > MaxSuggestedTags {
+		return StudentSuggestion{}, fmt.Errorf("%w: more than %d tags", ErrSpam, MaxSuggestedTags)
+	}
+
+	return sg, nil
+}
+
+// TagList returns the suggested tags separated by semicolons, as they are entered in the add article form.
+func (sg StudentSuggestion) TagList() string {
+	return strings.Join(sg.Tags, "; ")
+}
+
+// SuggestionQueue is the moderation queue of suggested articles, oldest first. It is backed by the Suggestions sheet.
+type SuggestionQueue []StudentSuggestion
+
+// Check returns ErrDuplicateArticle if the article suggested in sg is already in the database, ErrAlreadySuggested if it is waiting for moderation, and ErrQueueFull if there are MaxPendingSuggestions suggestions waiting already.
+func (q SuggestionQueue) Check(sg StudentSuggestion, database *ArticlesDBByDate) error {
+	for _, a := range *database {
+		if sameURL(a.URL, sg.URL) {
+			return ErrDuplicateArticle
+		}
+	}
+
+	pending := q.Pending()
+	for _, p := range pending {
+		if sameURL(p.URL, sg.URL) {
+			return ErrAlreadySuggested
+		}
+	}
+	if len(pending) >= MaxPendingSuggestions {
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// sameURL reports whether two URLs are the same, ignoring case in the host name, a leading "www." and a trailing slash.
+func sameURL(a, b string) bool {
+	normalize := func(s string) string {
+		s = strings.TrimSuffix(strings.TrimSpace(s), "/")
+		if u, err := url.Parse(s); err == nil && u.Host != "" {
+			return SourceDomain(s) + u.RequestURI()
+		}
+		return s
+	}
+	return normalize(a) == normalize(b)
+}
+
+// Pending returns the suggestions waiting for moderation, oldest first.
+func (q SuggestionQueue) Pending() SuggestionQueue {
+	return q.withStatus(SuggestionPending)
+}
+
+// Moderated returns the suggestions that have been accepted or rejected, most recently moderated first.
+func (q SuggestionQueue) Moderated() SuggestionQueue {
+	var moderated SuggestionQueue
+	for i := len(q) - 1; i >= 0; i-- {
+		if q[i].Status != SuggestionPending {
+			moderated = append(moderated, q[i])
+		}
+	}
+	return moderated
+}
+
+func (q SuggestionQueue) withStatus(st SuggestionStatus) SuggestionQueue {
+	var results SuggestionQueue
+	for _, sg := range q {
+		if sg.Status == st {
+			results = append(results, sg)
+		}
+	}
+	return results
+}
+
+// Find returns the suggestion with the given ID, or nil if there is none.
+func (q SuggestionQueue) Find(id string) *StudentSuggestion {
+	for i := range q {
+		if q[i].ID == id {
+			return &q[i]
+		}
+	}
+	return nil
+}
+
+// Moderate accepts or rejects the pending suggestion with the given ID on behalf of curator at now, with an optional note for rejecting it, and returns the updated suggestion.
+func (q SuggestionQueue) Moderate(id string, st SuggestionStatus, note, curator string, now time.Time) (*StudentSuggestion, error) {
+	if st != SuggestionAccepted && st != SuggestionRejected {
+		return nil, fmt.Errorf("unable to moderate a suggestion as %q", st)
+	}
+	sg := q.Find(id)
+	if sg == nil {
+		return nil, fmt.Errorf("unable to find the suggestion %s", id)
+	}
+	if sg.Status != SuggestionPending {
+		return nil, fmt.Errorf("the suggestion of %s has already been %s", sg.URL, sg.Status)
+	}
+
+	sg.Status = st
+	sg.Note = strings.TrimSpace(note)
+	sg.ModeratedBy = curator
+	sg.Moderated = now
+	return sg, nil
+}
+
+// Row returns the suggestion as a row of the Suggestions sheet: ID, time submitted, URL, reason, suggested tags, status, note, curator who moderated it, and time moderated.
+func (sg StudentSuggestion) Row() []interface{} {
+	var moderated string
+	if !sg.Moderated.IsZero() {
+		moderated = sg.Moderated.Format(time.RFC3339)
+	}
+	return []interface{}{
+		sg.ID,
+		sg.Submitted.Format(time.RFC3339),
+		sg.URL,
+		sg.Reason,
+		strings.Join(sg.Tags, "\n"),
+		string(sg.Status),
+		sg.Note,
+		sg.ModeratedBy,
+		moderated,
+	}
+}
+
+// InitSuggestions reads the moderation queue from the Suggestions sheet. Rows without an ID or URL are skipped.
+func InitSuggestions(ctx context.Context) (SuggestionQueue, error) {
+	srv, err := newSheetsService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start Sheets service: %w", err)
+	}
+
+	data, err := getSheetData(ctx, srv, "Suggestions")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get sheet data: %w", err)
+	}
+
+	q := make(SuggestionQueue, 0, len(data.Values))
+	for _, row := range data.Values {
+		cell := func(i int) string {
+			if i < len(row) {
+				return strings.TrimSpace(fmt.Sprint(row[i]))
+			}
+			return ""
+		}
+		if cell(0) == "" || cell(2) == "" {
+			continue
+		}
+
+		sg := StudentSuggestion{
+			ID:          cell(0),
+			URL:         cell(2),
+			Reason:      cell(3),
+			Tags:        trimAll(strings.Split(cell(4), "\n")),
+			Status:      SuggestionStatus(strings.ToLower(cell(5))),
+			Note:        cell(6),
+			ModeratedBy: cell(7),
+		}
+		sg.Submitted, _ = time.Parse(time.RFC3339, cell(1))
+		sg.Moderated, _ = time.Parse(time.RFC3339, cell(8))
+		if sg.Status != SuggestionAccepted && sg.Status != SuggestionRejected {
+			sg.Status = SuggestionPending
+		}
+		q = append(q, sg)
+	}
+	return q, nil
+}
+
+// AppendSuggestionWrite returns the SheetWrite that appends a new suggestion to the Suggestions sheet.
+func AppendSuggestionWrite(sg StudentSuggestion) SheetWrite {
+	return SheetWrite{
+		Description:   fmt.Sprintf("append the suggestion of %s to the Suggestions sheet", sg.URL),
+		SpreadsheetID: config.SheetID,
+		Range:         "Suggestions",
+		Values:        [][]interface{}{sg.Row()},
+		Append:        true,
+		InputOption:   "RAW",
+		KeyColumns:    []int{0},
+	}
+}
+
+// BackupSuggestionsWrite returns the SheetWrite that backs up the whole moderation queue to the Suggestions sheet, after suggestions have been accepted or rejected.
+func BackupSuggestionsWrite(q SuggestionQueue) SheetWrite {
+	values := make([][]interface{}, 0, len(q))
+	for _, sg := range q {
+		values = append(values, sg.Row())
+	}
+
+	return SheetWrite{
+		Description:   "back up the suggestions",
+		SpreadsheetID: config.SheetID,
+		Range:         "Suggestions",
+		Values:        values,
+		InputOption:   "RAW",
+		Replace:       true,
+	}
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSuggestion(t *testing.T) {
+	now := time.Date(2022, 3, 5, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		url, reason, tags string
+		want              error
+	}{
+		{"https://www.straitstimes.com/a", "Relevant to the 2019 question on ageing.", "ageing; 2019-Q6", nil},
+		{"javascript:alert(1)", "", "", ErrInvalidURL},
+		{"straitstimes.com/a", "", "", ErrInvalidURL},
+		{"https://example.com/b", "Cheap watches at https://spam.example", "", ErrSpam},
+		{"https://example.com/c", strings.Repeat("a", MaxReasonLength+1), "", ErrSpam},
+		{"https://example.com/d", "", "a, b, c, d, e, f", ErrSpam},
+	}
+	for _, tt := range tests {
+		if _, err := NewSuggestion(tt.url, tt.reason, tt.tags, now); !errors.Is(err, tt.want) {
+			t.Errorf("NewSuggestion(%q, %q, %q) returned %v, want %v", tt.url, tt.reason, tt.tags, err, tt.want)
+		}
+	}
+
+	sg, err := NewSuggestion(" https://www.straitstimes.com/a ", "", "ageing; 2019-Q6", now)
+	if err != nil || sg.TagList() != "ageing; 2019-Q6" || sg.Status != SuggestionPending {
+		t.Fatalf("got %+v, %v, want a pending suggestion tagged ageing and 2019-Q6", sg, err)
+	}
+
+	database := &ArticlesDBByDate{{URL: "https://www.bbc.com/news/e"}}
+	q := SuggestionQueue{sg}
+	if err := q.Check(StudentSuggestion{URL: "https://bbc.com/news/e/"}, database); !errors.Is(err, ErrDuplicateArticle) {
+		t.Errorf("got %v suggesting an article in the feed, want ErrDuplicateArticle", err)
+	}
+	if err := q.Check(StudentSuggestion{URL: "https://straitstimes.com/a"}, database); !errors.Is(err, ErrAlreadySuggested) {
+		t.Errorf("got %v suggesting an article waiting for moderation, want ErrAlreadySuggested", err)
+	}
+
+	if _, err := q.Moderate(sg.ID, SuggestionRejected, " Paywalled ", "mary", now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Moderate(sg.ID, SuggestionAccepted, "", "john", now); err == nil {
+		t.Error("got nil accepting a rejected suggestion, want an error")
+	}
+	if len(q.Pending()) != 0 || q.Moderated()[0].Note != "Paywalled" || q.Check(sg, database) != nil {
+		t.Errorf("got %+v after rejecting, want the suggestion rejected with a note, and suggestable again", q)
+	}
+}
@@ -0,0 +1,443 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Conflict is an article that has been changed differently in the Articles sheet and in the app since they were last in sync. A zero Sheet or App record means the article was deleted on that side.
+type Conflict struct {
+	URL      string
+	Base     Record
+	Sheet    Record
+	App      Record
+	Detected time.Time
+}
+
+// SheetSync keeps the articles database in step with edits made directly in the Articles sheet. It remembers each article as it was when the app and the sheet last agreed, so that a sync can tell which side changed: edits made only in the sheet are applied to the database, edits made only in the app are left for the next backup, and articles changed differently on both sides are held as conflicts for an admin to resolve.
+type SheetSync struct {
+	mu        sync.Mutex
+	base      map[string]Record
+	conflicts map[string]Conflict
+	rejected  map[string]rejection
+	unkeyed   []Record
+	// duplicates are rows with the same URL as an earlier row. Like rows without a URL, they cannot be matched to an article, and are kept in the sheet so that they can be removed or corrected there.
+	duplicates []Record
+	lastSync   time.Time
+	// writes counts the writes to the Articles sheet that have been applied, so that a sync can tell when the sheet was written to while it was being read.
+	writes int
+}
+
+// rejection is a sheet-side edit that failed validation. The sheet's version is kept in the sheet until it is corrected.
+type rejection struct {
+	rec    Record
+	reason string
+}
+
+// SyncResult summarises a single sync.
+type SyncResult struct {
+	// Applied is the number of sheet-side edits applied to the articles database.
+	Applied int
+	// Conflicts is the number of articles currently in conflict.
+	Conflicts int
+	// Rejected is the number of sheet-side edits currently failing validation.
+	Rejected int
+}
+
+// NewSheetSync returns a SheetSync that treats the articles database as being in sync with the Articles sheet, as it is right after InitArticlesDB. The rows that InitArticlesDB skipped, listed in report, are held as they are in the sheet, so that a backup made before the first sync does not delete them. report may be nil.
+func NewSheetSync(database *ArticlesDBByDate, report *ValidationReport) *SheetSync {
+	ss := &SheetSync{
+		base:      recordsByURL(database),
+		conflicts: make(map[string]Conflict),
+		rejected:  make(map[string]rejection),
+	}
+	if report == nil {
+		return ss
+	}
+
+	for _, e := range report.Errors {
+		rec := e.rec
+		rec.URL = strings.TrimSpace(rec.URL)
+		_, loaded := ss.base[rec.URL]
+		switch {
+		case rec.isBlank():
+		case rec.URL == "":
+			ss.unkeyed = append(ss.unkeyed, rec)
+		case e.duplicate || loaded:
+			ss.duplicates = append(ss.duplicates, rec)
+		default:
+			ss.rejected[rec.URL] = rejection{rec: rec, reason: e.Reason}
+		}
+	}
+	return ss
+}
+
+// Sync reads the Articles sheet and reconciles it with the articles database. It can be run while writes to the sheet are still pending: the sheet is compared with what it held when the app last wrote to or synced with it, so that edits made in the sheet in the meantime are still applied or held as conflicts. If a write reaches the sheet while it is being read, the sheet is read again.
+func (ss *SheetSync) Sync(ctx context.Context, database *ArticlesDBByDate, qnDB QuestionsDB, tm TopicsMap, qc QuestionCounter) (SyncResult, error) {
+	for attempt := 1; ; attempt++ {
+		sheet, err := ss.Read(ctx)
+		if err != nil {
+			return SyncResult{}, err
+		}
+		result, err := ss.Apply(sheet, database, qnDB, tm, qc)
+		if !errors.Is(err, ErrSheetWritten) || attempt == 3 {
+			return result, err
+		}
+	}
+}
+
+// ErrSheetWritten is returned by Apply when a write reached the Articles sheet after it was read, so that what was read is out of date. The sheet should be read again.
+var ErrSheetWritten = errors.New("the Articles sheet was written to after it was read")
+
+// ArticlesSheet is the Articles sheet as read by Read, to be reconciled with the articles database by Apply.
+type ArticlesSheet struct {
+	rows   [][]interface{}
+	writes int
+}
+
+// Read reads the Articles sheet for Apply. It is the part of Sync that calls Google Sheets, so that the sheet can be read without holding a lock on the articles database.
+func (ss *SheetSync) Read(ctx context.Context) (*ArticlesSheet, error) {
+	ss.mu.Lock()
+	writes := ss.writes
+	ss.mu.Unlock()
+
+	srv, err := newSheetsService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start Sheets service: %w", err)
+	}
+
+	data, err := getSheetData(ctx, srv, "Articles")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get sheet data: %w", err)
+	}
+	return &ArticlesSheet{rows: data.Values, writes: writes}, nil
+}
+
+// Apply reconciles the Articles sheet read by Read with the articles database, as Sync does. It returns ErrSheetWritten if a write has reached the sheet since it was read.
+func (ss *SheetSync) Apply(data *ArticlesSheet, database *ArticlesDBByDate, qnDB QuestionsDB, tm TopicsMap, qc QuestionCounter) (SyncResult, error) {
+	var result SyncResult
+
+	sheet := make(map[string]Record, len(data.rows))
+	var unkeyed, duplicates []Record
+	for _, row := range data.rows {
+		rec := rowToRecord(row)
+		rec.URL = strings.TrimSpace(rec.URL)
+		if rec.URL == "" {
+			// rows without a URL cannot be matched to an article, but are kept in the sheet so that they can be corrected.
+			if !rec.isBlank() {
+				unkeyed = append(unkeyed, rec)
+			}
+			continue
+		}
+		if _, ok := sheet[rec.URL]; ok {
+			duplicates = append(duplicates, rec)
+			continue
+		}
+		sheet[rec.URL] = rec
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.writes != data.writes {
+		return result, ErrSheetWritten
+	}
+
+	app := recordsByURL(database)
+	urls := make(map[string]bool, len(app)+len(sheet))
+	for _, m := range []map[string]Record{ss.base, sheet, app} {
+		for u := range m {
+			urls[u] = true
+		}
+	}
+
+	now := time.Now()
+	for u := range urls {
+		b, inBase := ss.base[u]
+		s, inSheet := sheet[u]
+		a, inApp := app[u]
+
+		sheetChanged := inSheet != inBase || (inSheet && !sameRecord(s, b))
+		appChanged := inApp != inBase || (inApp && !sameRecord(a, b))
+
+		switch {
+		case !sheetChanged:
+			// nothing to pull; any change in the app is written by the next backup.
+			delete(ss.conflicts, u)
+			delete(ss.rejected, u)
+		case inSheet == inApp && (!inSheet || sameRecord(s, a)):
+			// both sides made the same change.
+			ss.setBase(u, s, inSheet)
+			delete(ss.conflicts, u)
+			delete(ss.rejected, u)
+		case appChanged:
+			c, ok := ss.conflicts[u]
+			if !ok {
+				c.Detected = now
+			}
+			c.URL, c.Base, c.Sheet, c.App = u, b, s, a
+			ss.conflicts[u] = c
+		default:
+			if err := applyRecord(u, s, inSheet, database, qnDB, tm, qc); err != nil {
+				ss.rejected[u] = rejection{rec: s, reason: err.Error()}
+				continue
+			}
+			ss.setBase(u, s, inSheet)
+			delete(ss.rejected, u)
+			result.Applied++
+		}
+	}
+
+	ss.unkeyed = unkeyed
+	ss.duplicates = duplicates
+	ss.lastSync = now
+	result.Conflicts = len(ss.conflicts)
+	result.Rejected = len(ss.rejected) + len(ss.duplicates)
+	return result, nil
+}
+
+// Written records that w has reached Google Sheets. Articles written to the Articles sheet become the version the sheet is known to hold, so that the next Sync does not mistake them for edits made in the sheet. Articles in conflict or failing validation keep the sheet's earlier version, as they are written back unchanged. Written is to be called by the Outbox once each write has been applied.
+func (ss *SheetSync) Written(w SheetWrite) {
+	if w.SpreadsheetID != config.SheetID || w.Range != "Articles" {
+		return
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.writes++
+	written := make(map[string]Record, len(w.Values))
+	for _, row := range w.Values {
+		rec := rowToRecord(row)
+		rec.URL = strings.TrimSpace(rec.URL)
+		// rows with the same URL as an earlier row are duplicates kept from the sheet, not the article.
+		if _, ok := written[rec.URL]; !ok && rec.URL != "" {
+			written[rec.URL] = rec
+		}
+	}
+
+	held := func(u string) bool {
+		_, conflict := ss.conflicts[u]
+		_, rejected := ss.rejected[u]
+		return conflict || rejected
+	}
+	// a backup replaces the whole sheet, so articles left out of it are no longer in the sheet.
+	if !w.Append {
+		for u := range ss.base {
+			if _, ok := written[u]; !ok && !held(u) {
+				delete(ss.base, u)
+			}
+		}
+	}
+	for u, rec := range written {
+		if !held(u) {
+			ss.base[u] = rec
+		}
+	}
+}
+
+// Conflicts returns the articles currently in conflict, oldest first.
+func (ss *SheetSync) Conflicts() []Conflict {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	conflicts := make([]Conflict, 0, len(ss.conflicts))
+	for _, c := range ss.conflicts {
+		conflicts = append(conflicts, c)
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Detected.Equal(conflicts[j].Detected) {
+			return conflicts[i].URL < conflicts[j].URL
+		}
+		return conflicts[i].Detected.Before(conflicts[j].Detected)
+	})
+	return conflicts
+}
+
+// Rejected returns the sheet-side edits that could not be applied to the database because they failed validation, and the rows with the same URL as an earlier row, keyed by URL.
+func (ss *SheetSync) Rejected() map[string]string {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	rejected := make(map[string]string, len(ss.rejected)+len(ss.duplicates))
+	for _, rec := range ss.duplicates {
+		rejected[rec.URL] = fmt.Sprintf("%v: more than one row has this URL", ErrDuplicateArticle)
+	}
+	for u, r := range ss.rejected {
+		rejected[u] = r.reason
+	}
+	return rejected
+}
+
+// LastSync returns the time of the last successful sync.
+func (ss *SheetSync) LastSync() time.Time {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	return ss.lastSync
+}
+
+// Resolve settles the conflict for the article with the given URL. If keepSheet is true the sheet's version is applied to the database, otherwise the app's version is kept and will be written to the sheet by the next backup.
+func (ss *SheetSync) Resolve(url string, keepSheet bool, database *ArticlesDBByDate, qnDB QuestionsDB, tm TopicsMap, qc QuestionCounter) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	c, ok := ss.conflicts[url]
+	if !ok {
+		return fmt.Errorf("no conflict for %s", url)
+	}
+
+	inSheet := c.Sheet.URL != ""
+	if keepSheet {
+		if err := applyRecord(url, c.Sheet, inSheet, database, qnDB, tm, qc); err != nil {
+			return fmt.Errorf("unable to apply the sheet's version of %s: %w", url, err)
+		}
+	}
+
+	// the sheet's current version becomes the base, so that the app's version now counts as the only change.
+	ss.setBase(url, c.Sheet, inSheet)
+	delete(ss.conflicts, url)
+	return nil
+}
+
+// BackupArticlesWrite returns a SheetWrite that backs up the articles database to the Articles sheet like BackupArticlesWrite, except that articles in conflict and sheet edits that failed validation keep the sheet's version, and rows without a URL or with the same URL as an earlier row are kept, so that edits made in the sheet are not overwritten before they have been resolved or corrected. The articles written become the version the sheet is known to hold once the write has been applied and passed to Written.
+func (ss *SheetSync) BackupArticlesWrite(database *ArticlesDBByDate) SheetWrite {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	w := BackupArticlesWrite(database)
+	if len(ss.conflicts) == 0 && len(ss.rejected) == 0 && len(ss.unkeyed) == 0 && len(ss.duplicates) == 0 {
+		return w
+	}
+
+	held := make(map[string]Record, len(ss.conflicts)+len(ss.rejected))
+	for u, c := range ss.conflicts {
+		held[u] = c.Sheet
+	}
+	for u, r := range ss.rejected {
+		held[u] = r.rec
+	}
+
+	values := make([][]interface{}, 0, len(w.Values)+len(held)+len(ss.unkeyed))
+	for _, a := range *database {
+		if _, ok := held[a.URL]; ok {
+			continue
+		}
+		values = append(values, NewRecord(a).Row())
+	}
+	urls := make([]string, 0, len(held))
+	for u := range held {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	for _, u := range urls {
+		if rec := held[u]; rec.URL != "" {
+			values = append(values, rec.Row())
+		}
+	}
+	for _, rec := range ss.unkeyed {
+		values = append(values, rec.Row())
+	}
+	for _, rec := range ss.duplicates {
+		values = append(values, rec.Row())
+	}
+	w.Values = values
+	return w
+}
+
+// setBase records the agreed version of an article. The caller must hold ss.mu.
+func (ss *SheetSync) setBase(url string, rec Record, present bool) {
+	if !present {
+		delete(ss.base, url)
+		return
+	}
+	ss.base[url] = rec
+}
+
+// applyRecord makes the article with the given URL in the database match rec, or removes it if present is false.
+func applyRecord(url string, rec Record, present bool, database *ArticlesDBByDate, qnDB QuestionsDB, tm TopicsMap, qc QuestionCounter) error {
+	i := database.IndexOf(url)
+
+	if !present {
+		if i >= 0 {
+			database.RemoveArticle(i, tm, qc)
+		}
+		return nil
+	}
+
+	a, err := ParseArticle(rec, qnDB)
+	if err != nil {
+		return err
+	}
+
+	if i < 0 {
+		return a.AddArticleToDB(database, tm, qc)
+	}
+	return database.EditArticle(strconv.Itoa(i), *a, tm, qc)
+}
+
+// IndexOf returns the position in the database of the article with the given URL, or -1 if there is none.
+func (db ArticlesDBByDate) IndexOf(url string) int {
+	for i, a := range db {
+		if a.URL == url {
+			return i
+		}
+	}
+	return -1
+}
+
+func recordsByURL(database *ArticlesDBByDate) map[string]Record {
+	records := make(map[string]Record, len(*database))
+	for _, a := range *database {
+		if a.URL == "" {
+			continue
+		}
+		records[a.URL] = NewRecord(a)
+	}
+	return records
+}
+
+// sameRecord reports whether two records describe the same article, ignoring the question wording, which is looked up from the questions database, and differences in spacing or question key format.
+func sameRecord(a, b Record) bool {
+	return strings.TrimSpace(a.Title) == strings.TrimSpace(b.Title) &&
+		strings.TrimSpace(a.URL) == strings.TrimSpace(b.URL) &&
+		strings.TrimSpace(a.Date) == strings.TrimSpace(b.Date) &&
+		strings.TrimSpace(a.Summary) == strings.TrimSpace(b.Summary) &&
+		strings.TrimSpace(a.Note) == strings.TrimSpace(b.Note) &&
+		reflect.DeepEqual(trimAll(a.Prompts), trimAll(b.Prompts)) &&
+		strings.EqualFold(strings.TrimSpace(a.Status), strings.TrimSpace(b.Status)) &&
+		strings.TrimSpace(a.AddedBy) == strings.TrimSpace(b.AddedBy) &&
+		strings.TrimSpace(a.ReviewedBy) == strings.TrimSpace(b.ReviewedBy) &&
+		strings.TrimSpace(a.PublishAt) == strings.TrimSpace(b.PublishAt) &&
+		reflect.DeepEqual(trimAll(a.Topics), trimAll(b.Topics)) &&
+		reflect.DeepEqual(questionKeys(a.Questions), questionKeys(b.Questions))
+}
+
+func trimAll(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func questionKeys(keys []string) []string {
+	out := make([]string, 0, len(keys))
+	for _, k := range trimAll(keys) {
+		if qn, err := ParseQuestion(k); err == nil {
+			k = qn.Key()
+		}
+		out = append(out, k)
+	}
+	return out
+}
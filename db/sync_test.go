@@ -0,0 +1,293 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// initSyncTest loads testArticles from a fake Articles sheet and returns everything needed to sync it.
+func initSyncTest(t *testing.T) (*FakeSheets, *ArticlesDBByDate, QuestionsDB, TopicsMap, QuestionCounter, *SheetSync) {
+	t.Helper()
+
+	fake := useFakeSheets(t, testArticles, testQuestions)
+	ctx := context.Background()
+
+	qnDB, err := InitQuestionsDB(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := NewArticlesDBByDate()
+	tm := InitTopicsMap()
+	qc := InitQuestionCounter()
+	report, err := database.InitArticlesDB(ctx, qnDB, tm, qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fake, database, qnDB, tm, qc, NewSheetSync(database, report)
+}
+
+func TestSyncAppliesSheetEdits(t *testing.T) {
+	fake, database, qnDB, tm, qc, ss := initSyncTest(t)
+
+	fake.SetValues(testSheetID, "Articles", [][]interface{}{
+		{"Older article, typo fixed", "https://example.com/older", "Science\nTechnology", "2019 6", "", "Jan 2, 2021"},
+		{"Added in sheet", "https://example.com/added", "Politics", "2020 1", "", "Mar 4, 2021"},
+	})
+
+	result, err := ss.Sync(context.Background(), database, qnDB, tm, qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the edit and the new row are pulled in, and the row deleted in the sheet is removed.
+	if result.Applied != 3 || result.Conflicts != 0 {
+		t.Errorf("got %+v, want 3 applied and no conflicts", result)
+	}
+	var titles []string
+	for _, a := range *database {
+		titles = append(titles, a.Title)
+	}
+	if want := []string{"Added in sheet", "Older article, typo fixed"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("got titles %v, want %v", titles, want)
+	}
+	if _, ok := tm["Media"]; ok || tm["Politics"] != 1 {
+		t.Errorf("topics map not updated: %v", tm)
+	}
+	if qc["2020 - Q1"] != 1 {
+		t.Errorf("question counter not updated: %v", qc)
+	}
+}
+
+func TestSyncLeavesAppEditsForBackup(t *testing.T) {
+	fake, database, qnDB, tm, qc, ss := initSyncTest(t)
+
+	a := (*database)[0]
+	a.Title = "Edited in app"
+	if err := database.EditArticle("0", a, tm, qc); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ss.Sync(context.Background(), database, qnDB, tm, qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Applied != 0 || result.Conflicts != 0 {
+		t.Errorf("got %+v, want nothing applied and no conflicts", result)
+	}
+	if (*database)[0].Title != "Edited in app" {
+		t.Errorf("app edit was overwritten: got %q", (*database)[0].Title)
+	}
+
+	if err := ss.BackupArticlesWrite(database).apply(context.Background(), fake); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Values(testSheetID, "Articles")[0][0]; got != "Edited in app" {
+		t.Errorf("got %q in the sheet, want the app edit", got)
+	}
+}
+
+func TestSyncDetectsConflicts(t *testing.T) {
+	fake, database, qnDB, tm, qc, ss := initSyncTest(t)
+	ctx := context.Background()
+
+	a := (*database)[1]
+	a.Title = "Edited in app"
+	if err := database.EditArticle("1", a, tm, qc); err != nil {
+		t.Fatal(err)
+	}
+	rows := fake.Values(testSheetID, "Articles")
+	rows[0][0] = "Edited in sheet"
+	fake.SetValues(testSheetID, "Articles", rows)
+
+	result, err := ss.Sync(ctx, database, qnDB, tm, qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Conflicts != 1 {
+		t.Fatalf("got %+v, want 1 conflict", result)
+	}
+	c := ss.Conflicts()[0]
+	if c.URL != "https://example.com/older" || c.Sheet.Title != "Edited in sheet" || c.App.Title != "Edited in app" {
+		t.Errorf("got conflict %+v", c)
+	}
+
+	// a backup keeps the sheet's version until the conflict is resolved.
+	if err := ss.BackupArticlesWrite(database).apply(ctx, fake); err != nil {
+		t.Fatal(err)
+	}
+	rows = fake.Values(testSheetID, "Articles")
+	if len(rows) != 2 || rows[1][0] != "Edited in sheet" {
+		t.Errorf("conflicting sheet edit was overwritten: %v", rows)
+	}
+
+	if err := ss.Resolve(c.URL, true, database, qnDB, tm, qc); err != nil {
+		t.Fatal(err)
+	}
+	if (*database)[1].Title != "Edited in sheet" {
+		t.Errorf("got %q, want the sheet's version", (*database)[1].Title)
+	}
+	if len(ss.Conflicts()) != 0 {
+		t.Errorf("conflict was not cleared")
+	}
+}
+
+func TestSyncKeepsRejectedSheetEdits(t *testing.T) {
+	fake, database, qnDB, tm, qc, ss := initSyncTest(t)
+	ctx := context.Background()
+
+	rows := append(fake.Values(testSheetID, "Articles"),
+		[]interface{}{"Bad date", "https://example.com/bad", "Media", "", "", "someday"},
+		[]interface{}{"No URL", "", "Media", "", "", "Mar 4, 2021"},
+	)
+	fake.SetValues(testSheetID, "Articles", rows)
+
+	if _, err := ss.Sync(ctx, database, qnDB, tm, qc); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ss.Rejected()["https://example.com/bad"]; !ok || database.Len() != 2 {
+		t.Fatalf("got rejected %v and %d articles, want the bad row rejected", ss.Rejected(), database.Len())
+	}
+
+	// a backup keeps the rows that could not be loaded, so that they can be corrected in the sheet.
+	if err := ss.BackupArticlesWrite(database).apply(ctx, fake); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Values(testSheetID, "Articles"); len(got) != 4 || got[2][0] != "Bad date" || got[3][0] != "No URL" {
+		t.Errorf("rows that could not be loaded were not kept: %v", got)
+	}
+}
+
+func TestSyncAfterBackups(t *testing.T) {
+	fake, database, qnDB, tm, qc, ss := initSyncTest(t)
+	ctx := context.Background()
+
+	// two edits in the app, each backed up, are not mistaken for edits in the sheet.
+	for _, title := range []string{"First edit", "Second edit"} {
+		a := (*database)[0]
+		a.Title = title
+		if err := database.EditArticle("0", a, tm, qc); err != nil {
+			t.Fatal(err)
+		}
+		if err := ss.BackupArticlesWrite(database).apply(ctx, fake); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// an article deleted and then restored in the app is not deleted again.
+	removed := (*database)[1]
+	database.RemoveArticle(1, tm, qc)
+	if err := ss.BackupArticlesWrite(database).apply(ctx, fake); err != nil {
+		t.Fatal(err)
+	}
+	removed.AddArticleToDB(database, tm, qc)
+	if err := ss.BackupArticlesWrite(database).apply(ctx, fake); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ss.Sync(ctx, database, qnDB, tm, qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Applied != 0 || result.Conflicts != 0 {
+		t.Errorf("got %+v, want nothing applied and no conflicts", result)
+	}
+	if database.Len() != 2 || (*database)[0].Title != "Second edit" {
+		t.Errorf("app edits were undone: %v", *database)
+	}
+}
+
+func TestSyncWhileWritePending(t *testing.T) {
+	fake, database, qnDB, tm, qc, ss := initSyncTest(t)
+	ctx := context.Background()
+
+	o, err := OpenOutbox("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.OnApplied(ss.Written)
+
+	// the app's edit to the newer article is still waiting to be written when the older article is fixed in the sheet.
+	a := (*database)[0]
+	a.Title = "Edited in app"
+	if err := database.EditArticle("0", a, tm, qc); err != nil {
+		t.Fatal(err)
+	}
+	o.Enqueue(ss.BackupArticlesWrite(database))
+	rows := fake.Values(testSheetID, "Articles")
+	rows[0][0] = "Fixed in sheet"
+	fake.SetValues(testSheetID, "Articles", rows)
+
+	result, err := ss.Sync(ctx, database, qnDB, tm, qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Applied != 1 || result.Conflicts != 0 {
+		t.Errorf("got %+v, want the sheet edit applied without conflicts", result)
+	}
+
+	// the backup made after the sync replaces the pending one, so that neither edit is lost.
+	o.Enqueue(ss.BackupArticlesWrite(database))
+	if err := o.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Values(testSheetID, "Articles"); got[0][0] != "Edited in app" || got[1][0] != "Fixed in sheet" {
+		t.Errorf("got %v in the sheet, want both edits", got)
+	}
+
+	// undoing the app's edit before that is written is not mistaken for the sheet going back to the earlier title.
+	a.Title = "Newer article"
+	if err := database.EditArticle("0", a, tm, qc); err != nil {
+		t.Fatal(err)
+	}
+	o.Enqueue(ss.BackupArticlesWrite(database))
+	result, err = ss.Sync(ctx, database, qnDB, tm, qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Applied != 0 || result.Conflicts != 0 || (*database)[0].Title != "Newer article" {
+		t.Errorf("got %+v with title %q, want the app's undo kept", result, (*database)[0].Title)
+	}
+}
+
+func TestBackupKeepsRowsSkippedAtStartup(t *testing.T) {
+	rows := append(append([][]interface{}{}, testArticles...),
+		[]interface{}{"Bad date", "https://example.com/bad", "Media", "", "", "someday"},
+		[]interface{}{"No URL", "", "Media", "", "", "Mar 4, 2021"},
+		[]interface{}{"Older article again", "https://example.com/older", "Media", "", "", "Mar 5, 2021"},
+	)
+	fake := useFakeSheets(t, rows, testQuestions)
+	ctx := context.Background()
+
+	qnDB, err := InitQuestionsDB(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := NewArticlesDBByDate()
+	report, err := database.InitArticlesDB(ctx, qnDB, InitTopicsMap(), InitQuestionCounter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) != 3 || !strings.Contains(report.Errors[2].Reason, ErrDuplicateArticle.Error()) {
+		t.Fatalf("got %+v, want the bad, unkeyed and duplicate rows reported", report.Errors)
+	}
+
+	// a backup before the first sync, as when the app's first write is queued, keeps every row that was skipped.
+	ss := NewSheetSync(database, report)
+	if err := ss.BackupArticlesWrite(database).apply(ctx, fake); err != nil {
+		t.Fatal(err)
+	}
+	got := fake.Values(testSheetID, "Articles")
+	if len(got) != 5 || got[2][0] != "Bad date" || got[3][0] != "No URL" || got[4][0] != "Older article again" {
+		t.Errorf("rows skipped at startup were not kept: %v", got)
+	}
+	rejected := ss.Rejected()
+	if _, ok := rejected["https://example.com/bad"]; !ok {
+		t.Errorf("got rejected %v, want the bad row", rejected)
+	}
+	if _, ok := rejected["https://example.com/older"]; !ok {
+		t.Errorf("got rejected %v, want the duplicate row", rejected)
+	}
+}
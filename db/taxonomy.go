@@ -0,0 +1,163 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TopicTree records the topics in the topic hierarchy, such as Science > Technology > AI, with the parent of each, or an empty parent for a top-level topic. It is backed by the Topics sheet, which has a row of topic and parent for each entry.
+type TopicTree map[Topic]Topic
+
+// InitTopicTree reads the topic hierarchy from the Topics sheet. Rows that are incomplete, or that would make a topic its own ancestor, are skipped.
+func InitTopicTree(ctx context.Context) (TopicTree, error) {
+	tt := make(TopicTree)
+
+	srv, err := newSheetsService(ctx)
+	if err != nil {
+		return tt, fmt.Errorf("unable to start Sheets service: %w", err)
+	}
+
+	data, err := getSheetData(ctx, srv, "Topics")
+	if err != nil {
+		return tt, fmt.Errorf("unable to get sheet data: %w", err)
+	}
+
+	for _, row := range data.Values {
+		if len(row) == 0 {
+			continue
+		}
+		child := Topic(strings.TrimSpace(fmt.Sprint(row[0])))
+		var parent Topic
+		if len(row) > 1 {
+			parent = Topic(strings.TrimSpace(fmt.Sprint(row[1])))
+		}
+		if child == "" {
+			continue
+		}
+		tt.SetParent(child, parent)
+	}
+	return tt, nil
+}
+
+// SetParent makes parent the parent of child, or makes child a top-level topic if parent is empty. It returns an error, and leaves the tree unchanged, if parent is child or one of its descendants.
+func (tt TopicTree) SetParent(child, parent Topic) error {
+	if parent == child {
+		return fmt.Errorf("%q cannot be placed under itself", child)
+	}
+	for t := tt[parent]; t != ""; t = tt[t] {
+		if t == child {
+			return fmt.Errorf("%q cannot be placed under %q, which is one of its subtopics", child, parent)
+		}
+	}
+	tt[child] = parent
+	if _, ok := tt[parent]; !ok && parent != "" {
+		tt[parent] = ""
+	}
+	return nil
+}
+
+// Path returns the ancestors of t from the top of the hierarchy down, followed by t itself.
+func (tt TopicTree) Path(t Topic) []Topic {
+	path := []Topic{t}
+	seen := map[Topic]bool{t: true}
+	for p := tt[t]; p != "" && !seen[p]; p = tt[p] {
+		seen[p] = true
+		path = append([]Topic{p}, path...)
+	}
+	return path
+}
+
+// Children returns the topics whose parent is t, sorted by name.
+func (tt TopicTree) Children(t Topic) []Topic {
+	var children []Topic
+	for child, parent := range tt {
+		if parent == t && t != "" {
+			children = append(children, child)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i] < children[j] })
+	return children
+}
+
+// Descendants returns the children of t, their children, and so on, sorted by name.
+func (tt TopicTree) Descendants(t Topic) []Topic {
+	var descendants []Topic
+	queue := tt.Children(t)
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+		descendants = append(descendants, d)
+		queue = append(queue, tt.Children(d)...)
+	}
+	sort.Slice(descendants, func(i, j int) bool { return descendants[i] < descendants[j] })
+	return descendants
+}
+
+// Rename moves the place of the topic from in the hierarchy to the topic to, matching from regardless of case, as RenameTopic does for articles. The subtopics of from become subtopics of to, and to takes the parent of from unless it already has one.
+func (tt TopicTree) Rename(from, to Topic) {
+	renamed := func(t Topic) Topic {
+		if strings.EqualFold(string(t), string(from)) {
+			return to
+		}
+		return t
+	}
+
+	old := make(TopicTree, len(tt))
+	for child, parent := range tt {
+		old[child] = parent
+		delete(tt, child)
+	}
+
+	var fromParent Topic
+	var known bool
+	for child, parent := range old {
+		if renamed(child) == to {
+			known = true
+			if child != to {
+				fromParent = renamed(parent)
+				continue
+			}
+		}
+		if renamed(parent) == renamed(child) {
+			parent = ""
+		}
+		tt[child] = renamed(parent)
+	}
+	if !known {
+		return
+	}
+
+	parent := tt[to]
+	if parent == "" {
+		parent = fromParent
+	}
+	tt[to] = ""
+	// the parent is dropped if merging the two topics has made it one of to's subtopics.
+	tt.SetParent(to, parent)
+}
+
+// BackupTopicsWrite returns the SheetWrite that backs up the topic hierarchy to the Topics sheet.
+func BackupTopicsWrite(tt TopicTree) SheetWrite {
+	children := make([]Topic, 0, len(tt))
+	for child := range tt {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i] < children[j] })
+
+	values := make([][]interface{}, 0, len(tt))
+	for _, child := range children {
+		values = append(values, []interface{}{string(child), string(tt[child])})
+	}
+
+	return SheetWrite{
+		Description:   "back up the topic hierarchy",
+		SpreadsheetID: config.SheetID, // Articles DB new
+		Range:         "Topics",
+		Values:        values,
+		InputOption:   "RAW",
+		Replace:       true,
+	}
+}
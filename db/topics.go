@@ -1,6 +1,9 @@
 package db
 
-import "sort"
+import (
+	"sort"
+	"strings"
+)
 
 // Topic represents a searchable tag for each article.
 type Topic string
@@ -52,3 +55,43 @@ func GetTopicsCount(tm TopicsMap) TopicsCount {
 
 	return tc
 }
+
+// RenameTopic renames the topic from to the topic to in every article in the database, updating the TopicsMap to match. Topics are matched regardless of case. An article already tagged with to is not tagged twice, so renaming can also be used to merge two topics. It returns the number of articles changed.
+func RenameTopic(database *ArticlesDBByDate, tm TopicsMap, from, to Topic) int {
+	var n int
+	for i, a := range *database {
+		topics := make([]Topic, 0, len(a.Topics))
+		var renamed bool
+		for _, t := range a.Topics {
+			if strings.EqualFold(string(t), string(from)) {
+				t = to
+				renamed = true
+			}
+			if !containsTopic(topics, t) {
+				topics = append(topics, t)
+			}
+		}
+		if !renamed {
+			continue
+		}
+
+		for _, t := range a.Topics {
+			tm.Decrement(t)
+		}
+		for _, t := range topics {
+			tm.Increment(t)
+		}
+		(*database)[i].Topics = topics
+		n++
+	}
+	return n
+}
+
+func containsTopic(topics []Topic, topic Topic) bool {
+	for _, t := range topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenameTopic(t *testing.T) {
+	database := &ArticlesDBByDate{
+		{Title: "a", Topics: []Topic{"Environment", "Climate"}},
+		{Title: "b", Topics: []Topic{"climate"}},
+		{Title: "c", Topics: []Topic{"Media"}},
+	}
+	tm := TopicsMap{"Environment": 1, "Climate": 1, "climate": 1, "Media": 1}
+
+	if n := RenameTopic(database, tm, "Climate", "Environment"); n != 2 {
+		t.Errorf("got %d articles changed, want 2", n)
+	}
+
+	var got [][]Topic
+	for _, a := range *database {
+		got = append(got, a.Topics)
+	}
+	want := [][]Topic{{"Environment"}, {"Environment"}, {"Media"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got topics %v, want %v", got, want)
+	}
+	if wantTM := (TopicsMap{"Environment": 2, "Media": 1}); !reflect.DeepEqual(tm, wantTM) {
+		t.Errorf("got topics map %v, want %v", tm, wantTM)
+	}
+}
+
+func TestTopicTree(t *testing.T) {
+	tt := TopicTree{}
+	if err := tt.SetParent("Technology", "Science"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tt.SetParent("Ai", "Technology"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tt.SetParent("Science", "Ai"); err == nil {
+		t.Error("placing a topic under its own subtopic did not fail")
+	}
+
+	if got, want := tt.Path("Ai"), []Topic{"Science", "Technology", "Ai"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got path %v, want %v", got, want)
+	}
+	if got, want := tt.Descendants("Science"), []Topic{"Ai", "Technology"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got descendants %v, want %v", got, want)
+	}
+
+	database := &ArticlesDBByDate{
+		{Title: "a", Topics: []Topic{"Ai"}},
+		{Title: "b", Topics: []Topic{"Science", "Technology"}},
+		{Title: "c", Topics: []Topic{"Media"}},
+	}
+	var got []string
+	for _, a := range *SearchTopic("science", tt, database) {
+		got = append(got, a.Title)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got search results %v, want %v", got, want)
+	}
+
+	// merging Science into its own subtopic AI leaves AI at the top, with Technology under it.
+	tt.Rename("science", "Ai")
+	if want := (TopicTree{"Ai": "", "Technology": "Ai"}); !reflect.DeepEqual(tt, want) {
+		t.Errorf("got tree %v after rename, want %v", tt, want)
+	}
+}
+
+func TestVocabulary(t *testing.T) {
+	v := NewVocabulary(TopicsMap{"Environment": 2, "Media": 1}, TopicTree{"Climate Change": "Environment", "Environment": ""})
+	qnDB := QuestionsDB{
+		"2019 6":  {Year: "2019", Number: "6", Wording: "Is the media a force for good?"},
+		"2020 10": {Year: "2020", Number: "10", Wording: "Consider the view that the environment is beyond saving."},
+	}
+
+	a := &Article{Topics: []Topic{"environment", "Enviroment", "Clmate"}}
+	err := v.Check(a)
+	if !errors.Is(err, ErrUnknownTopic) || !strings.HasSuffix(err.Error(), ": Enviroment, Clmate") {
+		t.Errorf("got error %v, want the unknown topics listed", err)
+	}
+	if a.Topics[0] != "Environment" {
+		t.Errorf("got topic %q, want the vocabulary's spelling", a.Topics[0])
+	}
+
+	var got []string
+	for _, sug := range Suggest("envir", v, qnDB, 10) {
+		got = append(got, sug.Tag)
+	}
+	if want := []string{"Environment", "2020-Q10"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got suggestions %v, want %v", got, want)
+	}
+
+	got = nil
+	for _, sug := range Suggest("2019 q", v, qnDB, 10) {
+		got = append(got, sug.Tag)
+	}
+	if want := []string{"2019-Q6"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got suggestions %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RowError is a row of the Articles sheet that could not be loaded into the articles database. Row is the 1-based row number in the sheet.
+type RowError struct {
+	Row    int
+	Title  string
+	Reason string
+
+	rec       Record
+	duplicate bool
+}
+
+// ValidationReport is the result of loading the Articles sheet with InitArticlesDB. Rows that fail validation are skipped and listed in Errors, so that one bad row does not stop the app from starting with the rest.
+type ValidationReport struct {
+	Checked time.Time
+	Rows    int
+	Loaded  int
+	Errors  []RowError
+}
+
+// OK reports whether every row of the Articles sheet was loaded.
+func (rep *ValidationReport) OK() bool { return len(rep.Errors) == 0 }
+
+// String summarises the report, listing each row that was skipped, for sending to the admins.
+func (rep *ValidationReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Loaded %d of %d rows from the Articles sheet at %s.\n", rep.Loaded, rep.Rows, rep.Checked.Format("Jan 2, 2006 3:04 PM"))
+	if rep.OK() {
+		return sb.String()
+	}
+
+	fmt.Fprintf(&sb, "\nThe following %d row(s) were skipped and need to be corrected in the sheet:\n", len(rep.Errors))
+	for _, e := range rep.Errors {
+		if e.Title != "" {
+			fmt.Fprintf(&sb, "- Row %d (%q): %s\n", e.Row, e.Title, e.Reason)
+			continue
+		}
+		fmt.Fprintf(&sb, "- Row %d: %s\n", e.Row, e.Reason)
+	}
+	return sb.String()
+}
+
+func (rep *ValidationReport) skip(row int, rec Record, reason string) {
+	rep.Errors = append(rep.Errors, RowError{Row: row, Title: strings.TrimSpace(rec.Title), Reason: reason, rec: rec})
+}
+
+func (rep *ValidationReport) skipDuplicate(row int, rec Record, first int) {
+	rep.skip(row, rec, fmt.Sprintf("%v: same URL as row %d", ErrDuplicateArticle, first))
+	rep.Errors[len(rep.Errors)-1].duplicate = true
+}
+
+// rowToRecord converts a row of the Articles sheet into a Record, tolerating rows that are shorter than the full column layout.
+func rowToRecord(row []interface{}) Record {
+	cells := make([]string, 0, len(row))
+	for _, c := range row {
+		cells = append(cells, fmt.Sprint(c))
+	}
+	return recordFromCells(cells)
+}
+
+// isBlank reports whether every field of the record is empty.
+func (rec Record) isBlank() bool {
+	return strings.TrimSpace(rec.Title) == "" && strings.TrimSpace(rec.URL) == "" && strings.TrimSpace(rec.Date) == "" &&
+		len(trimAll(rec.Topics)) == 0 && len(trimAll(rec.Questions)) == 0
+}
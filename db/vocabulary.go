@@ -0,0 +1,130 @@
+// Package db provides functions and types relevant to the backend database for the article feed.
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Vocabulary is the set of approved topics: every topic tagged on an article or recorded in the topic hierarchy. It maps the lower case form of each topic to its usual spelling.
+type Vocabulary map[string]Topic
+
+// NewVocabulary returns the Vocabulary of the topics in tm and tt.
+func NewVocabulary(tm TopicsMap, tt TopicTree) Vocabulary {
+	v := make(Vocabulary, len(tm)+len(tt))
+	for t := range tt {
+		v[strings.ToLower(string(t))] = t
+	}
+	for t := range tm {
+		if _, ok := v[strings.ToLower(string(t))]; !ok {
+			v[strings.ToLower(string(t))] = t
+		}
+	}
+	return v
+}
+
+// Topics returns the topics in the vocabulary, sorted by name.
+func (v Vocabulary) Topics() []Topic {
+	topics := make([]Topic, 0, len(v))
+	for _, t := range v {
+		topics = append(topics, t)
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i] < topics[j] })
+	return topics
+}
+
+// Check changes the topics of a to the vocabulary's spelling, and returns an error wrapping ErrUnknownTopic that lists every topic of a not in the vocabulary.
+func (v Vocabulary) Check(a *Article) error {
+	var unknown []string
+	for i, t := range a.Topics {
+		known, ok := v[strings.ToLower(string(t))]
+		if !ok {
+			unknown = append(unknown, string(t))
+			continue
+		}
+		a.Topics[i] = known
+	}
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownTopic, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// CheckVocabulary rejects the valid rows of the report that are tagged with topics not in v, as the add article form does when the vocabulary is controlled.
+func (rep *ImportReport) CheckVocabulary(v Vocabulary) {
+	for i, r := range rep.Results {
+		if r.Err != nil {
+			continue
+		}
+		if err := v.Check(r.Article); err != nil {
+			rep.Results[i].Article, rep.Results[i].Err = nil, err
+		}
+	}
+}
+
+// Suggestion is a tag suggested to a curator while they type. Tag is written in the form used in the add article form's tags field, and Label describes it.
+type Suggestion struct {
+	Tag   string `json:"tag"`
+	Label string `json:"label"`
+	Kind  string `json:"kind"`
+}
+
+// Kinds of Suggestion.
+const (
+	SuggestTopic    = "topic"
+	SuggestQuestion = "question"
+)
+
+// Suggest returns up to limit topics from v and questions from qnDB that match what has been typed so far. Tags that start with typed come first, followed by tags that contain it, and questions whose wording contains it.
+func Suggest(typed string, v Vocabulary, qnDB QuestionsDB, limit int) []Suggestion {
+	typed = strings.ToLower(strings.TrimSpace(typed))
+	if typed == "" {
+		return nil
+	}
+
+	type ranked struct {
+		Suggestion
+		rank int
+	}
+	var matches []ranked
+
+	for lower, t := range v {
+		switch {
+		case strings.HasPrefix(lower, typed):
+			matches = append(matches, ranked{Suggestion{string(t), string(t), SuggestTopic}, 0})
+		case strings.Contains(lower, typed):
+			matches = append(matches, ranked{Suggestion{string(t), string(t), SuggestTopic}, 1})
+		}
+	}
+
+	// question tags are compared without spaces and dashes, so that "2019 q6", "2019-Q6" and "2019q6" all match, as do "njc prelim 2022" and "NJC-Prelim-2022-Q3".
+	compact := strings.NewReplacer(" ", "", "-", "")
+	for _, qn := range qnDB {
+		tag := qn.Tag()
+		sug := Suggestion{tag, fmt.Sprintf("%s: %s", tag, qn.Wording), SuggestQuestion}
+		switch {
+		case strings.HasPrefix(compact.Replace(strings.ToLower(tag)), compact.Replace(typed)):
+			matches = append(matches, ranked{sug, 0})
+		case len(typed) > 2 && strings.Contains(strings.ToLower(qn.Wording), typed):
+			matches = append(matches, ranked{sug, 2})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].rank != matches[j].rank {
+			return matches[i].rank < matches[j].rank
+		}
+		return matches[i].Tag < matches[j].Tag
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	suggestions := make([]Suggestion, 0, len(matches))
+	for _, m := range matches {
+		suggestions = append(suggestions, m.Suggestion)
+	}
+	return suggestions
+}
@@ -1,20 +1,35 @@
 package main
 
 import (
+	"flag"
 	"log"
-	"os"
 
+	"github.com/jwnpoh/njcgpnewsfeed/db"
 	"github.com/jwnpoh/njcgpnewsfeed/web"
 )
 
 func main() {
-	s := web.NewServer()
+	configPath := flag.String("config", db.DefaultConfigPath, "path to the JSON config file; settings in the environment take precedence")
+	flag.Parse()
 
-	s.Port = os.Getenv("PORT")
-	if s.Port == "" {
-		s.Port = "8080"
-		log.Printf("Defaulting to port %s", s.Port)
+	cfg, err := db.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
 	}
+	db.Configure(cfg)
+
+	// for offline development, read and write a local JSON file instead of Google Sheets.
+	if cfg.OfflineSheets != "" {
+		fake, err := db.LoadFakeSheets(cfg.OfflineSheets)
+		if err != nil {
+			log.Fatal(err)
+		}
+		db.UseSheetsClient(fake)
+		log.Printf("Using offline sheets data from %s", cfg.OfflineSheets)
+	}
+
+	s := web.NewServer(cfg)
+
 	s.TemplateDir = "html"
 	s.AssetPath = "/assets/"
 	s.AssetDir = "assets"
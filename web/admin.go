@@ -6,12 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"html"
+	"html/template"
 	"io/ioutil"
 	"net/http"
-	"os"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -23,26 +25,92 @@ type customError struct {
 	HelpMsg string
 }
 
-func setCookie(w http.ResponseWriter, r *http.Request) {
+// sessionAge is how long a curator stays logged in after they last open an admin page.
+const sessionAge = 10 * time.Minute
+
+// session is a curator logged in to the admin pages.
+type session struct {
+	curator string
+	expires time.Time
+}
+
+// sessions records the session IDs given out at login and which curator logged in with each, so that articles can record who added and reviewed them. Only these session IDs are let in to the admin pages.
+var sessions = struct {
+	sync.Mutex
+	active map[string]session
+}{active: make(map[string]session)}
+
+func setCookie(w http.ResponseWriter, r *http.Request, curator string) {
+	id := uuid.New().String()
+	now := time.Now()
+	sessions.Lock()
+	// drop the sessions that have expired, so that the map does not grow with every login.
+	active := make(map[string]session, len(sessions.active)+1)
+	for k, v := range sessions.active {
+		if now.Before(v.expires) {
+			active[k] = v
+		}
+	}
+	active[id] = session{curator: curator, expires: now.Add(sessionAge)}
+	sessions.active = active
+	sessions.Unlock()
+
 	http.SetCookie(w, &http.Cookie{
 		Name:     "sessionID",
-		Value:    uuid.New().String(),
-		MaxAge:   600,
+		Value:    id,
+		MaxAge:   int(sessionAge.Seconds()),
 		HttpOnly: true,
 	})
 }
 
-func checkCookie(w http.ResponseWriter, r *http.Request) bool {
+// lookupSession returns the session of r if it was given out at login and has not expired.
+func lookupSession(r *http.Request) (session, bool) {
 	c, err := r.Cookie("sessionID")
 	if err != nil {
+		return session{}, false
+	}
+	sessions.Lock()
+	defer sessions.Unlock()
+	sess, ok := sessions.active[c.Value]
+	if !ok || !time.Now().Before(sess.expires) {
+		return session{}, false
+	}
+	return sess, true
+}
+
+// curator returns the name of the curator logged in to the session of r, or an empty string if r is not from a logged in curator.
+func curator(r *http.Request) string {
+	sess, _ := lookupSession(r)
+	return sess.curator
+}
+
+// checkCookie reports whether r is from a logged in curator, and if so keeps them logged in for another sessionAge.
+func checkCookie(w http.ResponseWriter, r *http.Request) bool {
+	if _, ok := lookupSession(r); !ok {
 		return false
 	}
 
-	c.MaxAge = 600
+	c, _ := r.Cookie("sessionID")
+	sessions.Lock()
+	if sess, ok := sessions.active[c.Value]; ok {
+		sess.expires = time.Now().Add(sessionAge)
+		sessions.active[c.Value] = sess
+	}
+	sessions.Unlock()
+
+	c.MaxAge = int(sessionAge.Seconds())
 	http.SetCookie(w, c)
 	return true
 }
 
+// rankedCount returns the number of questions or topics listed at the top and bottom of the dashboard rankings, out of n.
+func rankedCount(n int) int {
+	if n < 5 {
+		return n
+	}
+	return 5
+}
+
 func login(w http.ResponseWriter, r *http.Request) {
 	var Stats struct {
 		TotalArticles   int
@@ -51,23 +119,78 @@ func login(w http.ResponseWriter, r *http.Request) {
 		BottomQuestions db.QuestionsByArticleCount
 		TopTopics       db.TopicsCount
 		BottomTopics    db.TopicsCount
+		Unsynced        []db.SheetWrite
+		Conflicts       int
+		LastSync        time.Time
+		Validation      *db.ValidationReport
+		RecentAverage   float64
+		Diversity       db.SourceDiversity
+		Pending         int
+		Scheduled       int
+		Suggestions     int
+		Trends          struct {
+			Daily, Weekly, Monthly, Topics, Sources, Curators template.HTML
+		}
 	}
 
+	// only published articles are counted, so that drafts and scheduled articles do not show up in the stats until students can see them.
+	s.mu.Lock()
+	published := db.PublishedArticles(s.Articles)
+	s.mu.Unlock()
+
 	// get total number of articles in db.
-	Stats.TotalArticles = s.Articles.Len()
+	Stats.TotalArticles = published.Len()
 
 	// get average number of articles per day.
-	Stats.AverageArticles = getAverageNumberOfArticles(Stats.TotalArticles)
+	Stats.AverageArticles = getAverageNumberOfArticles(Stats.TotalArticles, s.Config.LaunchTime())
 
-	// get top 5 and bottom 5 questions ranked by number of articles tagged.
-	qc := db.RankQuestionsByArticleCount(s.QuestionCounter)
-	Stats.TopQuestions = qc[:5]
-	Stats.BottomQuestions = qc[len(qc)-5:]
+	// the questions and topics are ranked by the published articles tagged with them, rather than by s.QuestionCounter and s.Topics, which count every article.
+	s.mu.Lock()
+	tm, qcounter := db.CountTags(published, s.Questions)
+	s.mu.Unlock()
+
+	// get top 5 and bottom 5 questions ranked by number of articles tagged, or as many as have been tagged when there are fewer, e.g. before any article has been published.
+	qc := db.RankQuestionsByArticleCount(qcounter)
+	Stats.TopQuestions = qc[:rankedCount(len(qc))]
+	Stats.BottomQuestions = qc[len(qc)-rankedCount(len(qc)):]
 
 	// get top 5 and bottom 5 topics ranked by number of articles tagged.
-	tc := db.GetTopicsCount(s.Topics)
-	Stats.TopTopics = tc[:5]
-	Stats.BottomTopics = tc[len(tc)-5:]
+	tc := db.GetTopicsCount(tm)
+	Stats.TopTopics = tc[:rankedCount(len(tc))]
+	Stats.BottomTopics = tc[len(tc)-rankedCount(len(tc)):]
+
+	// get changes that have not reached Google Sheets yet.
+	Stats.Unsynced = s.Outbox.Pending()
+
+	// get edits made in both the sheet and the app that need an admin to resolve.
+	Stats.Conflicts = len(s.Sync.Conflicts()) + len(s.Sync.Rejected())
+	Stats.LastSync = s.Sync.LastSync()
+
+	// get the rows of the Articles sheet that could not be loaded when the app started.
+	Stats.Validation = s.Validation
+
+	// get the number of articles published over time, per topic, per source and per curator, for the trend charts.
+	now := time.Now()
+	s.mu.Lock()
+	daily := db.ArticlesOverTime(published, db.Day, 30, now)
+	weekly := db.ArticlesOverTime(published, db.Week, 26, now)
+	monthly := db.ArticlesOverTime(published, db.Month, 12, now)
+	topics := db.TopicsOverTime(published, db.Month, 12, now, 5)
+	sources := db.ArticlesBySource(published, monthly.Buckets[0].Start)
+	curators := db.CuratorsOverTime(published, db.Month, 12, now)
+	Stats.Diversity = db.NewSourceDiversity(published, now.AddDate(0, 0, -90))
+	Stats.Pending = db.FilterByStatus(s.Articles, db.Pending).Len()
+	Stats.Scheduled = db.FilterByStatus(s.Articles, db.Scheduled).Len()
+	Stats.Suggestions = len(s.Suggestions.Pending())
+	s.mu.Unlock()
+
+	Stats.RecentAverage = daily.Average()
+	Stats.Trends.Daily = barChart("Articles per day in the past 30 days", daily)
+	Stats.Trends.Weekly = barChart("Articles per week in the past 26 weeks", weekly)
+	Stats.Trends.Monthly = barChart("Articles per month in the past 12 months", monthly)
+	Stats.Trends.Topics = lineChart("Articles per month for the top topics", topics)
+	Stats.Trends.Sources = sourceChart("Articles per source in the past 12 months", sources, 10)
+	Stats.Trends.Curators = lineChart("Articles per month by curator", curators)
 
 	err := tpl.ExecuteTemplate(w, "dashboard.html", Stats)
 	if err != nil {
@@ -88,8 +211,8 @@ func admin(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method == "POST" {
 		r.ParseForm()
-		if r.Form.Get("user") == os.Getenv("ADMIN") && r.Form.Get("password") == os.Getenv("PASSWORD") {
-			setCookie(w, r)
+		if s.Config.CanLogin(r.Form.Get("user"), r.Form.Get("password")) {
+			setCookie(w, r, r.Form.Get("user"))
 			login(w, r)
 			return
 		}
@@ -117,7 +240,16 @@ func form(w http.ResponseWriter, r *http.Request) {
 		addArticle(w, r)
 	}
 
-	err := tpl.ExecuteTemplate(w, "form.html", nil)
+	// a suggestion accepted from the moderation queue fills in the form, and the title is then fetched from its URL.
+	data := struct {
+		RequireReview bool
+		Suggestion    db.StudentSuggestion
+	}{
+		RequireReview: s.Config.RequireReview,
+		Suggestion:    pendingSuggestion(r.FormValue("suggestion")),
+	}
+
+	err := tpl.ExecuteTemplate(w, "form.html", data)
 	if err != nil {
 		msg := customError{
 			ErrMsg:  fmt.Sprintf("%v", err),
@@ -129,10 +261,13 @@ func form(w http.ResponseWriter, r *http.Request) {
 }
 
 type formData struct {
-	title string
-	url   string
-	date  string
-	tags  []string
+	title   string
+	url     string
+	date    string
+	tags    []string
+	summary string
+	note    string
+	prompts []string
 }
 
 func addArticle(w http.ResponseWriter, r *http.Request) {
@@ -146,6 +281,7 @@ func addArticle(w http.ResponseWriter, r *http.Request) {
 	url := r.Form.Get("url")
 	date := r.Form.Get("date")
 	tags := splitTags(r.Form.Get("tags"))
+	summary, note, prompts := formNotes(r)
 
 	if title == "" || url == "" || date == "" || r.Form.Get("tags") == "" {
 		msg := customError{ErrMsg: "Empty field(s) on form.", HelpMsg: "Make sure the form is fully filled."}
@@ -153,11 +289,20 @@ func addArticle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	publishAt, msg, err := formPublishTime(r)
+	if err != nil {
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+
 	data := formData{
 		title,
 		url,
 		date,
 		tags,
+		summary,
+		note,
+		prompts,
 	}
 
 	a, msg, err := formToArticle(data)
@@ -166,10 +311,28 @@ func addArticle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	a.PublishAt = publishAt
+	// articles published ahead of their publish time are scheduled, and published by the server when the time comes.
+	a.Submit(articleStatus(r), curator(r), s.Config.RequireReview, time.Now())
+
+	s.mu.Lock()
 	a.AddArticleToDB(s.Articles, s.Topics, s.QuestionCounter)
-	go db.BackupQuestions(s.Ctx, s.Questions)
-	go db.AppendArticle(s.Ctx, a)
-	go db.AppendArticleToOld(s.Ctx, a)
+	queueSheetWrites(db.BackupQuestionsWrite(s.Questions), db.AppendArticleWrite(a))
+	s.mu.Unlock()
+	// the old feed sheet is public, so articles are only added to it once they are published.
+	if a.IsPublished() {
+		queueSheetWrites(db.AppendArticleToOldWrite(a))
+	}
+	acceptSuggestion(r, r.Form.Get("suggestion"))
+}
+
+// articleStatus returns the status chosen with the buttons of the add article form: saved as a draft, submitted for review, or published.
+func articleStatus(r *http.Request) db.Status {
+	st, err := db.ParseStatus(r.Form.Get("status"))
+	if err != nil || st == db.Archived {
+		st = db.Draft
+	}
+	return st
 }
 
 func delete(w http.ResponseWriter, r *http.Request) {
@@ -182,7 +345,7 @@ func delete(w http.ResponseWriter, r *http.Request) {
 		deleteArticle(w, r)
 	}
 
-	data := *s.Articles
+	data := *s.copyArticles()
 	err := tpl.ExecuteTemplate(w, "delete.html", data)
 	if err != nil {
 		msg := customError{
@@ -201,10 +364,21 @@ func deleteArticle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	r.ParseForm()
-	index, _ := strconv.Atoi(r.Form.Get("index"))
 
-	s.Articles.RemoveArticle(index, s.Topics, s.QuestionCounter)
-	go backup(w, r)
+	s.takeSnapshot(db.SnapshotBeforeDelete)
+	// the article is found by its URL, as the sheet sync may have added or removed articles since the list was shown.
+	s.mu.Lock()
+	i := s.Articles.IndexOf(r.Form.Get("article"))
+	if i >= 0 {
+		s.Articles.RemoveArticle(i, s.Topics, s.QuestionCounter)
+	}
+	s.mu.Unlock()
+	if i < 0 {
+		msg := customError{ErrMsg: "Unable to find the article to be deleted.", HelpMsg: "It may have been deleted already. Go back and select an article again."}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+	backup(w, r)
 }
 
 func edit(w http.ResponseWriter, r *http.Request) {
@@ -215,11 +389,10 @@ func edit(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method == "POST" {
 		r.ParseForm()
-		index := r.Form.Get("index")
-		http.Redirect(w, r, "/editArticle?index="+index, http.StatusSeeOther)
+		http.Redirect(w, r, "/editArticle?article="+url.QueryEscape(r.Form.Get("article")), http.StatusSeeOther)
 	}
 
-	data := *s.Articles
+	data := *s.copyArticles()
 	err := tpl.ExecuteTemplate(w, "editList.html", data)
 	if err != nil {
 		msg := customError{
@@ -240,12 +413,18 @@ func editArticle(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
 		editTheArticle(w, r)
 		http.Redirect(w, r, "/edit", http.StatusSeeOther)
+		return
 	}
 
 	r.ParseForm()
-	index := r.Form.Get("index")
-	i, err := strconv.Atoi(index)
-	if err != nil {
+	s.mu.Lock()
+	i := s.Articles.IndexOf(r.Form.Get("article"))
+	var data db.Article
+	if i >= 0 {
+		data = (*s.Articles)[i]
+	}
+	s.mu.Unlock()
+	if i < 0 {
 		msg := customError{
 			ErrMsg:  "No article seems to have been selected.",
 			HelpMsg: "Go back and select an article to be edited.",
@@ -254,16 +433,7 @@ func editArticle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	articles := *s.Articles
-	data := struct {
-		Index int
-		db.Article
-	}{
-		i,
-		articles[i],
-	}
-
-	err = tpl.ExecuteTemplate(w, "edit.html", data)
+	err := tpl.ExecuteTemplate(w, "edit.html", data)
 	if err != nil {
 		msg := customError{
 			ErrMsg:  fmt.Sprintf("%v", err),
@@ -276,17 +446,27 @@ func editArticle(w http.ResponseWriter, r *http.Request) {
 
 func editTheArticle(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
-	index := r.Form.Get("hidden")
+	original := r.Form.Get("article")
 	title := r.Form.Get("title")
 	url := r.Form.Get("url")
 	date := strings.TrimSpace(r.Form.Get("date"))
 	tags := splitTags(r.Form.Get("tags"))
+	summary, note, prompts := formNotes(r)
+
+	publishAt, msg, err := formPublishTime(r)
+	if err != nil {
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
 
 	data := formData{
 		title,
 		url,
 		date,
 		tags,
+		summary,
+		note,
+		prompts,
 	}
 
 	a, msg, err := formToArticle(data)
@@ -295,8 +475,31 @@ func editTheArticle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.Articles.EditArticle(index, *a, s.Topics, s.QuestionCounter)
-	go backup(w, r)
+	s.takeSnapshot(db.SnapshotBeforeEdit)
+	// the article is found by the URL it had when the form was shown, as the sheet sync may have added, removed or re-sorted articles since.
+	s.mu.Lock()
+	i := s.Articles.IndexOf(original)
+	switch {
+	case i < 0:
+		msg = customError{ErrMsg: "Unable to find the article to be edited.", HelpMsg: "It may have been deleted. Go back and select an article again."}
+	case a.URL != original && s.Articles.IndexOf(a.URL) >= 0:
+		msg = customError{ErrMsg: fmt.Sprintf("Another article already has the URL %v.", a.URL), HelpMsg: "Check the URL, or delete the other article first."}
+	default:
+		old := (*s.Articles)[i]
+		// editing an article keeps its place in the review workflow.
+		a.Status, a.AddedBy, a.ReviewedBy = old.Status, old.AddedBy, old.ReviewedBy
+		// the publish time can only be changed until the article is published.
+		if !old.IsPublished() {
+			a.PublishAt = publishAt
+		}
+		s.Articles.EditArticle(strconv.Itoa(i), *a, s.Topics, s.QuestionCounter)
+	}
+	s.mu.Unlock()
+	if msg.ErrMsg != "" {
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+	backup(w, r)
 }
 
 func addQuestion(w http.ResponseWriter, r *http.Request) {
@@ -311,16 +514,36 @@ func addQuestion(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		r.ParseForm()
-
-		year := r.Form.Get("year")
-		number := r.Form.Get("number")
-		wording := r.Form.Get("wording")
+		qn, err := db.NormalizeQuestion(questionFromForm(r))
+		if err != nil {
+			msg := customError{ErrMsg: fmt.Sprintf("Unable to add the question - %v.", err), HelpMsg: "The year, question number and paper must be numbers, e.g. 2019, 6 and 1."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
 
-		qn := db.Question{Year: year, Number: number, Wording: wording}
-		key := year + " " + number
-		s.Questions[key] = qn
-		go db.AppendQuestion(s.Ctx, qn)
+		key := qn.Key()
+		s.mu.Lock()
+		_, exists := s.Questions[key]
+		s.mu.Unlock()
+
+		// updating a question changes its wording in the articles tagged with it too.
+		if exists {
+			s.takeSnapshot(db.SnapshotBeforeEdit)
+			err := s.changeQuestions(func() error {
+				_, err := db.UpdateQuestion(s.Articles, s.Questions, s.QuestionCounter, key, qn)
+				return err
+			})
+			if err != nil {
+				msg := customError{ErrMsg: fmt.Sprintf("Unable to update the question - %v.", err), HelpMsg: "The year, question number and paper must be numbers, e.g. 2019, 6 and 1."}
+				http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+				return
+			}
+		} else {
+			s.mu.Lock()
+			s.Questions[key] = qn
+			s.mu.Unlock()
+			queueSheetWrites(db.AppendQuestionWrite(qn))
+		}
 	}
 
 	err := tpl.ExecuteTemplate(w, "addQuestion.html", nil)
@@ -335,8 +558,31 @@ func addQuestion(w http.ResponseWriter, r *http.Request) {
 }
 
 func backup(w http.ResponseWriter, r *http.Request) {
-	db.BackupArticles(s.Ctx, s.Articles)
-	db.BackupQuestions(s.Ctx, s.Questions)
+	// pull in edits made directly in the sheet first, so that the backup does not overwrite them.
+	s.pullSheetEdits()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queueSheetWrites(s.Sync.BackupArticlesWrite(s.Articles), db.BackupQuestionsWrite(s.Questions))
+}
+
+// queueSheetWrites adds writes to the outbox, from which they are synced to Google Sheets in the background.
+func queueSheetWrites(writes ...db.SheetWrite) {
+	for _, w := range writes {
+		if err := s.Outbox.Enqueue(w); err != nil {
+			fmt.Printf("Unable to save change to outbox, it will be lost on restart if not synced - %v\n", err)
+		}
+	}
+}
+
+func syncNow(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Redirect(w, r, "/admin", http.StatusUnauthorized)
+		return
+	}
+
+	s.Outbox.RetryNow()
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
 }
 
 func getTitle(w http.ResponseWriter, r *http.Request) {
@@ -388,62 +634,77 @@ func splitTags(tags string) []string {
 	return xtags
 }
 
-func formToArticle(data formData) (*db.Article, customError, error) {
-	a, err := db.NewArticle()
+// formNotes returns the optional summary, teacher's note and discussion prompts of the add and edit article forms, with one prompt per line.
+func formNotes(r *http.Request) (summary, note string, prompts []string) {
+	for _, p := range strings.Split(r.Form.Get("prompts"), "\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			prompts = append(prompts, p)
+		}
+	}
+	return strings.TrimSpace(r.Form.Get("summary")), strings.TrimSpace(r.Form.Get("note")), prompts
+}
+
+// formPublishTime returns the optional publish time of the add and edit article forms as a Unix time, or 0 if the article is to be published as soon as it is approved.
+func formPublishTime(r *http.Request) (int64, customError, error) {
+	t, err := db.ParsePublishTime(r.Form.Get("publish_at"))
 	if err != nil {
-		msg := customError{ErrMsg: "Unable to initialise new Article.", HelpMsg: "Please try again."}
-		return nil, msg, err
+		return 0, customError{ErrMsg: fmt.Sprintf("Unable to parse publish time %v", r.Form.Get("publish_at")), HelpMsg: "Check if the publish time has been entered correctly, or leave it empty to publish the article straight away."}, err
 	}
+	return t, customError{}, nil
+}
 
-	a.Title = data.title
-	a.URL = data.url
-	if err := a.SetDate(data.date); err != nil {
-		msg := customError{ErrMsg: fmt.Sprintf("Unable to parse date %v", data.date), HelpMsg: "Check if the date has been entered correctly"}
-		return nil, msg, err
+func formToArticle(data formData) (*db.Article, customError, error) {
+	rec := db.Record{
+		Title:   data.title,
+		URL:     data.url,
+		Date:    data.date,
+		Summary: data.summary,
+		Note:    data.note,
+		Prompts: data.prompts,
 	}
 
-	regex := regexp.MustCompile(`^\d{4}\s?-?\s?(q|Q)\d{1,2}$`)
-	regexYear := regexp.MustCompile(`^\d{4}`)
-	regexNumber := regexp.MustCompile(`(q|Q)\d{1,2}$`)
 	for _, t := range data.tags {
+		t = strings.TrimSpace(t)
 		if t == "" {
 			continue
 		}
-		t = strings.TrimSpace(t)
-		if regex.MatchString(t) {
-			year := regexYear.FindString(t)
-			number := strings.TrimLeft(regexNumber.FindString(t), "qQ")
-
-			// check if question exists
-			key := year + " " + number
-			_, ok := s.Questions[key]
-			if !ok {
-				msg := customError{ErrMsg: fmt.Sprintf("The question for %v Q%v does not exist in the database currently.", year, number), HelpMsg: "Please use the Add Question function to add the question to the database first, then try adding the article again."}
-				return nil, msg, errors.New("error")
-			}
-
-			qnDB, err := a.SetQuestionsNewArticle(year, number, s.Questions)
-			if err != nil {
-				msg := customError{ErrMsg: fmt.Sprintf("Unable to tag the question %s to the article. Article not created.", key), HelpMsg: "Check if the question tag has been formatted correctly, in the form '2020-Q10'."}
-				return nil, msg, err
-			}
-			s.Questions = qnDB
+		if db.IsQuestionTag(t) {
+			rec.Questions = append(rec.Questions, t)
 		} else {
-			a.SetTopics(strings.Title(t))
+			rec.Topics = append(rec.Topics, t)
 		}
 	}
-	return a, customError{}, nil
+
+	s.mu.Lock()
+	a, err := db.ParseArticle(rec, s.Questions)
+	s.mu.Unlock()
+	if err == nil && s.Config.ControlledVocabulary {
+		err = s.vocabulary().Check(a)
+	}
+	switch {
+	case err == nil:
+		return a, customError{}, nil
+	case errors.Is(err, db.ErrUnknownTopic):
+		return nil, customError{ErrMsg: fmt.Sprintf("These tags are not in the approved vocabulary: %s.", strings.TrimPrefix(err.Error(), db.ErrUnknownTopic.Error()+": ")), HelpMsg: "Check the spelling of the tags, or choose from the suggestions shown while typing. New topics can be added in the topic manager."}, err
+	case errors.Is(err, db.ErrMissingField):
+		return nil, customError{ErrMsg: "Empty field(s) on form.", HelpMsg: "Make sure the form is fully filled."}, err
+	case errors.Is(err, db.ErrInvalidDate):
+		return nil, customError{ErrMsg: fmt.Sprintf("Unable to parse date %v", data.date), HelpMsg: "Check if the date has been entered correctly"}, err
+	case errors.Is(err, db.ErrUnknownQuestion):
+		return nil, customError{ErrMsg: fmt.Sprintf("Unable to tag the article - %v.", err), HelpMsg: "Please use the Add Question function to add the question to the database first, then try adding the article again."}, err
+	default:
+		return nil, customError{ErrMsg: "Unable to tag the question(s) to the article. Article not created.", HelpMsg: "Check if the question tag has been formatted correctly, in the form '2020-Q10', '2019-P2-Q5' or 'NJC-Prelim-2022-Q3'."}, err
+	}
 }
 
-func getAverageNumberOfArticles(numOfArticles int) int {
+func getAverageNumberOfArticles(numOfArticles int, dateOfLaunch time.Time) int {
 	var average int
 
-	dateOfLaunch, err := time.Parse("Jan 2, 2006", "Jan 15, 2021")
-	if err != nil {
-		return -1
-	}
 	timeLive := time.Since(dateOfLaunch)
 	daysLive := int(timeLive.Hours()) / 24
+	if daysLive < 1 {
+		daysLive = 1
+	}
 
 	average = numOfArticles / daysLive
 	return average
@@ -0,0 +1,197 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// testArticleRows are the rows of the Articles sheet loaded by useTestSheets, newest first.
+var testArticleRows = [][]interface{}{
+	{"Budget boosts healthcare", "https://example.com/budget", "Economy", "", "", "Mar 3, 2021"},
+	{"Carbon tax to rise", "https://example.com/tax", "Environment\nEconomy", "2019 6", "", "Mar 1, 2021"},
+}
+
+// useTestSheets loads the server from a FakeSheets seeded with the given Articles sheet, as NewServer does from Google Sheets, with an empty outbox and snapshot directory, for the length of the test. Every curator is logged out.
+func useTestSheets(t *testing.T, articles [][]interface{}, cfg db.Config) *db.FakeSheets {
+	t.Helper()
+	cfg.SheetID, cfg.OldSheetID = "sheet", "old-sheet"
+	db.Configure(cfg)
+	fake := db.NewFakeSheets()
+	fake.SetValues("sheet", "Articles", articles)
+	fake.SetValues("sheet", "Questions", [][]interface{}{{"2019 6", "2019", "6", "How far is science a force for good?"}})
+	fake.SetValues("old-sheet", "feed", nil)
+	db.UseSheetsClient(fake)
+	t.Cleanup(func() {
+		db.UseSheetsClient(nil)
+		db.Configure(db.DefaultConfig())
+	})
+
+	ctx := context.Background()
+	qnDB, err := db.InitQuestionsDB(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database, tm, qc := db.NewArticlesDBByDate(), db.InitTopicsMap(), db.InitQuestionCounter()
+	report, err := database.InitArticlesDB(ctx, qnDB, tm, qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := db.OpenSnapshotStore(filepath.Join(t.TempDir(), "snapshots"), cfg.RetentionPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	useTestArticles(t, *database, cfg)
+	useTestOutbox(t)
+	old := struct {
+		ctx       context.Context
+		questions db.QuestionsDB
+		topics    db.TopicsMap
+		counter   db.QuestionCounter
+		sync      *db.SheetSync
+		snapshots *db.SnapshotStore
+		report    *db.ValidationReport
+	}{s.Ctx, s.Questions, s.Topics, s.QuestionCounter, s.Sync, s.Snapshots, s.Validation}
+	s.Ctx, s.Questions, s.Topics, s.QuestionCounter = ctx, qnDB, tm, qc
+	s.Sync, s.Snapshots, s.Validation = db.NewSheetSync(s.Articles, report), snapshots, report
+	t.Cleanup(func() {
+		s.Ctx, s.Questions, s.Topics, s.QuestionCounter = old.ctx, old.questions, old.topics, old.counter
+		s.Sync, s.Snapshots, s.Validation = old.sync, old.snapshots, old.report
+	})
+	return fake
+}
+
+// useTemplates parses the templates in the html directory, so that pages can be rendered.
+func useTemplates(t *testing.T) {
+	t.Helper()
+	if tpl == nil {
+		s.TemplateDir = filepath.Join("..", "html")
+		s.parseTemplates()
+	}
+}
+
+// sheetURLs returns the URL of every row of the Articles sheet.
+func sheetURLs(fake *db.FakeSheets) []string {
+	var urls []string
+	for _, row := range fake.Values("sheet", "Articles") {
+		urls = append(urls, row[1].(string))
+	}
+	return urls
+}
+
+func TestEditArticleByURL(t *testing.T) {
+	fake := useTestSheets(t, testArticleRows, db.DefaultConfig())
+	session := loginAs(t, "Mary")
+
+	// an article is added to the sheet after the curator opened the list of articles, moving the others down it.
+	fake.SetValues("sheet", "Articles", append([][]interface{}{{"Newest article", "https://example.com/newest", "Media", "", "", "Mar 5, 2021"}}, testArticleRows...))
+	s.pullSheetEdits()
+	if s.Articles.Len() != 3 {
+		t.Fatalf("got %d articles after the sync, want 3", s.Articles.Len())
+	}
+
+	form := url.Values{"article": {"https://example.com/tax"}, "title": {"Carbon tax to rise again"}, "url": {"https://example.com/tax"}, "date": {"Mar 1, 2021"}, "tags": {"Environment"}}
+	w := post(editArticle, "/editArticle", form, session)
+	if got := w.Header().Get("Location"); got != "/edit" {
+		t.Fatalf("got redirected to %q, want the list of articles", got)
+	}
+	titles := make(map[string]string)
+	for _, a := range *s.Articles {
+		titles[a.URL] = a.Title
+	}
+	if titles["https://example.com/tax"] != "Carbon tax to rise again" || titles["https://example.com/budget"] != "Budget boosts healthcare" || titles["https://example.com/newest"] != "Newest article" {
+		t.Errorf("got titles %v, want only the carbon tax article edited", titles)
+	}
+
+	refused := []struct {
+		name string
+		form url.Values
+	}{
+		{"deleted article", url.Values{"article": {"https://example.com/gone"}, "title": {"Gone"}, "url": {"https://example.com/gone"}, "date": {"Mar 1, 2021"}, "tags": {"Media"}}},
+		{"URL of another article", url.Values{"article": {"https://example.com/tax"}, "title": {"Carbon tax"}, "url": {"https://example.com/budget"}, "date": {"Mar 1, 2021"}, "tags": {"Media"}}},
+	}
+	for _, tt := range refused {
+		if got := post(editTheArticle, "/editArticle", tt.form, session).Header().Get("Location"); !strings.HasPrefix(got, "/error") {
+			t.Errorf("%s: got redirected to %q, want the error page", tt.name, got)
+		}
+	}
+	if s.Articles.Len() != 3 || s.Topics["Media"] != 1 {
+		t.Errorf("got %d articles and topics %v, want the refused edits to change nothing", s.Articles.Len(), s.Topics)
+	}
+}
+
+func TestDeleteArticleByURL(t *testing.T) {
+	fake := useTestSheets(t, testArticleRows, db.DefaultConfig())
+	session := loginAs(t, "Mary")
+
+	if got := post(deleteArticle, "/delete", url.Values{"article": {"https://example.com/gone"}}, session).Header().Get("Location"); !strings.HasPrefix(got, "/error") || s.Articles.Len() != 2 {
+		t.Fatalf("got redirected to %q with %d articles, want nothing deleted", got, s.Articles.Len())
+	}
+
+	post(deleteArticle, "/delete", url.Values{"article": {"https://example.com/tax"}}, session)
+	if s.Articles.Len() != 1 || (*s.Articles)[0].URL != "https://example.com/budget" || s.Topics["Environment"] != 0 {
+		t.Fatalf("got articles %v and topics %v, want the carbon tax article deleted", *s.Articles, s.Topics)
+	}
+	if err := s.Outbox.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if urls := sheetURLs(fake); len(urls) != 1 || urls[0] != "https://example.com/budget" {
+		t.Errorf("got Articles sheet %v, want the carbon tax article deleted", urls)
+	}
+}
+
+func TestDashboardWithoutPublishedArticles(t *testing.T) {
+	rows := [][]interface{}{
+		{"Draft article", "https://example.com/draft", "Media", "", "", "Mar 4, 2021", "", "", "", "draft", "Mary"},
+		{"Pending article", "https://example.com/pending", "Economy", "2019 6", "", "Mar 3, 2021", "", "", "", "pending review", "Mary"},
+		{"Scheduled article", "https://example.com/scheduled", "Environment", "", "", "Mar 2, 2021", "", "", "", "scheduled", "Mary", "John", "Mar 7, 2031 07:30"},
+	}
+	useTestSheets(t, rows, db.DefaultConfig())
+	useTemplates(t)
+	session := loginAs(t, "Mary")
+
+	r := httptest.NewRequest("GET", "/admin", nil)
+	r.AddCookie(&http.Cookie{Name: session.Name, Value: session.Value})
+	w := httptest.NewRecorder()
+	login(w, r)
+	if body := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(body, "Review queue (1)") || !strings.Contains(body, "1 article(s) are scheduled") {
+		t.Errorf("got status %d, want the dashboard with the article pending review and the scheduled one:\n%s", w.Code, body)
+	}
+}
+
+// TestExportWhilePublishing is run with -race to check that pages reading the articles do not race the publisher changing them.
+func TestExportWhilePublishing(t *testing.T) {
+	rows := append([][]interface{}{{"Scheduled article", "https://example.com/scheduled", "Media", "", "", "Mar 4, 2021", "", "", "", "scheduled", "Mary", "John", "Mar 4, 2021 08:00"}}, testArticleRows...)
+	useTestSheets(t, rows, db.DefaultConfig())
+	session := loginAs(t, "Mary")
+
+	var wg sync.WaitGroup
+	for _, path := range []string{"/export?format=csv", "/all"} {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			r := httptest.NewRequest("GET", path, nil)
+			r.AddCookie(&http.Cookie{Name: session.Name, Value: session.Value})
+			if path == "/all" {
+				s.visibleArticles(httptest.NewRecorder(), r).Len()
+				return
+			}
+			export(httptest.NewRecorder(), r)
+		}(path)
+	}
+	s.publishDue(time.Now())
+	wg.Wait()
+
+	if got := db.FilterByStatus(s.Articles, db.Published).Len(); got != 3 {
+		t.Errorf("got %d published articles, want the scheduled one published", got)
+	}
+}
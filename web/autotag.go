@@ -0,0 +1,121 @@
+// Package web contains the server, routing, and handlers logic.
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// articleTextClient fetches the text of articles for tag suggestions.
+var articleTextClient = &http.Client{Timeout: 15 * time.Second}
+
+// maxArticleTexts is the most article texts kept in s.Texts for tag suggestions.
+const maxArticleTexts = 5000
+
+// storeTexts adds the fetched texts of articles to s.Texts. Only the texts of articles in the database are kept, and none are added once maxArticleTexts are kept, after dropping the texts of articles that have since been deleted. s.mu must be held.
+func (s *Server) storeTexts(fetched map[string]db.Document) {
+	urls := make(map[string]bool, s.Articles.Len())
+	for _, a := range *s.Articles {
+		urls[a.URL] = true
+	}
+	if len(s.Texts)+len(fetched) > maxArticleTexts {
+		texts := make(map[string]db.Document, len(s.Texts))
+		for url, doc := range s.Texts {
+			if urls[url] {
+				texts[url] = doc
+			}
+		}
+		s.Texts = texts
+	}
+	for url, doc := range fetched {
+		if urls[url] && len(s.Texts) < maxArticleTexts {
+			s.Texts[url] = doc
+		}
+	}
+}
+
+// fetchArticleTexts fetches the text of every article in the background, so that tag suggestions are based on more than the articles' titles.
+func (s *Server) fetchArticleTexts() {
+	s.mu.Lock()
+	database := append(db.ArticlesDBByDate(nil), *s.Articles...)
+	have := make(map[string]bool, len(s.Texts))
+	for url := range s.Texts {
+		have[url] = true
+	}
+	s.mu.Unlock()
+
+	fetched := db.FetchArticleTexts(s.Ctx, &database, have, articleTextClient, 4)
+
+	s.mu.Lock()
+	s.storeTexts(fetched)
+	s.mu.Unlock()
+	log.Printf("Fetched the text of %d of %d article(s) for tag suggestions", len(fetched), len(database))
+}
+
+// tagClassifier returns the TagClassifier trained on the articles and their texts. It is only trained again once the articles or their texts have changed, as training it takes much longer than suggesting tags, and it is trained on a copy of them without s.mu held, so that the pages of the feed are not held up.
+func (s *Server) tagClassifier() *db.TagClassifier {
+	s.mu.Lock()
+	fp := db.TrainingFingerprint(s.Articles, s.Texts)
+	if s.classifier != nil && fp == s.classifierFingerprint {
+		defer s.mu.Unlock()
+		return s.classifier
+	}
+	database := append(db.ArticlesDBByDate(nil), *s.Articles...)
+	texts := make(map[string]db.Document, len(s.Texts))
+	for url, doc := range s.Texts {
+		texts[url] = doc
+	}
+	s.mu.Unlock()
+
+	classifier := db.TrainTagClassifier(&database, texts)
+
+	s.mu.Lock()
+	s.classifier, s.classifierFingerprint = classifier, fp
+	s.mu.Unlock()
+	return classifier
+}
+
+// autoTag writes, as JSON, the topics and past year questions suggested for the article with the url and title parameters, based on the tags of the existing articles most similar to it.
+func autoTag(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	url := strings.TrimSpace(r.FormValue("url"))
+	doc := db.NewDocument(r.FormValue("title"))
+
+	if url != "" {
+		s.mu.Lock()
+		text, ok := s.Texts[url]
+		s.mu.Unlock()
+
+		// a text that cannot be fetched is not stored, so that it is fetched again next time. Texts of articles that are not on the feed yet, such as the one being added, are not stored either, as anyone could post any URL.
+		if !ok {
+			t, err := db.FetchArticleText(r.Context(), articleTextClient, url)
+			if err != nil {
+				log.Printf("Unable to fetch the text of %s for tag suggestions - %v", url, err)
+			}
+			text = db.NewDocument(t)
+
+			if err == nil {
+				s.mu.Lock()
+				s.storeTexts(map[string]db.Document{url: text})
+				s.mu.Unlock()
+			}
+		}
+		doc.Add(text)
+	}
+
+	suggestions := s.tagClassifier().Suggest(doc, url, 8)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(suggestions); err != nil {
+		log.Printf("Unable to write tag suggestions - %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// useTestTexts empties the article texts and the classifier for the length of the test.
+func useTestTexts(t *testing.T) {
+	t.Helper()
+	oldTexts, oldClassifier, oldFingerprint := s.Texts, s.classifier, s.classifierFingerprint
+	s.Texts, s.classifier, s.classifierFingerprint = make(map[string]db.Document), nil, 0
+	t.Cleanup(func() { s.Texts, s.classifier, s.classifierFingerprint = oldTexts, oldClassifier, oldFingerprint })
+}
+
+func TestAutoTagStoresTexts(t *testing.T) {
+	pages := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "<html><body><p>Carbon emissions and climate policy.</p></body></html>")
+	}))
+	defer pages.Close()
+
+	useTestArticles(t, db.ArticlesDBByDate{
+		{Title: "Carbon tax to rise", URL: pages.URL + "/tax", Topics: []db.Topic{"Environment"}},
+		{Title: "Broken page", URL: pages.URL + "/broken", Topics: []db.Topic{"Economy"}},
+	}, db.DefaultConfig())
+	useTestTexts(t)
+	session := loginAs(t, "Mary")
+
+	for _, path := range []string{"/tax", "/broken", "/new"} {
+		if w := post(autoTag, "/autotag", url.Values{"url": {pages.URL + path}}, session); w.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d, want 200", path, w.Code)
+		}
+	}
+	if _, ok := s.Texts[pages.URL+"/tax"]; !ok || len(s.Texts) != 1 {
+		t.Errorf("got texts for %d URL(s), want only the article on the feed that could be fetched", len(s.Texts))
+	}
+	if s.classifier == nil {
+		t.Error("want the classifier trained and kept")
+	}
+}
+
+func TestStoreTextsLimit(t *testing.T) {
+	var articles db.ArticlesDBByDate
+	fetched := make(map[string]db.Document)
+	for i := 0; i < maxArticleTexts+10; i++ {
+		u := fmt.Sprintf("https://example.com/%d", i)
+		articles = append(articles, db.Article{URL: u})
+		fetched[u] = db.NewDocument("text")
+	}
+	useTestArticles(t, articles[:maxArticleTexts], db.DefaultConfig())
+	useTestTexts(t)
+
+	s.storeTexts(fetched)
+	if len(s.Texts) != maxArticleTexts {
+		t.Fatalf("got %d texts, want %d", len(s.Texts), maxArticleTexts)
+	}
+
+	// texts of deleted articles make room for new ones.
+	*s.Articles = append((*s.Articles)[1:], articles[maxArticleTexts])
+	s.storeTexts(map[string]db.Document{articles[maxArticleTexts].URL: db.NewDocument("text")})
+	if _, ok := s.Texts[articles[maxArticleTexts].URL]; !ok || len(s.Texts) != maxArticleTexts {
+		t.Errorf("got %d texts, want the deleted article's text dropped for the new one", len(s.Texts))
+	}
+}
@@ -0,0 +1,146 @@
+// Package web contains the server, routing, and handlers logic.
+package web
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// chart dimensions, in SVG user units. Charts are scaled to the width of their container.
+const (
+	chartWidth  = 600
+	chartHeight = 200
+	chartLeft   = 30
+	chartBottom = 20
+	chartTop    = 10
+)
+
+// chartColours are the colours of the lines of a line chart, in order.
+var chartColours = []string{"#e53935", "#1e88e5", "#43a047", "#fb8c00", "#8e24aa", "#00897b", "#6d4c41", "#546e7a"}
+
+// bucketLabel returns the label of a bucket on the x axis of a chart.
+func bucketLabel(period db.Period, b db.Bucket) string {
+	if period == db.Month {
+		return b.Start.Format("Jan 2006")
+	}
+	return b.Start.Format("2 Jan")
+}
+
+// maxCount returns the highest count in the series, and at least 1 so that empty charts can be drawn.
+func maxCount(series ...db.TimeSeries) int {
+	max := 1
+	for _, ts := range series {
+		for _, b := range ts.Buckets {
+			if b.Count > max {
+				max = b.Count
+			}
+		}
+	}
+	return max
+}
+
+// svgOpen starts an SVG chart with a y axis labelled from 0 to max, and an x axis labelled with the buckets of ts.
+func svgOpen(b *strings.Builder, title string, max int, ts db.TimeSeries) {
+	fmt.Fprintf(b, `<svg viewBox="0 0 %d %d" width="100%%" role="img" aria-label="%s" xmlns="http://www.w3.org/2000/svg" font-family="sans-serif" font-size="10">`, chartWidth, chartHeight, html.EscapeString(title))
+	fmt.Fprintf(b, `<title>%s</title>`, html.EscapeString(title))
+
+	bottom := chartHeight - chartBottom
+	fmt.Fprintf(b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#9e9e9e"/>`, chartLeft, bottom, chartWidth, bottom)
+	fmt.Fprintf(b, `<text x="%d" y="%d" text-anchor="end">%d</text>`, chartLeft-4, chartTop+4, max)
+	fmt.Fprintf(b, `<text x="%d" y="%d" text-anchor="end">0</text>`, chartLeft-4, bottom)
+
+	// label about six buckets, so that the labels do not overlap.
+	n := len(ts.Buckets)
+	step := (n + 5) / 6
+	for i := 0; i < n; i += step {
+		fmt.Fprintf(b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, bucketX(i, n), chartHeight-6, html.EscapeString(bucketLabel(ts.Period, ts.Buckets[i])))
+	}
+}
+
+// bucketX returns the x coordinate of the middle of the ith of n buckets.
+func bucketX(i, n int) float64 {
+	w := float64(chartWidth-chartLeft) / float64(n)
+	return chartLeft + w*(float64(i)+0.5)
+}
+
+// countY returns the y coordinate of count on a chart whose y axis goes up to max.
+func countY(count, max int) float64 {
+	h := float64(chartHeight - chartBottom - chartTop)
+	return float64(chartHeight-chartBottom) - h*float64(count)/float64(max)
+}
+
+// barChart draws ts as an SVG bar chart.
+func barChart(title string, ts db.TimeSeries) template.HTML {
+	var b strings.Builder
+	max := maxCount(ts)
+	svgOpen(&b, title, max, ts)
+
+	n := len(ts.Buckets)
+	w := float64(chartWidth-chartLeft) / float64(n)
+	for i, bucket := range ts.Buckets {
+		y := countY(bucket.Count, max)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#e53935"><title>%s: %d</title></rect>`,
+			bucketX(i, n)-w*0.4, y, w*0.8, float64(chartHeight-chartBottom)-y, html.EscapeString(bucketLabel(ts.Period, bucket)), bucket.Count)
+	}
+
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// lineChart draws each of series as a line of an SVG line chart, with a legend below it. The series must have the same buckets.
+func lineChart(title string, series []db.TimeSeries) template.HTML {
+	if len(series) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	max := maxCount(series...)
+	svgOpen(&b, title, max, series[0])
+
+	for i, ts := range series {
+		colour := chartColours[i%len(chartColours)]
+		points := make([]string, 0, len(ts.Buckets))
+		for j, bucket := range ts.Buckets {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", bucketX(j, len(ts.Buckets)), countY(bucket.Count, max)))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"><title>%s</title></polyline>`, strings.Join(points, " "), colour, html.EscapeString(ts.Name))
+	}
+	b.WriteString(`</svg>`)
+
+	b.WriteString(`<p>`)
+	for i, ts := range series {
+		fmt.Fprintf(&b, `<span style="color: %s;">&#9632;</span> %s (%d) &nbsp; `, chartColours[i%len(chartColours)], html.EscapeString(ts.Name), ts.Total())
+	}
+	b.WriteString(`</p>`)
+	return template.HTML(b.String())
+}
+
+// sourceChart draws the first limit sources as an SVG horizontal bar chart.
+func sourceChart(title string, sources []db.SourceCount, limit int) template.HTML {
+	if len(sources) > limit {
+		sources = sources[:limit]
+	}
+	if len(sources) == 0 {
+		return ""
+	}
+
+	const rowHeight, labelWidth = 20, 160
+	max := sources[0].Count
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %d %d" width="100%%" role="img" aria-label="%s" xmlns="http://www.w3.org/2000/svg" font-family="sans-serif" font-size="11">`, chartWidth, rowHeight*len(sources), html.EscapeString(title))
+	fmt.Fprintf(&b, `<title>%s</title>`, html.EscapeString(title))
+	for i, s := range sources {
+		y := i * rowHeight
+		w := float64(chartWidth-labelWidth-40) * float64(s.Count) / float64(max)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, labelWidth-6, y+14, html.EscapeString(s.Source))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="#e53935"/>`, labelWidth, y+3, w, rowHeight-6)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d">%d</text>`, float64(labelWidth)+w+4, y+14, s.Count)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
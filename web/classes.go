@@ -0,0 +1,278 @@
+// Package web contains the server, routing, and handlers logic.
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// classSummary is a class as listed on the classes page, with the reading assigned to it and how many students have finished each assignment.
+type classSummary struct {
+	db.Class
+	Assignments []assignmentSummary
+}
+
+// assignmentSummary is an assignment with how many of the students in its class have read all of its articles.
+type assignmentSummary struct {
+	db.Assignment
+	Complete int
+	Students int
+	Overdue  bool
+}
+
+// summarise returns the assignment with how many students in class have finished it at now. s.mu must be held.
+func summarise(a db.Assignment, class db.Class, now time.Time) assignmentSummary {
+	_, progress := a.Progress(class, s.Students, s.Articles)
+	sum := assignmentSummary{Assignment: a, Students: len(progress), Overdue: a.Overdue(now)}
+	for _, p := range progress {
+		if p.Complete() {
+			sum.Complete++
+		}
+	}
+	return sum
+}
+
+// classes is the page where teachers set up their classes with the email addresses of their students, and see the reading assigned to each class and how many students have finished it.
+func classes(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Redirect(w, r, "/admin", http.StatusUnauthorized)
+		return
+	}
+
+	if len(s.Config.StudentDomains) == 0 {
+		msg := customError{ErrMsg: "Student accounts are not available.", HelpMsg: "Set student_domains to the domains of the students' school email addresses to set up classes."}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+
+	if r.Method == "POST" {
+		name := r.FormValue("name")
+		if r.FormValue("action") == "delete" {
+			s.mu.Lock()
+			s.Classes = s.Classes.Remove(name)
+			s.Assignments = s.Assignments.WithoutClass(name)
+			queueSheetWrites(db.BackupClassesWrite(s.Classes), db.BackupAssignmentsWrite(s.Assignments))
+			s.mu.Unlock()
+			http.Redirect(w, r, "/classes", http.StatusSeeOther)
+			return
+		}
+
+		class, err := db.NewClass(name, r.FormValue("students"))
+		if err != nil {
+			msg := customError{ErrMsg: fmt.Sprintf("Unable to save the class - %v.", err), HelpMsg: fmt.Sprintf("Enter the students' school email addresses one per line, at most %d of them.", db.MaxClassSize)}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+		s.mu.Lock()
+		// renaming a class keeps the reading assigned to it.
+		if old := r.FormValue("old"); old != "" && !strings.EqualFold(old, class.Name) {
+			s.Classes = s.Classes.Remove(old)
+			for i := range s.Assignments {
+				if strings.EqualFold(s.Assignments[i].Class, old) {
+					s.Assignments[i].Class = class.Name
+				}
+			}
+		}
+		s.Classes = s.Classes.Set(class)
+		queueSheetWrites(db.BackupClassesWrite(s.Classes), db.BackupAssignmentsWrite(s.Assignments))
+		s.mu.Unlock()
+
+		http.Redirect(w, r, "/classes", http.StatusSeeOther)
+		return
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	data := struct {
+		Classes  []classSummary
+		Edit     db.Class
+		Domain   string
+		MaxClass int
+	}{
+		Domain:   strings.TrimPrefix(s.Config.StudentDomains[0], "@"),
+		MaxClass: db.MaxClassSize,
+	}
+	for _, c := range s.Classes {
+		sum := classSummary{Class: c}
+		for _, a := range s.Assignments.ForClasses(c.Name) {
+			sum.Assignments = append(sum.Assignments, summarise(a, c, now))
+		}
+		data.Classes = append(data.Classes, sum)
+	}
+	if c := s.Classes.Find(r.FormValue("edit")); c != nil {
+		data.Edit = *c
+	}
+	s.mu.Unlock()
+
+	err := tpl.ExecuteTemplate(w, "classes.html", data)
+	if err != nil {
+		msg := customError{
+			ErrMsg:  fmt.Sprintf("%v", err),
+			HelpMsg: "",
+		}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+}
+
+// assign is the form for assigning reading to a class. The articles are picked from the results of a search, which is saved with the assignment so that it can be run again for a later week, or pasted in by URL.
+func assign(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Redirect(w, r, "/admin", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == "POST" {
+		r.ParseForm()
+		urls := append(r.Form["url"], strings.Fields(r.FormValue("more"))...)
+
+		s.mu.Lock()
+		class := s.Classes.Find(r.FormValue("class"))
+		a, err := db.NewAssignment(r.FormValue("class"), r.FormValue("title"), r.FormValue("query"), urls, r.FormValue("due"), curator(r), time.Now())
+		if err == nil && class == nil {
+			err = fmt.Errorf("there is no class called %q", r.FormValue("class"))
+		}
+		if err == nil {
+			a.Class = class.Name
+			// only articles on the feed can be assigned, as students cannot see the others.
+			if n := db.ArticlesByURL(s.Articles, a.URLs).Len(); n != len(a.URLs) {
+				err = fmt.Errorf("%d of the articles are not on the feed", len(a.URLs)-n)
+			}
+		}
+		if err == nil {
+			s.Assignments = append(s.Assignments, a)
+			queueSheetWrites(db.BackupAssignmentsWrite(s.Assignments))
+		}
+		s.mu.Unlock()
+
+		if err != nil {
+			help := "Go back to the form and check the articles and due date."
+			if errors.Is(err, db.ErrInvalidAssignment) {
+				help = fmt.Sprintf("Give the assignment a title and a due date, and pick 1 to %d articles.", db.MaxAssignmentArticles)
+			}
+			msg := customError{ErrMsg: fmt.Sprintf("Unable to assign the reading - %v.", err), HelpMsg: help}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/assignment?id="+a.ID, http.StatusSeeOther)
+		return
+	}
+
+	term := strings.TrimSpace(r.FormValue("term"))
+	s.mu.Lock()
+	data := struct {
+		Classes     db.Classes
+		Class       string
+		Title       string
+		Due         string
+		Term        string
+		Results     *db.ArticlesDBByDate
+		MaxArticles int
+	}{
+		Classes:     s.Classes,
+		Class:       r.FormValue("class"),
+		Title:       r.FormValue("title"),
+		Due:         time.Now().AddDate(0, 0, 7).Format(db.DueDateFormat),
+		Term:        term,
+		Results:     db.NewArticlesDBByDate(),
+		MaxArticles: db.MaxAssignmentArticles,
+	}
+	if term != "" {
+		data.Results = db.Search(term, db.PublishedArticles(s.Articles))
+	}
+	s.mu.Unlock()
+	if data.Results.Len() > db.MaxAssignmentArticles {
+		*data.Results = (*data.Results)[:db.MaxAssignmentArticles]
+	}
+
+	err := tpl.ExecuteTemplate(w, "assign.html", data)
+	if err != nil {
+		msg := customError{
+			ErrMsg:  fmt.Sprintf("%v", err),
+			HelpMsg: "",
+		}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+}
+
+// assignment shows which articles of an assignment each student in the class has marked as read, or deletes the assignment.
+func assignment(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Redirect(w, r, "/admin", http.StatusUnauthorized)
+		return
+	}
+
+	id := r.FormValue("id")
+	if r.Method == "POST" {
+		s.mu.Lock()
+		s.Assignments = s.Assignments.Remove(id)
+		queueSheetWrites(db.BackupAssignmentsWrite(s.Assignments))
+		s.mu.Unlock()
+		http.Redirect(w, r, "/classes", http.StatusSeeOther)
+		return
+	}
+
+	s.mu.Lock()
+	a := s.Assignments.Find(id)
+	var class *db.Class
+	if a != nil {
+		class = s.Classes.Find(a.Class)
+	}
+	if a == nil || class == nil {
+		s.mu.Unlock()
+		msg := customError{ErrMsg: "Unable to find the assignment.", HelpMsg: "It may have been deleted. Go back to the classes and try again."}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+	articles, progress := a.Progress(*class, s.Students, s.Articles)
+	data := struct {
+		assignmentSummary
+		Articles *db.ArticlesDBByDate
+		Progress []db.Progress
+	}{
+		assignmentSummary: summarise(*a, *class, time.Now()),
+		Articles:          articles,
+		Progress:          progress,
+	}
+	s.mu.Unlock()
+
+	err := tpl.ExecuteTemplate(w, "assignment.html", data)
+	if err != nil {
+		msg := customError{
+			ErrMsg:  fmt.Sprintf("%v", err),
+			HelpMsg: "",
+		}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+}
+
+// studentAssignment is reading assigned to a class of a student, with whether they have read each article.
+type studentAssignment struct {
+	db.Assignment
+	Articles []studentItem
+	Done     int
+	Overdue  bool
+}
+
+// studentAssignments returns the reading assigned to the classes of the student a, soonest due first. s.mu must be held.
+func studentAssignments(a *db.Student, now time.Time) []studentAssignment {
+	var results []studentAssignment
+	for _, as := range s.Assignments.ForClasses(s.Classes.Of(a.Email)...) {
+		sa := studentAssignment{Assignment: as, Articles: studentItems(a, as.URLs), Overdue: as.Overdue(now)}
+		for _, item := range sa.Articles {
+			if item.Read {
+				sa.Done++
+			}
+		}
+		results = append(results, sa)
+	}
+	return results
+}
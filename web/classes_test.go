@@ -0,0 +1,148 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// useTestClasses gives the server student accounts at students.example.edu, with no classes or assignments, for the length of the test.
+func useTestClasses(t *testing.T) {
+	t.Helper()
+	initSessionKey("secret")
+	cfg := db.DefaultConfig()
+	cfg.StudentDomains = []string{"students.example.edu"}
+	cfg.PublicURL = "https://gp.example.com"
+	db.Configure(cfg)
+	t.Cleanup(func() { db.Configure(db.DefaultConfig()) })
+
+	useTestArticles(t, db.ArticlesDBByDate{
+		{Title: "Carbon tax to rise", URL: "https://example.com/tax"},
+		{Title: "Draft", URL: "https://example.com/draft", Status: db.Draft},
+	}, cfg)
+	useTestOutbox(t)
+	oldStudents, oldClasses, oldAssignments := s.Students, s.Classes, s.Assignments
+	s.Students, s.Classes, s.Assignments = make(db.Students), nil, nil
+	t.Cleanup(func() { s.Students, s.Classes, s.Assignments = oldStudents, oldClasses, oldAssignments })
+}
+
+// post sends the form to the handler with the cookie, and returns the response.
+func post(handler http.HandlerFunc, path string, form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != nil {
+		r.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// meAs returns the assignments the student API gives the student signed in with email.
+func meAs(t *testing.T, email string) []assignmentJSON {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/api/me", nil)
+	r.AddCookie(&http.Cookie{Name: "studentSession", Value: signToken("session", email, time.Now().Add(time.Hour))})
+	w := httptest.NewRecorder()
+	studentAPI(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d from the student API for %s, want 200", w.Code, email)
+	}
+	var v studentJSON
+	if err := json.NewDecoder(w.Body).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v.Assignments
+}
+
+func TestClassesNeedCurator(t *testing.T) {
+	useTestClasses(t)
+	forged := &http.Cookie{Name: "sessionID", Value: "anything"}
+	class := url.Values{"name": {"Civics 1"}, "students": {"ann@students.example.edu"}}
+	reading := url.Values{"class": {"Civics 1"}, "title": {"Week 1"}, "url": {"https://example.com/tax"}, "due": {"2031-03-08"}}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		path    string
+		form    url.Values
+	}{
+		{"classes", classes, "/classes", class},
+		{"assign", assign, "/assign", reading},
+		{"assignment", assignment, "/assignment", url.Values{"id": {"1"}}},
+	}
+	for _, tt := range tests {
+		for _, cookie := range []*http.Cookie{nil, forged} {
+			w := post(tt.handler, tt.path, tt.form, cookie)
+			if w.Code != http.StatusUnauthorized || w.Header().Get("Location") != "/admin" {
+				t.Errorf("%s with cookie %v: got %d to %q, want 401 to /admin", tt.name, cookie, w.Code, w.Header().Get("Location"))
+			}
+		}
+	}
+	if len(s.Classes) != 0 || len(s.Assignments) != 0 || len(s.Outbox.Pending()) != 0 {
+		t.Errorf("got classes %v and assignments %v, want nothing saved without a session", s.Classes, s.Assignments)
+	}
+}
+
+func TestAssignReading(t *testing.T) {
+	useTestClasses(t)
+	session := loginAs(t, "Mary")
+
+	w := post(classes, "/classes", url.Values{"name": {"Civics 1"}, "students": {"ann@students.example.edu, bob@example.com"}}, session)
+	if got := w.Header().Get("Location"); !strings.HasPrefix(got, "/error") || len(s.Classes) != 0 {
+		t.Fatalf("got redirected to %q with classes %v, want a class with an address outside the student domains refused", got, s.Classes)
+	}
+	w = post(classes, "/classes", url.Values{"name": {"Civics 1"}, "students": {"Ann@students.example.edu\ncal@students.example.edu"}}, session)
+	if got := w.Header().Get("Location"); got != "/classes" || len(s.Classes) != 1 || !s.Classes[0].Has("ann@students.example.edu") {
+		t.Fatalf("got redirected to %q with classes %v, want the class saved", got, s.Classes)
+	}
+
+	refused := []struct {
+		name string
+		form url.Values
+	}{
+		{"unknown class", url.Values{"class": {"Civics 2"}, "title": {"Week 1"}, "url": {"https://example.com/tax"}, "due": {"2031-03-08"}}},
+		{"article not on the feed", url.Values{"class": {"Civics 1"}, "title": {"Week 1"}, "more": {"https://example.com/tax https://example.com/elsewhere"}, "due": {"2031-03-08"}}},
+		{"no due date", url.Values{"class": {"Civics 1"}, "title": {"Week 1"}, "url": {"https://example.com/tax"}}},
+	}
+	for _, tt := range refused {
+		if got := post(assign, "/assign", tt.form, session).Header().Get("Location"); !strings.HasPrefix(got, "/error") {
+			t.Errorf("%s: got redirected to %q, want the error page", tt.name, got)
+		}
+	}
+	if len(s.Assignments) != 0 {
+		t.Fatalf("got assignments %v, want none saved", s.Assignments)
+	}
+
+	w = post(assign, "/assign", url.Values{"class": {"civics 1"}, "title": {"Week 1"}, "url": {"https://example.com/tax"}, "due": {"2031-03-08"}}, session)
+	if len(s.Assignments) != 1 {
+		t.Fatalf("got assignments %v, want the reading assigned", s.Assignments)
+	}
+	a := s.Assignments[0]
+	if got := w.Header().Get("Location"); got != "/assignment?id="+a.ID || a.Class != "Civics 1" || a.SetBy != "Mary" {
+		t.Errorf("got redirected to %q with assignment %+v, want it set by Mary for Civics 1", got, a)
+	}
+
+	// students see the reading assigned to their own classes only.
+	if got := meAs(t, "ann@students.example.edu"); len(got) != 1 || got[0].Title != "Week 1" || len(got[0].Articles) != 1 || got[0].Due != "2031-03-08" {
+		t.Errorf("got assignments %+v for a student in the class, want Week 1", got)
+	}
+	if got := meAs(t, "dan@students.example.edu"); len(got) != 0 {
+		t.Errorf("got assignments %+v for a student in no class, want none", got)
+	}
+
+	// deleting the class deletes the reading assigned to it.
+	post(classes, "/classes", url.Values{"action": {"delete"}, "name": {"Civics 1"}}, session)
+	if len(s.Classes) != 0 || len(s.Assignments) != 0 {
+		t.Errorf("got classes %v and assignments %v after deleting the class, want none", s.Classes, s.Assignments)
+	}
+	if got := meAs(t, "ann@students.example.edu"); len(got) != 0 {
+		t.Errorf("got assignments %+v after the class was deleted, want none", got)
+	}
+}
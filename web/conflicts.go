@@ -0,0 +1,53 @@
+// Package web contains the server, routing, and handlers logic.
+package web
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// conflicts lists articles edited differently in the Articles sheet and in the app, and sheet edits that could not be applied, and lets an admin choose which version of a conflicting article to keep.
+func conflicts(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Redirect(w, r, "/admin", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == "POST" {
+		keepSheet := r.FormValue("keep") == "sheet"
+
+		s.takeSnapshot(db.SnapshotBeforeResolve)
+		s.mu.Lock()
+		err := s.Sync.Resolve(r.FormValue("url"), keepSheet, s.Articles, s.Questions, s.Topics, s.QuestionCounter)
+		s.mu.Unlock()
+		if err != nil {
+			msg := customError{ErrMsg: fmt.Sprintf("Unable to resolve the conflict - %v", err), HelpMsg: "The sheet's version may not be valid. Correct the row in the Articles sheet, or keep the app's version instead."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+
+		backup(w, r)
+		http.Redirect(w, r, "/conflicts", http.StatusSeeOther)
+		return
+	}
+
+	data := struct {
+		Conflicts []db.Conflict
+		Rejected  map[string]string
+	}{
+		Conflicts: s.Sync.Conflicts(),
+		Rejected:  s.Sync.Rejected(),
+	}
+
+	err := tpl.ExecuteTemplate(w, "conflicts.html", data)
+	if err != nil {
+		msg := customError{
+			ErrMsg:  fmt.Sprintf("%v", err),
+			HelpMsg: "",
+		}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+}
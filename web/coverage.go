@@ -0,0 +1,58 @@
+// Package web contains the server, routing, and handlers logic.
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// coverage shows the coverage report of every past year question and topic, optionally filtered by kind and to the stale ones only, or downloads it as CSV with format=csv. The days parameter overrides the stale_days setting.
+func coverage(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Redirect(w, r, "/admin", http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	days := s.Config.StaleDays
+	if d, err := strconv.Atoi(q.Get("days")); err == nil && d > 0 {
+		days = d
+	}
+
+	s.mu.Lock()
+	report := db.NewCoverageReport(s.Articles, s.Questions, s.TopicTree, time.Now(), days)
+	s.mu.Unlock()
+	staleQuestions, staleTopics := report.Stale()
+	report = report.Filter(q.Get("kind"), q.Get("stale") != "")
+
+	if q.Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="coverage.csv"`)
+		if err := report.WriteCSV(w); err != nil {
+			fmt.Printf("Unable to export coverage report - %v\n", err)
+		}
+		return
+	}
+
+	data := struct {
+		*db.CoverageReport
+		Kind           string
+		StaleOnly      bool
+		StaleQuestions int
+		StaleTopics    int
+	}{report, q.Get("kind"), q.Get("stale") != "", staleQuestions, staleTopics}
+
+	err := tpl.ExecuteTemplate(w, "coverage.html", data)
+	if err != nil {
+		msg := customError{
+			ErrMsg:  fmt.Sprintf("%v", err),
+			HelpMsg: "",
+		}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+}
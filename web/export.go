@@ -0,0 +1,134 @@
+// Package web contains the server, routing, and handlers logic.
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+func export(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Redirect(w, r, "/admin", http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	format := q.Get("format")
+
+	// without a format, show the export form.
+	if format == "" {
+		err := tpl.ExecuteTemplate(w, "export.html", nil)
+		if err != nil {
+			msg := customError{
+				ErrMsg:  fmt.Sprintf("%v", err),
+				HelpMsg: "",
+			}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		}
+		return
+	}
+
+	// exporting a topic includes the articles tagged with its subtopics, as searching it does.
+	articles := s.copyArticles()
+	s.mu.Lock()
+	results, err := db.FilterArticles(articles, s.TopicTree, q.Get("term"), q.Get("topic"), q.Get("question"))
+	s.mu.Unlock()
+	if err != nil {
+		msg := customError{ErrMsg: fmt.Sprintf("Unable to filter articles - %v", err), HelpMsg: "Check if the question has been formatted correctly, in the form '2020-Q10', '2019-P2-Q5' or 'NJC-Prelim-2022-Q3'."}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="articles.csv"`)
+		err = db.ExportCSV(w, results)
+	case "json":
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="articles.json"`)
+		err = db.ExportJSON(w, results)
+	case "md":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="reading-list.md"`)
+		err = db.ExportMarkdown(w, results, exportHeading(q.Get("term"), q.Get("topic"), q.Get("question")))
+	default:
+		msg := customError{ErrMsg: fmt.Sprintf("Unknown export format %v.", format), HelpMsg: "Choose CSV, JSON or Markdown."}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+
+	if err != nil {
+		fmt.Printf("Unable to export articles - %v\n", err)
+	}
+}
+
+// exportHeading describes the filters applied to an export, for use as the title of a reading list.
+func exportHeading(term, topic, question string) string {
+	filters := make([]string, 0, 3)
+	if term = strings.TrimSpace(term); term != "" {
+		filters = append(filters, fmt.Sprintf("%q", term))
+	}
+	if topic = strings.TrimSpace(topic); topic != "" {
+		filters = append(filters, "#"+topic)
+	}
+	if question = strings.TrimSpace(question); question != "" {
+		filters = append(filters, question)
+	}
+
+	if len(filters) == 0 {
+		return "NJC GP News Feed reading list"
+	}
+	return "NJC GP News Feed reading list: " + strings.Join(filters, ", ")
+}
+
+func readingPack(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Redirect(w, r, "/admin", http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+
+	// reading packs are handed out to students, so only published articles are included.
+	s.mu.Lock()
+	published := db.PublishedArticles(s.Articles)
+	s.mu.Unlock()
+
+	var pack *db.ReadingPack
+	var err error
+	switch {
+	case q.Get("question") != "":
+		s.mu.Lock()
+		pack, err = db.NewQuestionReadingPack(published, s.Questions, q.Get("question"))
+		s.mu.Unlock()
+	case q.Get("topic") != "":
+		s.mu.Lock()
+		pack, err = db.NewTopicReadingPack(published, s.TopicTree, q.Get("topic"))
+		s.mu.Unlock()
+	default:
+		msg := customError{ErrMsg: "No question or topic was selected for the reading pack.", HelpMsg: "Enter a past year question (e.g. 2019-Q6) or a topic."}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		msg := customError{ErrMsg: fmt.Sprintf("Unable to create reading pack - %v", err), HelpMsg: "Check if the question has been formatted correctly, in the form '2020-Q10', and that it exists in the database."}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+
+	if pack.Articles.Len() == 0 {
+		msg := customError{ErrMsg: fmt.Sprintf("There are no articles tagged with %v.", pack.Title), HelpMsg: "Try a different question or topic."}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="reading-pack.pdf"`)
+	if err := pack.WritePDF(w); err != nil {
+		fmt.Printf("Unable to write reading pack - %v\n", err)
+	}
+}
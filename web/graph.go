@@ -0,0 +1,217 @@
+// Package web contains the server, routing, and handlers logic.
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// graph dimensions, in SVG user units.
+const (
+	graphWidth  = 800
+	graphHeight = 560
+	graphMargin = 60
+)
+
+// maxGraphNodes is the most nodes the topic graph is drawn with, however many are asked for, as the time taken to lay it out grows with the square of the number of nodes.
+const maxGraphNodes = 100
+
+// graphOptions returns the options of the topic graph in the questions, min, max and focus parameters of r. By default only topics are shown, with the 60 most tagged topics, and every pair tagged together is linked. At most maxGraphNodes nodes are shown.
+func graphOptions(r *http.Request) db.GraphOptions {
+	opts := db.GraphOptions{
+		Questions: r.FormValue("questions") != "",
+		MinWeight: 1,
+		MaxNodes:  60,
+		Focus:     strings.TrimSpace(r.FormValue("focus")),
+	}
+	if n, err := strconv.Atoi(r.FormValue("min")); err == nil && n > 0 {
+		opts.MinWeight = n
+	}
+	if n, err := strconv.Atoi(r.FormValue("max")); err == nil && n > 0 {
+		opts.MaxNodes = n
+	}
+	if opts.MaxNodes > maxGraphNodes {
+		opts.MaxNodes = maxGraphNodes
+	}
+	// a topic or question is shown with as much as can be drawn of what is linked to it, however rarely it is tagged.
+	if opts.Focus != "" {
+		opts.MaxNodes = maxGraphNodes
+		opts.Questions = opts.Questions || db.IsQuestionTag(opts.Focus)
+	}
+	return opts
+}
+
+// topicGraph shows how topics, and optionally past year questions, are connected by the articles tagged with them, as a graph. Clicking a topic or question shows only what is linked to it.
+func topicGraph(w http.ResponseWriter, r *http.Request) {
+	opts := graphOptions(r)
+	articles := s.visibleArticles(w, r)
+
+	s.mu.Lock()
+	g := db.NewTopicGraph(articles, opts)
+	s.mu.Unlock()
+
+	data := struct {
+		Options  db.GraphOptions
+		Graph    template.HTML
+		Clusters [][]string
+		Nodes    int
+		Search   string
+	}{
+		Options:  opts,
+		Graph:    graphSVG(g, opts),
+		Clusters: g.Clusters(),
+		Nodes:    len(g.Nodes),
+	}
+	if opts.Focus != "" {
+		data.Search = "/search?topic=" + url.QueryEscape(opts.Focus)
+		if db.IsQuestionTag(opts.Focus) {
+			data.Search = "/search?term=" + url.QueryEscape(opts.Focus)
+		}
+	}
+
+	err := tpl.ExecuteTemplate(w, "graph.html", data)
+	if err != nil {
+		msg := customError{
+			ErrMsg:  fmt.Sprintf("%v", err),
+			HelpMsg: "",
+		}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+}
+
+// topicGraphAPI writes the topic graph as JSON, with the same parameters as the graph page. Its edges are the co-occurrence matrix of the topics and questions, listing every pair tagged on the same articles with the number of such articles.
+func topicGraphAPI(w http.ResponseWriter, r *http.Request) {
+	articles := s.visibleArticles(w, r)
+
+	s.mu.Lock()
+	g := db.NewTopicGraph(articles, graphOptions(r))
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(g); err != nil {
+		log.Printf("Unable to write the topic graph - %v", err)
+	}
+}
+
+// point is the position of a node in the graph layout.
+type point struct{ x, y float64 }
+
+// layoutGraph places the nodes of g with a force-directed layout, in which linked nodes pull each other closer and every node pushes the others away, so that clusters of related topics are drawn together. Nodes start around a circle in cluster order, so the layout is the same every time for the same graph.
+func layoutGraph(g *db.TopicGraph) []point {
+	n := len(g.Nodes)
+	pos := make([]point, n)
+	if n == 0 {
+		return pos
+	}
+
+	index := make(map[string]int, n)
+	order := make([]int, 0, n)
+	for cluster := 0; len(order) < n; cluster++ {
+		for i, node := range g.Nodes {
+			if node.Cluster == cluster {
+				order = append(order, i)
+			}
+		}
+	}
+	for k, i := range order {
+		index[g.Nodes[i].ID] = i
+		angle := 2 * math.Pi * float64(k) / float64(n)
+		pos[i] = point{graphWidth/2 + graphWidth/3*math.Cos(angle), graphHeight/2 + graphHeight/3*math.Sin(angle)}
+	}
+
+	w, h := float64(graphWidth-2*graphMargin), float64(graphHeight-2*graphMargin)
+	k := math.Sqrt(w * h / float64(n))
+	temperature := w / 10
+	for iter := 0; iter < 300; iter++ {
+		disp := make([]point, n)
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				dx, dy := pos[i].x-pos[j].x, pos[i].y-pos[j].y
+				d := math.Max(math.Hypot(dx, dy), 0.01)
+				f := k * k / d
+				disp[i].x += dx / d * f
+				disp[i].y += dy / d * f
+				disp[j].x -= dx / d * f
+				disp[j].y -= dy / d * f
+			}
+		}
+		for _, e := range g.Edges {
+			i, j := index[e.Source], index[e.Target]
+			dx, dy := pos[i].x-pos[j].x, pos[i].y-pos[j].y
+			d := math.Max(math.Hypot(dx, dy), 0.01)
+			f := d * d / k * (1 + math.Log(float64(e.Weight)))
+			disp[i].x -= dx / d * f
+			disp[i].y -= dy / d * f
+			disp[j].x += dx / d * f
+			disp[j].y += dy / d * f
+		}
+
+		for i := range pos {
+			d := math.Max(math.Hypot(disp[i].x, disp[i].y), 0.01)
+			step := math.Min(d, temperature)
+			pos[i].x += disp[i].x / d * step
+			pos[i].y += disp[i].y / d * step
+			pos[i].x = math.Min(math.Max(pos[i].x, graphMargin), graphWidth-graphMargin)
+			pos[i].y = math.Min(math.Max(pos[i].y, graphMargin), graphHeight-graphMargin)
+		}
+		temperature *= 0.98
+	}
+	return pos
+}
+
+// graphSVG draws g as an SVG graph. Nodes are sized by the number of articles tagged with them and coloured by cluster, and link to the graph focused on them.
+func graphSVG(g *db.TopicGraph, opts db.GraphOptions) template.HTML {
+	if len(g.Nodes) == 0 {
+		return ""
+	}
+	pos := layoutGraph(g)
+	index := make(map[string]int, len(g.Nodes))
+	for i, n := range g.Nodes {
+		index[n.ID] = i
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg id="topic-graph" viewBox="0 0 %d %d" width="100%%" role="img" aria-label="Graph of how topics are connected" xmlns="http://www.w3.org/2000/svg" font-family="sans-serif" font-size="11">`, graphWidth, graphHeight)
+
+	for _, e := range g.Edges {
+		s, t := pos[index[e.Source]], pos[index[e.Target]]
+		fmt.Fprintf(&b, `<line class="edge" data-source="%s" data-target="%s" x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#9e9e9e" stroke-opacity="0.6" stroke-width="%.1f"><title>%s and %s: %d article(s)</title></line>`,
+			html.EscapeString(e.Source), html.EscapeString(e.Target), s.x, s.y, t.x, t.y, 1+math.Log(float64(e.Weight))*1.5,
+			html.EscapeString(g.Nodes[index[e.Source]].Label), html.EscapeString(g.Nodes[index[e.Target]].Label), e.Weight)
+	}
+
+	for i, n := range g.Nodes {
+		p := pos[i]
+		colour := chartColours[n.Cluster%len(chartColours)]
+		radius := 4 + 2*math.Sqrt(float64(n.Articles))
+		shape := fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s"/>`, p.x, p.y, radius, colour)
+		if n.Kind == db.CoverageQuestion {
+			shape = fmt.Sprintf(`<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`, p.x-radius, p.y-radius, 2*radius, 2*radius, colour)
+		}
+		stroke := ""
+		if strings.EqualFold(n.ID, opts.Focus) {
+			stroke = ` stroke="#212121" stroke-width="2"`
+		}
+
+		q := url.Values{"focus": {n.ID}}
+		if opts.Questions {
+			q.Set("questions", "1")
+		}
+		fmt.Fprintf(&b, `<a href="/graph?%s"><g class="node" data-id="%s"%s>%s<text x="%.1f" y="%.1f" text-anchor="middle" fill="#212121">%s</text><title>%s: %d article(s)</title></g></a>`,
+			html.EscapeString(q.Encode()), html.EscapeString(n.ID), stroke, shape, p.x, p.y-radius-3, html.EscapeString(n.Label), html.EscapeString(n.Label), n.Articles)
+	}
+
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
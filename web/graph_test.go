@@ -0,0 +1,24 @@
+package web
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGraphOptionsLimitNodes(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 60},
+		{"?max=20", 20},
+		{"?max=100000", maxGraphNodes},
+		{"?focus=Technology", maxGraphNodes},
+		{"?focus=Technology&max=100000", maxGraphNodes},
+	}
+	for _, tt := range tests {
+		if got := graphOptions(httptest.NewRequest("GET", "/graph"+tt.query, nil)).MaxNodes; got != tt.want {
+			t.Errorf("%q: got %d nodes, want %d", tt.query, got, tt.want)
+		}
+	}
+}
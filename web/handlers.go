@@ -4,6 +4,7 @@ package web
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/jwnpoh/njcgpnewsfeed/db"
 )
@@ -13,20 +14,47 @@ func (s *Server) router() {
 	http.HandleFunc("/latest", latest)
 	http.HandleFunc("/all", all)
 	http.HandleFunc("/search", search)
+	http.HandleFunc("/graph", topicGraph)
+	http.HandleFunc("/api/graph", topicGraphAPI)
+	http.HandleFunc("/api/me", studentAPI)
+	http.HandleFunc("/api/me/", studentAPI)
+	http.HandleFunc("/account", account)
+	http.HandleFunc("/account/signin", accountSignIn)
+	http.HandleFunc("/account/signout", accountSignOut)
 	http.HandleFunc("/admin", admin)
 	http.HandleFunc("/form", form)
 	http.HandleFunc("/delete", delete)
 	http.HandleFunc("/edit", edit)
 	http.HandleFunc("/editArticle", editArticle)
 	http.HandleFunc("/add", addQuestion)
+	http.HandleFunc("/questions", questions)
 	http.HandleFunc("/backup", backup)
+	http.HandleFunc("/sync", syncNow)
+	http.HandleFunc("/conflicts", conflicts)
+	http.HandleFunc("/snapshots", snapshots)
+	http.HandleFunc("/topics", topics)
+	http.HandleFunc("/coverage", coverage)
+	http.HandleFunc("/sources", newsSources)
+	http.HandleFunc("/review", review)
+	http.HandleFunc("/suggestArticle", suggestArticle)
+	http.HandleFunc("/suggestions", suggestions)
+	http.HandleFunc("/classes", classes)
+	http.HandleFunc("/assign", assign)
+	http.HandleFunc("/assignment", assignment)
+	http.HandleFunc("/import", importArticles)
+	http.HandleFunc("/export", export)
+	http.HandleFunc("/readingpack", readingPack)
 	http.HandleFunc("/getTitle", getTitle)
+	http.HandleFunc("/suggest", suggestTags)
+	http.HandleFunc("/autotag", autoTag)
 	http.HandleFunc("/error", errorPage)
 }
 
 func index(w http.ResponseWriter, r *http.Request) {
-	data := *s.Articles
-	data = data[0:12]
+	data := *s.visibleArticles(w, r)
+	if len(data) > 12 {
+		data = data[0:12]
+	}
 
 	err := tpl.ExecuteTemplate(w, "index.html", data)
 	if err != nil {
@@ -40,8 +68,10 @@ func index(w http.ResponseWriter, r *http.Request) {
 }
 
 func latest(w http.ResponseWriter, r *http.Request) {
-	data := *s.Articles
-	data = data[0:15]
+	data := *s.visibleArticles(w, r)
+	if len(data) > 15 {
+		data = data[0:15]
+	}
 
 	err := tpl.ExecuteTemplate(w, "latest.html", data)
 	if err != nil {
@@ -55,7 +85,7 @@ func latest(w http.ResponseWriter, r *http.Request) {
 }
 
 func all(w http.ResponseWriter, r *http.Request) {
-	data := *s.Articles
+	data := *s.visibleArticles(w, r)
 
 	err := tpl.ExecuteTemplate(w, "all.html", data)
 	if err != nil {
@@ -70,20 +100,60 @@ func all(w http.ResponseWriter, r *http.Request) {
 
 func search(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
-	results := db.Search(q.Get("term"), s.Articles)
+	term := q.Get("term")
+	articles := s.visibleArticles(w, r)
+	results := db.Search(term, articles)
+
+	// searching by topic includes the articles tagged with its subtopics.
+	if topic := q.Get("topic"); topic != "" {
+		term = topic
+		s.mu.Lock()
+		results = db.SearchTopic(db.Topic(topic), s.TopicTree, articles)
+		s.mu.Unlock()
+	}
+
+	// searching by publisher lists its articles, narrowed down to those matching the term if there is one.
+	if publisher := strings.TrimSpace(q.Get("publisher")); publisher != "" {
+		if len(term) == 0 {
+			term, results = publisher, articles
+		}
+		s.mu.Lock()
+		results = db.FilterBySource(results, publisher)
+		s.mu.Unlock()
+	}
 
-	if len(q.Get("term")) == 0 || len(*results) == 0 {
+	// the question filter narrows the results down to articles tagged with questions of a paper, source, exam level or theme.
+	filter := newQuestionFilter(r)
+	if !filter.IsZero() {
+		if len(term) == 0 {
+			term, results = filter.String(), articles
+		}
+		results = db.FilterByQuestion(results, filter)
+	}
+
+	if len(term) == 0 || len(*results) == 0 {
 		msg := customError{ErrMsg: "Nothing matched the search term.", HelpMsg: "Try refining your search term, or try a different search term."}
 		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
 		return
 	}
 
+	s.mu.Lock()
+	facets := db.NewQuestionFacets(s.Questions)
+	s.mu.Unlock()
+
 	data := struct {
 		Term     string
 		Articles *db.ArticlesDBByDate
+		Filter   questionFilterForm
 	}{
-		Term:     q.Get("term"),
+		Term:     term,
 		Articles: results,
+		Filter: questionFilterForm{
+			Action: "/search",
+			Hidden: map[string]string{"term": q.Get("term"), "topic": q.Get("topic"), "publisher": q.Get("publisher")},
+			Filter: filter,
+			Facets: facets,
+		},
 	}
 
 	err := tpl.ExecuteTemplate(w, "search.html", data)
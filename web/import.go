@@ -0,0 +1,81 @@
+// Package web contains the server, routing, and handlers logic.
+package web
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+func importArticles(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Redirect(w, r, "/admin", http.StatusUnauthorized)
+		return
+	}
+
+	data := struct {
+		Format    string
+		Data      string
+		Report    *db.ImportReport
+		Committed int
+	}{
+		Format: db.FormatCSV,
+	}
+
+	if r.Method == "POST" {
+		r.ParseMultipartForm(10 << 20)
+		data.Format = r.FormValue("format")
+		data.Data = r.FormValue("data")
+
+		// an uploaded file takes precedence over pasted data.
+		if f, _, err := r.FormFile("file"); err == nil {
+			b, err := ioutil.ReadAll(f)
+			f.Close()
+			if err != nil {
+				msg := customError{ErrMsg: "Unable to read the uploaded file.", HelpMsg: "Please try again, or paste the data into the text box instead."}
+				http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+				return
+			}
+			data.Data = string(b)
+		}
+
+		s.mu.Lock()
+		report, err := db.ImportArticles(strings.NewReader(data.Data), data.Format, s.Articles, s.Questions)
+		s.mu.Unlock()
+		if err != nil {
+			msg := customError{ErrMsg: fmt.Sprintf("Unable to read the import data - %v", err), HelpMsg: "Check that the data is in the same column layout as the Articles sheet, and that the correct format has been selected."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+		if s.Config.ControlledVocabulary {
+			report.CheckVocabulary(s.vocabulary())
+		}
+		// imported articles go through the review workflow as if they had been added with the add article form.
+		report.Submit(curator(r), s.Config.RequireReview, time.Now())
+		data.Report = report
+
+		if r.FormValue("action") == "commit" {
+			s.takeSnapshot(db.SnapshotBeforeImport)
+			s.mu.Lock()
+			data.Committed = report.Commit(s.Articles, s.Topics, s.QuestionCounter)
+			s.mu.Unlock()
+			if data.Committed > 0 {
+				backup(w, r)
+			}
+		}
+	}
+
+	err := tpl.ExecuteTemplate(w, "import.html", data)
+	if err != nil {
+		msg := customError{
+			ErrMsg:  fmt.Sprintf("%v", err),
+			HelpMsg: "",
+		}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+func TestImportNeedsReview(t *testing.T) {
+	cfg := db.DefaultConfig()
+	cfg.RequireReview = true
+	useTestSheets(t, testArticleRows, cfg)
+	useTemplates(t)
+	session := loginAs(t, "Mary")
+
+	csv := `Title,URL,Topics,Questions,Wording,Date,Summary,Note,Prompts,Status,Added by,Reviewed by,Publish at
+Censorship online,https://example.com/censor,Media,,,"Mar 4, 2021"
+Scheduled satire,https://example.com/satire,Media,,,"Mar 5, 2021",,,,scheduled,John,Ann,"Mar 7, 2031 07:30"
+`
+	w := post(importArticles, "/import", url.Values{"format": {db.FormatCSV}, "data": {csv}, "action": {"commit"}}, session)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want the import report", w.Code)
+	}
+
+	statuses := make(map[string]db.Article)
+	for _, a := range *s.Articles {
+		statuses[a.URL] = a
+	}
+	if a := statuses["https://example.com/censor"]; a.Status != db.Pending || a.AddedBy != "Mary" {
+		t.Errorf("got status %q added by %q, want a row with no status pending review and added by Mary", a.Status, a.AddedBy)
+	}
+	if a := statuses["https://example.com/satire"]; a.Status != db.Pending || a.AddedBy != "John" || a.ReviewedBy != "" {
+		t.Errorf("got status %q added by %q and reviewed by %q, want a scheduled row pending review and added by John", a.Status, a.AddedBy, a.ReviewedBy)
+	}
+
+	// students see neither article on the front page.
+	home := httptest.NewRecorder()
+	index(home, httptest.NewRequest("GET", "/", nil))
+	if body := home.Body.String(); !strings.Contains(body, "Budget boosts healthcare") || strings.Contains(body, "Censorship online") || strings.Contains(body, "Scheduled satire") {
+		t.Errorf("got front page without the published article or with an imported one:\n%s", body)
+	}
+}
+
+func TestImportSchedules(t *testing.T) {
+	useTestSheets(t, testArticleRows, db.DefaultConfig())
+	useTemplates(t)
+	session := loginAs(t, "Mary")
+
+	data := `[{"title": "Censorship online", "url": "https://example.com/censor", "topics": ["Media"], "date": "Mar 4, 2021", "publish_at": "Mar 7, 2031 07:30"},
+{"title": "Satire", "url": "https://example.com/satire", "topics": ["Media"], "date": "Mar 5, 2021", "publish_at": "Mar 7, 2020 07:30"}]`
+	post(importArticles, "/import", url.Values{"format": {db.FormatJSON}, "data": {data}, "action": {"commit"}}, session)
+
+	published := db.PublishedArticles(s.Articles)
+	if published.Len() != 3 || db.FilterByStatus(s.Articles, db.Scheduled).Len() != 1 {
+		t.Errorf("got %d published and %d scheduled articles, want the article with a publish time to come scheduled", published.Len(), db.FilterByStatus(s.Articles, db.Scheduled).Len())
+	}
+	for _, a := range *published {
+		if a.PublishAt != 0 {
+			t.Errorf("got published article %q with publish time %d, want it cleared", a.Title, a.PublishAt)
+		}
+	}
+}
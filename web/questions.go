@@ -0,0 +1,160 @@
+// Package web contains the server, routing, and handlers logic.
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// questionEntry is a row of the question bank.
+type questionEntry struct {
+	db.Question
+	Articles int
+}
+
+// questionFilterForm is the data of the questionfilter template, which filters the page at Action by question paper, source, exam level and theme. Hidden holds the other query parameters of the page, such as the search term.
+type questionFilterForm struct {
+	Action string
+	Hidden map[string]string
+	Filter db.QuestionFilter
+	Facets db.QuestionFacets
+}
+
+// newQuestionFilter returns the question filter in the paper, source, level and theme parameters of r.
+func newQuestionFilter(r *http.Request) db.QuestionFilter {
+	return db.QuestionFilter{
+		Paper:  strings.TrimSpace(r.FormValue("paper")),
+		Source: strings.TrimSpace(r.FormValue("source")),
+		Level:  strings.TrimSpace(r.FormValue("level")),
+		Theme:  strings.TrimSpace(r.FormValue("theme")),
+	}
+}
+
+// questionFromForm returns the question in the year, number, paper, source, level, theme and wording fields of a question form.
+func questionFromForm(r *http.Request) db.Question {
+	return db.Question{
+		Year:    strings.TrimSpace(r.FormValue("year")),
+		Number:  strings.TrimSpace(r.FormValue("number")),
+		Wording: strings.TrimSpace(r.FormValue("wording")),
+		Paper:   strings.TrimSpace(r.FormValue("paper")),
+		Source:  strings.TrimSpace(r.FormValue("source")),
+		Level:   strings.TrimSpace(r.FormValue("level")),
+		Theme:   strings.TrimSpace(r.FormValue("theme")),
+	}
+}
+
+// changeQuestions applies change, a change to the question bank, and writes the articles and questions to the sheets. The sheets are pulled before the change rather than after it as backup does, since pulling the Questions sheet would otherwise bring back a question that has just been deleted or re-keyed.
+func (s *Server) changeQuestions(change func() error) error {
+	s.pullSheetEdits()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := change(); err != nil {
+		return err
+	}
+	queueSheetWrites(s.Sync.BackupArticlesWrite(s.Articles), db.BackupQuestionsWrite(s.Questions))
+	return nil
+}
+
+// questions lists the past year questions with the number of articles tagged with each, filtered by paper, source, exam level and theme, and lets an admin edit, re-key or delete a question. Changes are made to every article tagged with the question too.
+func questions(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Redirect(w, r, "/admin", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == "POST" {
+		key := r.FormValue("key")
+
+		var err error
+		switch r.FormValue("action") {
+		case "update":
+			qn := questionFromForm(r)
+			if qn.Wording == "" {
+				err = db.ErrMissingField
+				break
+			}
+			s.takeSnapshot(db.SnapshotBeforeEdit)
+			err = s.changeQuestions(func() error {
+				_, err := db.UpdateQuestion(s.Articles, s.Questions, s.QuestionCounter, key, qn)
+				return err
+			})
+		case "delete":
+			s.takeSnapshot(db.SnapshotBeforeDelete)
+			err = s.changeQuestions(func() error {
+				_, err := db.DeleteQuestion(s.Articles, s.Questions, s.QuestionCounter, key, r.FormValue("reassign"))
+				return err
+			})
+		}
+
+		if err != nil {
+			var msg customError
+			switch {
+			case errors.Is(err, db.ErrMissingField):
+				msg = customError{ErrMsg: "Empty field(s) on form.", HelpMsg: "Make sure the form is fully filled."}
+			case errors.Is(err, db.ErrInvalidQuestion):
+				msg = customError{ErrMsg: fmt.Sprintf("Unable to change the question - %v.", err), HelpMsg: "The year, question number and paper must be numbers, e.g. 2019, 6 and 1."}
+			case errors.Is(err, db.ErrQuestionExists):
+				msg = customError{ErrMsg: fmt.Sprintf("Unable to change the question - %v.", err), HelpMsg: "To combine two questions, delete this one and move its articles to the other."}
+			case errors.Is(err, db.ErrQuestionInUse):
+				msg = customError{ErrMsg: fmt.Sprintf("Unable to delete the question - %v.", err), HelpMsg: "Choose another question to move its articles to, then delete it again."}
+			default:
+				msg = customError{ErrMsg: fmt.Sprintf("Unable to change the question - %v.", err), HelpMsg: "Go back to the question bank and try again."}
+			}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/questions", http.StatusSeeOther)
+		return
+	}
+
+	data := struct {
+		Questions []questionEntry
+		Selected  *questionEntry
+		Filter    questionFilterForm
+	}{}
+
+	filter := newQuestionFilter(r)
+	s.mu.Lock()
+	for _, qn := range db.SortedQuestions(s.Questions) {
+		data.Questions = append(data.Questions, questionEntry{qn, db.QuestionUsage(s.Articles, qn.Key())})
+	}
+	data.Filter = questionFilterForm{Action: "/questions", Filter: filter, Facets: db.NewQuestionFacets(s.Questions)}
+	s.mu.Unlock()
+
+	if key := r.FormValue("key"); key != "" {
+		for i := range data.Questions {
+			if data.Questions[i].Key() == key {
+				data.Selected = &data.Questions[i]
+			}
+		}
+		if data.Selected == nil {
+			msg := customError{ErrMsg: fmt.Sprintf("There is no question %s.", key), HelpMsg: "It may have been changed or deleted. Go back to the question bank and choose the question again."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+	} else if !filter.IsZero() {
+		filtered := make([]questionEntry, 0, len(data.Questions))
+		for _, e := range data.Questions {
+			if filter.Match(e.Question) {
+				filtered = append(filtered, e)
+			}
+		}
+		data.Questions = filtered
+	}
+
+	err := tpl.ExecuteTemplate(w, "questions.html", data)
+	if err != nil {
+		msg := customError{
+			ErrMsg:  fmt.Sprintf("%v", err),
+			HelpMsg: "",
+		}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+}
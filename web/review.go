@@ -0,0 +1,152 @@
+// Package web contains the server, routing, and handlers logic.
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// visibleArticles returns the articles that can be seen by the visitor of r: every article for a logged in curator, and only the published ones for students.
+func (s *Server) visibleArticles(w http.ResponseWriter, r *http.Request) *db.ArticlesDBByDate {
+	if checkCookie(w, r) {
+		return s.copyArticles()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return db.PublishedArticles(s.Articles)
+}
+
+// copyArticles returns a copy of the articles database, which can be read without holding s.mu while the sheet sync and the publisher change the database in the background.
+func (s *Server) copyArticles() *db.ArticlesDBByDate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	articles := append(db.ArticlesDBByDate(nil), *s.Articles...)
+	return &articles
+}
+
+// review is the review queue, listing the articles pending review, the scheduled articles, the drafts and the archived articles, and letting a curator move them through the workflow: submitting drafts for review, approving or returning articles pending review, publishing scheduled articles early, and archiving or restoring articles.
+func review(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Redirect(w, r, "/admin", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == "POST" {
+		st, err := db.ParseStatus(r.FormValue("status"))
+		if err != nil || r.FormValue("status") == "" {
+			msg := customError{ErrMsg: "No action seems to have been chosen.", HelpMsg: "Go back and choose what to do with the article."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+		name := curator(r)
+		if st == db.Published && name == "" && s.Config.RequireReview {
+			msg := customError{ErrMsg: "Unable to tell who is approving the article.", HelpMsg: "Log out and log in again, so that the approval is recorded under your name."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+
+		s.mu.Lock()
+		var prev db.Article
+		for _, a := range *s.Articles {
+			if a.URL == r.FormValue("url") {
+				prev = a
+			}
+		}
+		a, err := s.Articles.SetStatus(r.FormValue("url"), st, name, s.Config.RequireReview)
+		var article db.Article
+		if err == nil {
+			article = *a
+		}
+		s.mu.Unlock()
+		switch {
+		case errors.Is(err, db.ErrSelfReview):
+			msg := customError{ErrMsg: "You cannot approve an article you added yourself.", HelpMsg: "Ask another curator to review the article."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		case errors.Is(err, db.ErrReviewRequired):
+			msg := customError{ErrMsg: "The article has not been reviewed.", HelpMsg: "Submit the article for review, and ask another curator to approve it."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		case err != nil:
+			msg := customError{ErrMsg: fmt.Sprintf("Unable to change the status of the article - %v.", err), HelpMsg: "The article may have been deleted. Go back to the review queue and try again."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+
+		// the old feed sheet is public, so an article is added to it when it is first published, and not again when it is restored from the archive.
+		if article.IsPublished() && !prev.IsPublished() && prev.Status != db.Archived {
+			queueSheetWrites(db.AppendArticleToOldWrite(&article))
+		}
+		backup(w, r)
+		http.Redirect(w, r, "/review", http.StatusSeeOther)
+		return
+	}
+
+	s.mu.Lock()
+	data := struct {
+		Curator       string
+		RequireReview bool
+		Pending       db.ArticlesDBByDate
+		Scheduled     db.ArticlesDBByDate
+		Drafts        db.ArticlesDBByDate
+		Archived      db.ArticlesDBByDate
+	}{
+		Curator:       curator(r),
+		RequireReview: s.Config.RequireReview,
+		Pending:       *db.FilterByStatus(s.Articles, db.Pending),
+		Scheduled:     *db.FilterByStatus(s.Articles, db.Scheduled),
+		Drafts:        *db.FilterByStatus(s.Articles, db.Draft),
+		Archived:      *db.FilterByStatus(s.Articles, db.Archived),
+	}
+	s.mu.Unlock()
+
+	err := tpl.ExecuteTemplate(w, "review.html", data)
+	if err != nil {
+		msg := customError{
+			ErrMsg:  fmt.Sprintf("%v", err),
+			HelpMsg: "",
+		}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+}
+
+// publishScheduled publishes the scheduled articles that are due every interval, starting straight away so that articles that fell due while the app was down are not held back.
+func (s *Server) publishScheduled(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.publishDue(time.Now())
+		<-ticker.C
+	}
+}
+
+// publishDue publishes the scheduled articles that are due at now, adds them to the old feed sheet, and backs up the Articles sheet.
+func (s *Server) publishDue(now time.Time) {
+	s.mu.Lock()
+	due := s.Articles.HasDue(now)
+	s.mu.Unlock()
+	if !due {
+		return
+	}
+
+	// pull in edits made directly in the sheet first, so that the backup does not overwrite them.
+	s.pullSheetEdits()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	published := s.Articles.PublishDue(now)
+	for i := range published {
+		queueSheetWrites(db.AppendArticleToOldWrite(&published[i]))
+		log.Printf("Published the scheduled article %q", published[i].Title)
+	}
+	if len(published) > 0 {
+		queueSheetWrites(s.Sync.BackupArticlesWrite(s.Articles), db.BackupQuestionsWrite(s.Questions))
+	}
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// useTestArticles sets the articles and config of the server for the length of the test, and logs out every curator.
+func useTestArticles(t *testing.T, articles db.ArticlesDBByDate, cfg db.Config) {
+	t.Helper()
+	oldArticles, oldConfig := s.Articles, s.Config
+	s.Articles, s.Config = &articles, cfg
+	sessions.Lock()
+	sessions.active = make(map[string]session)
+	sessions.Unlock()
+	t.Cleanup(func() { s.Articles, s.Config = oldArticles, oldConfig })
+}
+
+// loginAs returns the session cookie of curator logging in.
+func loginAs(t *testing.T, curator string) *http.Cookie {
+	t.Helper()
+	w := httptest.NewRecorder()
+	setCookie(w, httptest.NewRequest("POST", "/admin", nil), curator)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "sessionID" {
+			return c
+		}
+	}
+	t.Fatal("no session cookie was set at login")
+	return nil
+}
+
+func TestDraftsOnlyVisibleToCurators(t *testing.T) {
+	useTestArticles(t, db.ArticlesDBByDate{
+		{Title: "Published", URL: "https://example.com/published"},
+		{Title: "Draft", URL: "https://example.com/draft", Status: db.Draft},
+	}, db.DefaultConfig())
+
+	valid := loginAs(t, "Mary")
+	expired := loginAs(t, "John")
+	sessions.Lock()
+	sess := sessions.active[expired.Value]
+	sess.expires = time.Now().Add(-time.Second)
+	sessions.active[expired.Value] = sess
+	sessions.Unlock()
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		want   int
+	}{
+		{"no session", nil, 1},
+		{"made up session", &http.Cookie{Name: "sessionID", Value: "anything"}, 1},
+		{"expired session", expired, 1},
+		{"logged in", valid, 2},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/all", nil)
+		if tt.cookie != nil {
+			r.AddCookie(&http.Cookie{Name: tt.cookie.Name, Value: tt.cookie.Value})
+		}
+		if got := s.visibleArticles(httptest.NewRecorder(), r).Len(); got != tt.want {
+			t.Errorf("%s: got %d articles, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReviewNeedsAnotherCurator(t *testing.T) {
+	cfg := db.DefaultConfig()
+	cfg.RequireReview = true
+	useTestArticles(t, db.ArticlesDBByDate{
+		{Title: "Pending", URL: "https://example.com/pending", Status: db.Pending, AddedBy: "Mary"},
+	}, cfg)
+
+	approve := func(c *http.Cookie) *httptest.ResponseRecorder {
+		form := url.Values{"url": {"https://example.com/pending"}, "status": {string(db.Published)}}
+		r := httptest.NewRequest("POST", "/review", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+		w := httptest.NewRecorder()
+		review(w, r)
+		return w
+	}
+
+	if w := approve(&http.Cookie{Name: "sessionID", Value: "anything"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d approving with a made up session, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := approve(loginAs(t, "Mary")); !strings.HasPrefix(w.Header().Get("Location"), "/error") {
+		t.Errorf("got redirected to %q approving an article the curator added, want the error page", w.Header().Get("Location"))
+	}
+	if got := (*s.Articles)[0]; got.Status != db.Pending || got.ReviewedBy != "" {
+		t.Errorf("got article %+v, want it still pending review", got)
+	}
+}
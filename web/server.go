@@ -7,6 +7,8 @@ import (
 	"log"
 	"net/http"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/jwnpoh/njcgpnewsfeed/db"
 	"google.golang.org/api/sheets/v4"
@@ -20,10 +22,20 @@ Author: Joel Poh
 ==> Started server, listening on port %v....
 ==> `
 
+// syncInterval is how often edits made directly in the Articles sheet are pulled into the app.
+const syncInterval = 5 * time.Minute
+
+// publishInterval is how often scheduled articles are checked for being due to be published.
+const publishInterval = time.Minute
+
+// sheetsTimeout is how long reading a sheet to pull in its edits may take, so that a slow response from Google Sheets does not hold up the sync for good.
+const sheetsTimeout = 30 * time.Second
+
 var tpl *template.Template
 
 // Server represents all objects to be initialised in the application.
 type Server struct {
+	Config          db.Config
 	Port            string
 	TemplateDir     string
 	AssetPath       string
@@ -32,8 +44,28 @@ type Server struct {
 	Questions       db.QuestionsDB
 	QuestionCounter db.QuestionCounter
 	Topics          db.TopicsMap
+	TopicTree       db.TopicTree
+	Sources         db.Sources
+	Suggestions     db.SuggestionQueue
+	Students        db.Students
+	Classes         db.Classes
+	Assignments     db.Assignments
+	Texts           map[string]db.Document
+	Outbox          *db.Outbox
+	Sync            *db.SheetSync
+	Snapshots       *db.SnapshotStore
+	Validation      *db.ValidationReport
+	Notifier        db.Notifier
 	Ctx             context.Context
 	Srv             *sheets.Service
+
+	// mu is held while the articles and questions databases are being changed.
+	mu sync.Mutex
+	// classifier is the TagClassifier last trained for tag suggestions, on the articles and texts with classifierFingerprint. It is guarded by mu.
+	classifier            *db.TagClassifier
+	classifierFingerprint uint64
+	// topicTreeLoaded is whether TopicTree has been read from the Topics sheet. Until it has, the hierarchy is not changed, so that the sheet is not replaced with an empty one. It is guarded by mu.
+	topicTreeLoaded bool
 }
 
 var s Server
@@ -45,6 +77,11 @@ func (s *Server) Start() error {
 	s.parseTemplates()
 	s.serveStatic()
 	s.router()
+	go s.Outbox.Run(s.Ctx, time.Minute)
+	go s.syncSheets(syncInterval)
+	go s.publishScheduled(publishInterval)
+	go s.scheduleSnapshots(s.Config.SnapshotInterval())
+	go s.fetchArticleTexts()
 	err := http.ListenAndServe(":"+s.Port, nil)
 	if err != nil {
 		return err
@@ -52,9 +89,25 @@ func (s *Server) Start() error {
 	return nil
 }
 
-// NewServer initialises the initial data necessary to get going.
-func NewServer() *Server {
+// NewServer initialises the initial data necessary to get going, using the settings in cfg. cfg should already have been passed to db.Configure.
+func NewServer(cfg db.Config) *Server {
 	ctx := context.Background()
+
+	outbox, err := db.OpenOutbox(cfg.OutboxPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// apply changes left unsynced by the previous run before reading the sheets, so that they are not lost.
+	if err := outbox.Flush(ctx); err != nil {
+		log.Printf("Unable to sync %d pending change(s) to Google Sheets - %v", outbox.Len(), err)
+	}
+
+	snapshots, err := db.OpenSnapshotStore(cfg.Snapshots.Dir, cfg.RetentionPolicy())
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	database := db.NewArticlesDBByDate()
 	qnDB, err := db.InitQuestionsDB(ctx)
 	if err != nil {
@@ -64,18 +117,137 @@ func NewServer() *Server {
 	tm := db.InitTopicsMap()
 	qc := db.InitQuestionCounter()
 
-	if err := database.InitArticlesDB(ctx, qnDB, tm, qc); err != nil {
+	// the Topics sheet is optional, so the feed still starts without a topic hierarchy if it cannot be read.
+	tt, err := db.InitTopicTree(ctx)
+	if err != nil {
+		log.Printf("Unable to load the topic hierarchy from the Topics sheet - %v", err)
+	}
+	topicTreeLoaded := err == nil
+
+	// the Sources sheet is optional too, and only adds to and renames the publications known by default. It is read before the articles so that their sources are named with it.
+	sources, err := db.InitSources(ctx)
+	if err != nil {
+		log.Printf("Unable to load the news sources from the Sources sheet - %v", err)
+	}
+	db.SetSources(sources)
+
+	// the Suggestions sheet is optional too, and starts empty until students suggest their first articles.
+	suggestions, err := db.InitSuggestions(ctx)
+	if err != nil {
+		log.Printf("Unable to load the suggested articles from the Suggestions sheet - %v", err)
+	}
+
+	// so is the Students sheet, which holds what students have saved.
+	students, err := db.InitStudents(ctx)
+	if err != nil {
+		log.Printf("Unable to load the student accounts from the Students sheet - %v", err)
+	}
+	initSessionKey(cfg.SessionSecret)
+
+	// as are the Classes and Assignments sheets, which hold the classes teachers have set up and the reading assigned to them.
+	classes, err := db.InitClasses(ctx)
+	if err != nil {
+		log.Printf("Unable to load the classes from the Classes sheet - %v", err)
+	}
+	assignments, err := db.InitAssignments(ctx)
+	if err != nil {
+		log.Printf("Unable to load the assigned reading from the Assignments sheet - %v", err)
+	}
+
+	notifier := db.NewNotifier(cfg)
+
+	report, err := database.InitArticlesDB(ctx, qnDB, tm, qc)
+	if err != nil {
 		log.Fatal(err)
 	}
+	if !report.OK() {
+		log.Printf("Skipped %d invalid row(s) in the Articles sheet", len(report.Errors))
+		go func() {
+			if err := notifier.Notify("NJC GP Newsfeed DB Error", report.String()); err != nil {
+				log.Printf("Unable to send the Articles sheet validation report - %v", err)
+			}
+		}()
+	}
 
+	s.Config = cfg
+	s.Port = cfg.Port
 	s.Articles = database
 	s.Questions = qnDB
 	s.QuestionCounter = qc
 	s.Topics = tm
+	s.TopicTree = tt
+	s.topicTreeLoaded = topicTreeLoaded
+	s.Sources = sources
+	s.Suggestions = suggestions
+	s.Students = students
+	s.Classes = classes
+	s.Assignments = assignments
+	s.Texts = make(map[string]db.Document)
+	s.Outbox = outbox
+	s.Sync = db.NewSheetSync(database, report)
+	outbox.OnApplied(s.Sync.Written)
+	s.Snapshots = snapshots
+	s.Validation = report
+	s.Notifier = notifier
 	s.Ctx = ctx
 	return &s
 }
 
+// syncSheets pulls edits made directly in the Articles sheet into the app every interval.
+func (s *Server) syncSheets(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.pullSheetEdits()
+	}
+}
+
+// pullSheetEdits applies edits made directly in the Articles sheet, and questions added to the Questions sheet, to the databases. Articles edited differently in the sheet and in the app are held as conflicts for an admin to resolve. The sheets are read without s.mu held, so that the pages of the feed are not held up while Google Sheets responds, and what was read is applied with it held.
+func (s *Server) pullSheetEdits() {
+	ctx, cancel := context.WithTimeout(s.Ctx, sheetsTimeout)
+	defer cancel()
+
+	// until the app's own changes to the questions have reached the sheet, questions it has deleted or re-keyed would look as if they had been added there, so the sheet is left alone.
+	var questions []db.Question
+	questionWrites := s.Outbox.Enqueued(s.Config.SheetID, "Questions")
+	if !s.Outbox.HasPending(s.Config.SheetID, "Questions") {
+		var err error
+		if questions, err = db.ReadQuestions(ctx); err != nil {
+			log.Printf("Unable to sync the Questions sheet - %v", err)
+		}
+	}
+	sheet, err := s.Sync.Read(ctx)
+	if err != nil {
+		log.Printf("Unable to sync the Articles sheet - %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// pick up questions added to the sheet first, so that articles tagged with them can be applied. They are left for the next sync if the questions were changed in the app while the sheet was being read.
+	if s.Outbox.Enqueued(s.Config.SheetID, "Questions") == questionWrites {
+		if n := db.AddNewQuestions(s.Questions, questions); n > 0 {
+			log.Printf("Synced the Questions sheet: %d question(s) added", n)
+		}
+	}
+
+	result, err := s.Sync.Apply(sheet, s.Articles, s.Questions, s.Topics, s.QuestionCounter)
+	if err != nil {
+		log.Printf("Unable to sync the Articles sheet - %v", err)
+		return
+	}
+	if result.Applied > 0 || result.Conflicts > 0 {
+		log.Printf("Synced the Articles sheet: %d edit(s) applied, %d conflict(s)", result.Applied, result.Conflicts)
+	}
+
+	// a backup still waiting to be written was made before these sheet edits were pulled in, and would overwrite them, so it is replaced with one that includes them.
+	if (result.Applied > 0 || result.Conflicts > 0 || result.Rejected > 0) && s.Outbox.HasPending(s.Config.SheetID, "Articles") {
+		queueSheetWrites(s.Sync.BackupArticlesWrite(s.Articles))
+	}
+}
+
 func (s *Server) parseTemplates() {
 	templates := filepath.Join(s.TemplateDir, "*html")
 	tpl = template.Must(template.ParseGlob(templates))
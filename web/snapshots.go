@@ -0,0 +1,136 @@
+// Package web contains the server, routing, and handlers logic.
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// takeSnapshot saves a snapshot of the databases, recording reason as one of the db.Snapshot constants. Failures are logged rather than returned, so that a full disk does not stop admins from making changes.
+func (s *Server) takeSnapshot(reason string) {
+	s.mu.Lock()
+	var tt db.TopicTree
+	if s.topicTreeLoaded {
+		tt = s.TopicTree
+	}
+	sn := db.NewSnapshot(s.Articles, s.Questions, tt)
+	s.mu.Unlock()
+
+	if _, err := s.Snapshots.Save(sn, reason); err != nil {
+		log.Printf("Unable to save a snapshot %s - %v", reason, err)
+	}
+}
+
+// scheduleSnapshots takes a snapshot every interval, starting straight away if the newest snapshot is already older than interval.
+func (s *Server) scheduleSnapshots(interval time.Duration) {
+	latest, ok, err := s.Snapshots.Latest()
+	if err != nil {
+		log.Printf("Unable to list snapshots - %v", err)
+	}
+	if !ok || time.Since(latest.Created) >= interval {
+		s.takeSnapshot(db.SnapshotScheduled)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.takeSnapshot(db.SnapshotScheduled)
+	}
+}
+
+// restoreSnapshot replaces the databases and the topic hierarchy with the contents of the snapshot, after taking a snapshot of the current databases so that the restore can itself be undone.
+func (s *Server) restoreSnapshot(sn *db.Snapshot) error {
+	database, qnDB, tm, qc, err := sn.Load()
+	if err != nil {
+		return err
+	}
+
+	s.takeSnapshot(db.SnapshotBeforeRestore)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	*s.Articles = *database
+	s.Questions = qnDB
+	s.Topics = tm
+	s.QuestionCounter = qc
+	if sn.TopicTree != nil {
+		s.TopicTree, s.topicTreeLoaded = make(db.TopicTree, len(sn.TopicTree)), true
+		for child, parent := range sn.TopicTree {
+			s.TopicTree[child] = parent
+		}
+		queueSheetWrites(db.BackupTopicsWrite(s.TopicTree))
+	}
+	return nil
+}
+
+func snapshots(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Redirect(w, r, "/admin", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == "POST" {
+		switch r.FormValue("action") {
+		case "take":
+			s.takeSnapshot(db.SnapshotManual)
+		case "restore":
+			sn, err := s.Snapshots.Open(r.FormValue("id"))
+			if err == nil {
+				err = s.restoreSnapshot(sn)
+			}
+			if err != nil {
+				msg := customError{ErrMsg: fmt.Sprintf("Unable to restore the snapshot - %v", err), HelpMsg: "Nothing has been changed. Try restoring a different snapshot."}
+				http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+				return
+			}
+			backup(w, r)
+		}
+		http.Redirect(w, r, "/snapshots", http.StatusSeeOther)
+		return
+	}
+
+	data := struct {
+		Snapshots []db.SnapshotInfo
+		Preview   *db.Snapshot
+		ID        string
+		Diff      db.SnapshotDiff
+	}{}
+
+	if id := r.FormValue("id"); id != "" {
+		sn, err := s.Snapshots.Open(id)
+		if err != nil {
+			msg := customError{ErrMsg: fmt.Sprintf("Unable to open the snapshot - %v", err), HelpMsg: "The snapshot may have been removed by the retention policy. Go back to the list of snapshots and try again."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+		data.Preview, data.ID = sn, id
+
+		s.mu.Lock()
+		data.Diff = sn.Diff(s.Articles, s.Questions, s.TopicTree)
+		s.mu.Unlock()
+	} else {
+		list, err := s.Snapshots.List()
+		if err != nil {
+			msg := customError{ErrMsg: fmt.Sprintf("%v", err), HelpMsg: "Check that the snapshot directory in the config exists and can be read."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+		data.Snapshots = list
+	}
+
+	err := tpl.ExecuteTemplate(w, "snapshots.html", data)
+	if err != nil {
+		msg := customError{
+			ErrMsg:  fmt.Sprintf("%v", err),
+			HelpMsg: "",
+		}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+}
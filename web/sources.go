@@ -0,0 +1,69 @@
+// Package web contains the server, routing, and handlers logic.
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// sourceEntry is a row of the news sources page.
+type sourceEntry struct {
+	Domain   string
+	Name     string
+	Articles int
+}
+
+// newsSources lets an admin see which publications the articles are from, and name the publication of a domain, such as The Straits Times for straitstimes.com. Articles from domains without a name are shown with the domain instead.
+func newsSources(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Redirect(w, r, "/admin", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == "POST" {
+		domain := db.SourceDomain(r.FormValue("domain"))
+		if domain == "" {
+			msg := customError{ErrMsg: "Empty field(s) on form.", HelpMsg: "Enter the domain of the news site, such as straitstimes.com."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+
+		s.mu.Lock()
+		sources := s.Sources.Copy()
+		sources.Set(domain, r.FormValue("name"))
+		s.Sources = sources
+		db.SetSources(sources)
+		s.Articles.RefreshSources()
+		queueSheetWrites(db.BackupSourcesWrite(sources))
+		s.mu.Unlock()
+
+		http.Redirect(w, r, "/sources", http.StatusSeeOther)
+		return
+	}
+
+	s.mu.Lock()
+	domains := db.SourceDomains(s.Articles)
+	data := make([]sourceEntry, 0, len(domains))
+	for _, d := range domains {
+		data = append(data, sourceEntry{Domain: d.Source, Name: s.Sources.Name(d.Source), Articles: d.Count})
+	}
+	s.mu.Unlock()
+
+	// domains without a name are listed first, as they are the ones to name.
+	sort.SliceStable(data, func(i, j int) bool {
+		return data[i].Name == data[i].Domain && data[j].Name != data[j].Domain
+	})
+
+	err := tpl.ExecuteTemplate(w, "sources.html", data)
+	if err != nil {
+		msg := customError{
+			ErrMsg:  fmt.Sprintf("%v", err),
+			HelpMsg: "",
+		}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+}
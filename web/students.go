@@ -0,0 +1,359 @@
+// Package web contains the server, routing, and handlers logic.
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// studentSessionAge is how long a student stays signed in, and signInLinkAge how long the sign-in link emailed to them works.
+const (
+	studentSessionAge = 30 * 24 * time.Hour
+	signInLinkAge     = 30 * time.Minute
+)
+
+// sessionKey signs the sign-in links and sessions of students. It is set by initSessionKey.
+var sessionKey []byte
+
+// signInLimiter limits how many sign-in links each visitor can ask for, so that the form cannot be used to flood students' inboxes.
+var signInLimiter = newRateLimiter(5, time.Hour)
+
+// initSessionKey sets the key that signs the sign-in links and sessions of students to secret, or to a random key if secret is empty, which signs every student out when the app restarts.
+func initSessionKey(secret string) {
+	sessionKey = []byte(secret)
+	if len(sessionKey) > 0 {
+		return
+	}
+	sessionKey = make([]byte, 32)
+	if _, err := rand.Read(sessionKey); err != nil {
+		log.Fatalf("Unable to generate a session key - %v", err)
+	}
+}
+
+// signToken returns a token for email that is valid for purpose until expires, such as a sign-in link or a session, signed with sessionKey so that it cannot be forged.
+func signToken(purpose, email string, expires time.Time) string {
+	payload := email + "|" + strconv.FormatInt(expires.Unix(), 10)
+	mac := hmac.New(sha256.New, sessionKey)
+	mac.Write([]byte(purpose + "|" + payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyToken returns the email of a token made with signToken for purpose, and reports whether the token is genuine and has not expired at now.
+func verifyToken(purpose, token string, now time.Time) (string, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", false
+	}
+	mac := hmac.New(sha256.New, sessionKey)
+	mac.Write([]byte(purpose + "|"))
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", false
+	}
+
+	sep := strings.LastIndex(string(payload), "|")
+	if sep < 0 {
+		return "", false
+	}
+	expires, err := strconv.ParseInt(string(payload[sep+1:]), 10, 64)
+	if err != nil || now.Unix() > expires {
+		return "", false
+	}
+	return string(payload[:sep]), true
+}
+
+// student returns the email address of the student signed in to r, or an empty string if no student is signed in.
+func student(r *http.Request) string {
+	c, err := r.Cookie("studentSession")
+	if err != nil {
+		return ""
+	}
+	email, ok := verifyToken("session", c.Value, time.Now())
+	if !ok || !s.Config.IsStudentEmail(email) {
+		return ""
+	}
+	return email
+}
+
+// studentItem is an article saved by a student, with whether they have read it.
+type studentItem struct {
+	db.Article
+	Read bool
+}
+
+// studentList is a reading list of a student, with the articles in it.
+type studentList struct {
+	Name     string
+	Articles []studentItem
+}
+
+// studentItems returns the published articles at urls as saved by the student a. s.mu must be held.
+func studentItems(a *db.Student, urls []string) []studentItem {
+	articles := db.ArticlesByURL(s.Articles, urls)
+	items := make([]studentItem, 0, len(*articles))
+	for _, article := range *articles {
+		items = append(items, studentItem{Article: article, Read: a.HasRead(article.URL)})
+	}
+	return items
+}
+
+// account is the page where students sign in with their school email address, and see the articles they have bookmarked and put in reading lists. Students are emailed a link to sign in with, so that they do not need a password.
+func account(w http.ResponseWriter, r *http.Request) {
+	if len(s.Config.StudentDomains) == 0 {
+		msg := customError{ErrMsg: "Student accounts are not available.", HelpMsg: "Ask your GP teacher about saving articles."}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+
+	if r.Method == "POST" {
+		email := db.NormalizeEmail(r.FormValue("email"))
+		if !s.Config.IsStudentEmail(email) {
+			msg := customError{ErrMsg: "That is not a school email address.", HelpMsg: fmt.Sprintf("Sign in with your school email address, ending with @%s.", strings.TrimPrefix(s.Config.StudentDomains[0], "@"))}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+		if !signInLimiter.Allow(clientAddress(r), time.Now()) {
+			msg := customError{ErrMsg: "Too many sign-in links have been asked for.", HelpMsg: "Use the link in the latest email, or try again in an hour."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+
+		// the link is built from the configured address, and not the Host header of r, which anyone can set to their own site to be sent a student's sign-in link.
+		link := strings.TrimRight(s.Config.PublicURL, "/") + "/account/signin?token=" + url.QueryEscape(signToken("signin", email, time.Now().Add(signInLinkAge)))
+		body := fmt.Sprintf("Open this link within %d minutes to sign in to the NJC GP News Feed:\n\n%s\n\nIf you did not ask to sign in, you can ignore this email.", int(signInLinkAge.Minutes()), link)
+		// without a mail server, as when developing the app, the link is written to the log instead.
+		if s.Config.SMTP.Host == "" {
+			log.Printf("Sign-in link for %s: %s", email, link)
+		} else if err := db.SendMail(s.Config.Notify.From, email, s.Config.Notify.From, "Sign in to the NJC GP News Feed", body); err != nil {
+			log.Printf("Unable to email a sign-in link to %s - %v", email, err)
+			msg := customError{ErrMsg: "Unable to email the sign-in link.", HelpMsg: "Please try again later."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/account?sent=1", http.StatusSeeOther)
+		return
+	}
+
+	data := struct {
+		Email       string
+		Sent        bool
+		Domain      string
+		Bookmarks   []studentItem
+		Lists       []studentList
+		Assignments []studentAssignment
+		Read        int
+	}{
+		Email:  student(r),
+		Sent:   r.FormValue("sent") != "",
+		Domain: strings.TrimPrefix(s.Config.StudentDomains[0], "@"),
+	}
+
+	if data.Email != "" {
+		s.mu.Lock()
+		a := s.Students.Get(data.Email)
+		data.Assignments = studentAssignments(a, time.Now())
+		data.Bookmarks = studentItems(a, a.Bookmarks)
+		for _, l := range a.Lists {
+			data.Lists = append(data.Lists, studentList{Name: l.Name, Articles: studentItems(a, l.URLs)})
+		}
+		data.Read = db.ArticlesByURL(s.Articles, a.Read).Len()
+		s.mu.Unlock()
+	}
+
+	err := tpl.ExecuteTemplate(w, "account.html", data)
+	if err != nil {
+		msg := customError{
+			ErrMsg:  fmt.Sprintf("%v", err),
+			HelpMsg: "",
+		}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+}
+
+// accountSignIn signs a student in with the link emailed to them.
+func accountSignIn(w http.ResponseWriter, r *http.Request) {
+	email, ok := verifyToken("signin", r.FormValue("token"), time.Now())
+	if !ok || !s.Config.IsStudentEmail(email) {
+		msg := customError{ErrMsg: "The sign-in link has expired or is not valid.", HelpMsg: "Ask for a new sign-in link on the My reading page."}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "studentSession",
+		Value:    signToken("session", email, time.Now().Add(studentSessionAge)),
+		Path:     "/",
+		MaxAge:   int(studentSessionAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+// accountSignOut signs the student out.
+func accountSignOut(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: "studentSession", Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// readingListJSON is a reading list as returned by the student API.
+type readingListJSON struct {
+	Name     string      `json:"name"`
+	Articles []db.Record `json:"articles"`
+}
+
+// assignmentJSON is reading assigned to a class of a student as returned by the student API.
+type assignmentJSON struct {
+	Class    string      `json:"class"`
+	Title    string      `json:"title"`
+	Due      string      `json:"due"`
+	Articles []db.Record `json:"articles"`
+}
+
+// studentJSON is a student's account as returned by the student API, with the articles they have bookmarked and put in reading lists, the URLs of the articles they have read, and the reading assigned to their classes.
+type studentJSON struct {
+	Email       string            `json:"email"`
+	Bookmarks   []db.Record       `json:"bookmarks"`
+	Read        []string          `json:"read"`
+	Lists       []readingListJSON `json:"lists"`
+	Assignments []assignmentJSON  `json:"assignments"`
+}
+
+// records returns the published articles at urls as Records. s.mu must be held.
+func records(urls []string) []db.Record {
+	articles := db.ArticlesByURL(s.Articles, urls)
+	recs := make([]db.Record, 0, len(*articles))
+	for _, a := range *articles {
+		recs = append(recs, db.NewRecord(a))
+	}
+	return recs
+}
+
+// newStudentJSON returns the account of the student a for the student API. s.mu must be held.
+func newStudentJSON(a *db.Student) studentJSON {
+	v := studentJSON{
+		Email:       a.Email,
+		Bookmarks:   records(a.Bookmarks),
+		Read:        make([]string, 0, len(a.Read)),
+		Lists:       make([]readingListJSON, 0, len(a.Lists)),
+		Assignments: make([]assignmentJSON, 0),
+	}
+	for _, r := range records(a.Read) {
+		v.Read = append(v.Read, r.URL)
+	}
+	for _, l := range a.Lists {
+		v.Lists = append(v.Lists, readingListJSON{Name: l.Name, Articles: records(l.URLs)})
+	}
+	for _, as := range s.Assignments.ForClasses(s.Classes.Of(a.Email)...) {
+		v.Assignments = append(v.Assignments, assignmentJSON{Class: as.Class, Title: as.Title, Due: as.Due.Format(db.DueDateFormat), Articles: records(as.URLs)})
+	}
+	return v
+}
+
+// writeJSON writes v as the JSON response to a student API request, with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Unable to write the API response - %v", err)
+	}
+}
+
+// studentAPI is the JSON API of student accounts. GET /api/me returns the signed in student's account. POST /api/me/bookmarks and /api/me/read bookmark the article at url or mark it as read, and POST /api/me/lists adds it to the reading list named list; with on=false they undo it instead, and on=false without a url deletes a whole reading list. They return the updated account, or redirect to redirect, a path on the site, for the forms on the My reading page.
+func studentAPI(w http.ResponseWriter, r *http.Request) {
+	redirect := r.FormValue("redirect")
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") {
+		redirect = ""
+	}
+	fail := func(status int, msg string) {
+		if redirect != "" {
+			msg := customError{ErrMsg: msg, HelpMsg: "Go back to My reading and try again."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+		writeJSON(w, status, map[string]string{"error": msg})
+	}
+
+	email := student(r)
+	if email == "" {
+		fail(http.StatusUnauthorized, "Sign in with your school email address to save articles.")
+		return
+	}
+
+	if r.URL.Path == "/api/me" {
+		s.mu.Lock()
+		v := newStudentJSON(s.Students.Get(email))
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, v)
+		return
+	}
+
+	if r.Method != "POST" {
+		fail(http.StatusMethodNotAllowed, "Use POST to save articles.")
+		return
+	}
+	articleURL := strings.TrimSpace(r.FormValue("url"))
+	on := true
+	if v := r.FormValue("on"); v != "" {
+		on, _ = strconv.ParseBool(v)
+	}
+
+	s.mu.Lock()
+	if articleURL != "" && db.ArticlesByURL(s.Articles, []string{articleURL}).Len() == 0 && on {
+		s.mu.Unlock()
+		fail(http.StatusNotFound, "That article is not on the feed.")
+		return
+	}
+	a := s.Students.Get(email)
+	var err error
+	switch {
+	case r.URL.Path == "/api/me/lists":
+		err = a.SetInList(r.FormValue("list"), articleURL, on)
+	case articleURL == "":
+		err = fmt.Errorf("no article was given")
+	case r.URL.Path == "/api/me/bookmarks":
+		err = a.SetBookmark(articleURL, on)
+	case r.URL.Path == "/api/me/read":
+		err = a.SetRead(articleURL, on)
+	default:
+		s.mu.Unlock()
+		fail(http.StatusNotFound, "There is no such API.")
+		return
+	}
+	if err == nil {
+		queueSheetWrites(db.BackupStudentsWrite(s.Students))
+	}
+	v := newStudentJSON(a)
+	s.mu.Unlock()
+
+	switch {
+	case err != nil:
+		fail(http.StatusBadRequest, fmt.Sprintf("Unable to save - %v.", err))
+	case redirect != "":
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+	default:
+		writeJSON(w, http.StatusOK, v)
+	}
+}
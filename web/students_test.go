@@ -0,0 +1,74 @@
+package web
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+func TestSignToken(t *testing.T) {
+	initSessionKey("secret")
+	now := time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)
+	token := signToken("signin", "ann@students.example.edu", now.Add(signInLinkAge))
+
+	if email, ok := verifyToken("signin", token, now); !ok || email != "ann@students.example.edu" {
+		t.Errorf("got %q, %v for a genuine token, want ann", email, ok)
+	}
+
+	forged := signToken("signin", "bob@students.example.edu", now.Add(signInLinkAge))
+	tests := []struct {
+		name  string
+		token string
+		when  time.Time
+		use   string
+	}{
+		{"expired", token, now.Add(signInLinkAge + time.Second), "signin"},
+		{"used as a session", token, now, "session"},
+		{"email changed", strings.Split(forged, ".")[0] + "." + strings.Split(token, ".")[1], now, "signin"},
+		{"not a token", "ann@students.example.edu", now, "signin"},
+	}
+	for _, tt := range tests {
+		if email, ok := verifyToken(tt.use, tt.token, tt.when); ok {
+			t.Errorf("%s: got %q accepted, want it rejected", tt.name, email)
+		}
+	}
+
+	initSessionKey("another secret")
+	if _, ok := verifyToken("signin", token, now); ok {
+		t.Error("got a token accepted after the session secret changed, want it rejected")
+	}
+}
+
+func TestSignInLinkUsesPublicURL(t *testing.T) {
+	initSessionKey("secret")
+	cfg := db.DefaultConfig()
+	cfg.StudentDomains = []string{"students.example.edu"}
+	cfg.PublicURL = "https://gp.example.com/"
+	useTestArticles(t, nil, cfg)
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	form := url.Values{"email": {"ann@students.example.edu"}}
+	r := httptest.NewRequest("POST", "/account", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Host = "attacker.example.net"
+	w := httptest.NewRecorder()
+	account(w, r)
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/account?sent=1" {
+		t.Fatalf("got %d to %q, want the sign-in link to be sent", w.Code, w.Header().Get("Location"))
+	}
+	if !strings.Contains(logged.String(), "https://gp.example.com/account/signin?token=") || strings.Contains(logged.String(), "attacker") {
+		t.Errorf("got sign-in link %q, want one to the public URL", logged.String())
+	}
+}
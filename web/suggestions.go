@@ -0,0 +1,218 @@
+// Package web contains the server, routing, and handlers logic.
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// suggestionLimit is how many articles each student can suggest in suggestionWindow, so that the moderation queue cannot be flooded.
+const (
+	suggestionLimit  = 5
+	suggestionWindow = time.Hour
+)
+
+// rateLimiter limits how often each client can do something, such as suggesting articles, by the times of their recent attempts.
+type rateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	recent map[string][]time.Time
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{limit: limit, window: window, recent: make(map[string][]time.Time)}
+}
+
+// Allow records an attempt by client at now, and reports whether it is within the limit. Attempts that are not allowed are not recorded, so that a client is let through again once its earlier attempts fall out of the window.
+func (l *rateLimiter) Allow(client string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// clients without attempts in the window are dropped, so that the map does not grow with every visitor.
+	recent := make(map[string][]time.Time, len(l.recent))
+	for c, times := range l.recent {
+		for _, t := range times {
+			if now.Sub(t) < l.window {
+				recent[c] = append(recent[c], t)
+			}
+		}
+	}
+	l.recent = recent
+
+	if len(l.recent[client]) >= l.limit {
+		return false
+	}
+	l.recent[client] = append(l.recent[client], now)
+	return true
+}
+
+var suggestionLimiter = newRateLimiter(suggestionLimit, suggestionWindow)
+
+// clientAddress returns the IP address of the visitor of r. Behind the hosting platform's proxy, it is taken from the X-Forwarded-For header, and only the last address in the header is used, as it is the one the proxy added: the ones before it are sent by the visitor, who could change them with every request to get around the rate limits. Otherwise the header is ignored, as all of it comes from the visitor.
+func clientAddress(r *http.Request) string {
+	if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 && s.Config.BehindProxy {
+		addrs := strings.Split(fwd[len(fwd)-1], ",")
+		return strings.TrimSpace(addrs[len(addrs)-1])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// suggestArticle is the public form for students to suggest an article for the feed, with an optional reason and suggested tags. Suggestions wait in the moderation queue until a curator accepts or rejects them.
+func suggestArticle(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		// the website field is hidden from people, so only spam bots fill it in. They are thanked as usual so that they do not try again.
+		if r.FormValue("website") != "" {
+			http.Redirect(w, r, "/suggestArticle?sent=1", http.StatusSeeOther)
+			return
+		}
+
+		sg, err := db.NewSuggestion(r.FormValue("url"), r.FormValue("reason"), r.FormValue("tags"), time.Now())
+		limited := false
+		if err == nil {
+			// the suggestion is checked and added under the same lock, so that the same article suggested twice at once is only added once.
+			s.mu.Lock()
+			err = s.Suggestions.Check(sg, s.Articles)
+			limited = err == nil && !suggestionLimiter.Allow(clientAddress(r), time.Now())
+			if err == nil && !limited {
+				s.Suggestions = append(s.Suggestions, sg)
+				queueSheetWrites(db.AppendSuggestionWrite(sg))
+			}
+			s.mu.Unlock()
+		}
+		if limited {
+			msg := customError{ErrMsg: "You have suggested several articles in the past hour.", HelpMsg: "Thank you! Please wait a while before suggesting more articles."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+		switch {
+		case errors.Is(err, db.ErrInvalidURL):
+			msg := customError{ErrMsg: "That does not look like the link to an article.", HelpMsg: "Copy the full address of the article from your browser, starting with https://."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		case errors.Is(err, db.ErrSpam):
+			msg := customError{ErrMsg: fmt.Sprintf("Unable to send the suggestion - %v.", strings.TrimPrefix(err.Error(), db.ErrSpam.Error()+": ")), HelpMsg: fmt.Sprintf("Keep the reason under %d characters without links, and suggest at most %d tags.", db.MaxReasonLength, db.MaxSuggestedTags)}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		case errors.Is(err, db.ErrDuplicateArticle):
+			msg := customError{ErrMsg: "That article is already on the feed.", HelpMsg: "Search for it to see the topics and questions it is tagged with."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		case errors.Is(err, db.ErrAlreadySuggested):
+			msg := customError{ErrMsg: "Someone has already suggested that article.", HelpMsg: "Thank you! The teachers will look at it soon."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		case err != nil:
+			msg := customError{ErrMsg: fmt.Sprintf("Unable to send the suggestion - %v.", err), HelpMsg: "Please try again later."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/suggestArticle?sent=1", http.StatusSeeOther)
+		return
+	}
+
+	data := struct {
+		Sent      bool
+		MaxReason int
+		MaxTags   int
+	}{
+		Sent:      r.FormValue("sent") != "",
+		MaxReason: db.MaxReasonLength,
+		MaxTags:   db.MaxSuggestedTags,
+	}
+
+	err := tpl.ExecuteTemplate(w, "suggestArticle.html", data)
+	if err != nil {
+		msg := customError{
+			ErrMsg:  fmt.Sprintf("%v", err),
+			HelpMsg: "",
+		}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+}
+
+// suggestions is the moderation queue of the articles suggested by students. Accepting a suggestion opens the add article form filled in with it, and the suggestion is marked as accepted once the article is added. Rejecting a suggestion records the curator's note on why.
+func suggestions(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Redirect(w, r, "/admin", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == "POST" {
+		s.mu.Lock()
+		_, err := s.Suggestions.Moderate(r.FormValue("id"), db.SuggestionRejected, r.FormValue("note"), curator(r), time.Now())
+		if err == nil {
+			queueSheetWrites(db.BackupSuggestionsWrite(s.Suggestions))
+		}
+		s.mu.Unlock()
+		if err != nil {
+			msg := customError{ErrMsg: fmt.Sprintf("Unable to reject the suggestion - %v.", err), HelpMsg: "Another curator may have moderated it already. Go back to the suggestions and try again."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+
+		http.Redirect(w, r, "/suggestions", http.StatusSeeOther)
+		return
+	}
+
+	s.mu.Lock()
+	moderated := s.Suggestions.Moderated()
+	if len(moderated) > 20 {
+		moderated = moderated[:20]
+	}
+	data := struct {
+		Pending   db.SuggestionQueue
+		Moderated db.SuggestionQueue
+	}{
+		Pending:   s.Suggestions.Pending(),
+		Moderated: moderated,
+	}
+	s.mu.Unlock()
+
+	err := tpl.ExecuteTemplate(w, "suggestions.html", data)
+	if err != nil {
+		msg := customError{
+			ErrMsg:  fmt.Sprintf("%v", err),
+			HelpMsg: "",
+		}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+}
+
+// acceptSuggestion marks the suggestion with the given ID as accepted by the curator of r, once the suggested article has been added with the add article form. Suggestions that have already been moderated are left as they are.
+func acceptSuggestion(r *http.Request, id string) {
+	if id == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.Suggestions.Moderate(id, db.SuggestionAccepted, "", curator(r), time.Now()); err != nil {
+		return
+	}
+	queueSheetWrites(db.BackupSuggestionsWrite(s.Suggestions))
+}
+
+// pendingSuggestion returns the pending suggestion with the given ID, to fill in the add article form with, or a zero suggestion if there is none.
+func pendingSuggestion(id string) db.StudentSuggestion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sg := s.Suggestions.Find(id); sg != nil && sg.Status == db.SuggestionPending {
+		return *sg
+	}
+	return db.StudentSuggestion{}
+}
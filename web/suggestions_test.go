@@ -0,0 +1,117 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// useTestOutbox gives the server an empty outbox for the length of the test.
+func useTestOutbox(t *testing.T) {
+	t.Helper()
+	outbox, err := db.OpenOutbox(filepath.Join(t.TempDir(), "outbox.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := s.Outbox
+	s.Outbox = outbox
+	t.Cleanup(func() { s.Outbox = old })
+}
+
+func TestClientAddress(t *testing.T) {
+	tests := []struct {
+		name        string
+		behindProxy bool
+		forwarded   []string
+		want        string
+	}{
+		{"no proxy", true, nil, "192.0.2.1"},
+		{"proxy", true, []string{"198.51.100.7"}, "198.51.100.7"},
+		{"made up address before the proxy's", true, []string{"203.0.113.9, 198.51.100.7"}, "198.51.100.7"},
+		{"made up header before the proxy's", true, []string{"203.0.113.9", "198.51.100.7"}, "198.51.100.7"},
+		{"made up header without a proxy", false, []string{"203.0.113.9"}, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		cfg := db.DefaultConfig()
+		cfg.BehindProxy = tt.behindProxy
+		useTestArticles(t, nil, cfg)
+		r := httptest.NewRequest("POST", "/suggestArticle", nil)
+		for _, fwd := range tt.forwarded {
+			r.Header.Add("X-Forwarded-For", fwd)
+		}
+		if got := clientAddress(r); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestionRateLimit(t *testing.T) {
+	cfg := db.DefaultConfig()
+	cfg.BehindProxy = true
+	useTestArticles(t, nil, cfg)
+	useTestOutbox(t)
+	oldLimiter, oldSuggestions := suggestionLimiter, s.Suggestions
+	suggestionLimiter, s.Suggestions = newRateLimiter(suggestionLimit, suggestionWindow), nil
+	t.Cleanup(func() { suggestionLimiter, s.Suggestions = oldLimiter, oldSuggestions })
+
+	suggest := func(i int) string {
+		form := url.Values{"url": {fmt.Sprintf("https://example.com/article-%d", i)}}
+		r := httptest.NewRequest("POST", "/suggestArticle", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		// a new made up address with each suggestion does not get around the limit.
+		r.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d, 198.51.100.7", i))
+		w := httptest.NewRecorder()
+		suggestArticle(w, r)
+		if w.Code != http.StatusSeeOther {
+			t.Fatalf("got status %d, want a redirect", w.Code)
+		}
+		return w.Header().Get("Location")
+	}
+
+	for i := 0; i < suggestionLimit; i++ {
+		if got := suggest(i); got != "/suggestArticle?sent=1" {
+			t.Fatalf("got redirected to %q for suggestion %d, want it sent", got, i+1)
+		}
+	}
+	if got := suggest(suggestionLimit); !strings.HasPrefix(got, "/error") {
+		t.Errorf("got redirected to %q after %d suggestions, want the error page", got, suggestionLimit)
+	}
+	if len(s.Suggestions) != suggestionLimit || len(s.Outbox.Pending()) != suggestionLimit {
+		t.Errorf("got %d suggestions and %d writes queued, want %d", len(s.Suggestions), len(s.Outbox.Pending()), suggestionLimit)
+	}
+
+	// visitors at other addresses have their own limit.
+	if !suggestionLimiter.Allow("198.51.100.8", time.Now()) {
+		t.Error("got another address limited, want it allowed")
+	}
+}
+
+func TestSuggestionRateLimitWithoutProxy(t *testing.T) {
+	useTestArticles(t, nil, db.DefaultConfig())
+	useTestOutbox(t)
+	oldLimiter, oldSuggestions := suggestionLimiter, s.Suggestions
+	suggestionLimiter, s.Suggestions = newRateLimiter(suggestionLimit, suggestionWindow), nil
+	t.Cleanup(func() { suggestionLimiter, s.Suggestions = oldLimiter, oldSuggestions })
+
+	var got string
+	for i := 0; i <= suggestionLimit; i++ {
+		form := url.Values{"url": {fmt.Sprintf("https://example.com/article-%d", i)}}
+		r := httptest.NewRequest("POST", "/suggestArticle", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		// without a proxy, the whole header is made up by the visitor.
+		r.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+		w := httptest.NewRecorder()
+		suggestArticle(w, r)
+		got = w.Header().Get("Location")
+	}
+	if !strings.HasPrefix(got, "/error") || len(s.Suggestions) != suggestionLimit {
+		t.Errorf("got redirected to %q with %d suggestions, want the visitor limited by their own address", got, len(s.Suggestions))
+	}
+}
@@ -0,0 +1,193 @@
+// Package web contains the server, routing, and handlers logic.
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+// topicEntry is a row of the topic manager.
+type topicEntry struct {
+	Topic       db.Topic
+	Path        []db.Topic
+	Parent      db.Topic
+	Articles    int
+	Descendants int
+}
+
+// topicEntries returns every topic that is tagged on an article or placed in the hierarchy, in hierarchy order. The caller must hold s.mu.
+func (s *Server) topicEntries() []topicEntry {
+	seen := make(map[db.Topic]bool)
+	for t := range s.Topics {
+		seen[t] = true
+	}
+	for t := range s.TopicTree {
+		seen[t] = true
+	}
+
+	entries := make([]topicEntry, 0, len(seen))
+	for t := range seen {
+		entries = append(entries, topicEntry{
+			Topic:       t,
+			Path:        s.TopicTree.Path(t),
+			Parent:      s.TopicTree[t],
+			Articles:    s.Topics[t],
+			Descendants: len(s.TopicTree.Descendants(t)),
+		})
+	}
+
+	// sorting by path lists each topic straight after its parent.
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i].Path, entries[j].Path
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return strings.ToLower(string(a[k])) < strings.ToLower(string(b[k]))
+			}
+		}
+		return len(a) < len(b)
+	})
+	return entries
+}
+
+// vocabulary returns the approved topics that articles can be tagged with.
+func (s *Server) vocabulary() db.Vocabulary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return db.NewVocabulary(s.Topics, s.TopicTree)
+}
+
+// suggestTags writes, as JSON, the topics and past year questions matching the tag being typed in the q parameter, for the tags field of the add and edit article forms.
+func suggestTags(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	v := s.vocabulary()
+	s.mu.Lock()
+	suggestions := db.Suggest(r.FormValue("q"), v, s.Questions, 8)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(suggestions); err != nil {
+		log.Printf("Unable to write tag suggestions - %v", err)
+	}
+}
+
+// topicTreeReady reports whether the topic hierarchy has been read from the Topics sheet, reading it again if it could not be read when the app started.
+func (s *Server) topicTreeReady() bool {
+	s.mu.Lock()
+	loaded := s.topicTreeLoaded
+	s.mu.Unlock()
+	if loaded {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(s.Ctx, sheetsTimeout)
+	defer cancel()
+	tt, err := db.InitTopicTree(ctx)
+	if err != nil {
+		log.Printf("Unable to load the topic hierarchy from the Topics sheet - %v", err)
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.topicTreeLoaded {
+		s.TopicTree, s.topicTreeLoaded = tt, true
+	}
+	return true
+}
+
+// topics lets an admin rename or merge topics across every article, and arrange topics in a hierarchy so that searching a topic includes its subtopics.
+func topics(w http.ResponseWriter, r *http.Request) {
+	if !checkCookie(w, r) {
+		http.Redirect(w, r, "/admin", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == "POST" {
+		from := db.Topic(strings.TrimSpace(r.FormValue("topic")))
+
+		// topics can still be renamed across the articles without the hierarchy, but it cannot be changed until it has been read.
+		ready := s.topicTreeReady()
+		if !ready && r.FormValue("action") != "rename" {
+			msg := customError{ErrMsg: "Unable to load the topic hierarchy from the Topics sheet.", HelpMsg: "Check that the spreadsheet has a Topics sheet, and try again in a few minutes."}
+			http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+			return
+		}
+
+		switch r.FormValue("action") {
+		case "add":
+			topic := db.Topic(strings.Title(strings.TrimSpace(r.FormValue("name"))))
+			if topic == "" {
+				msg := customError{ErrMsg: "Empty field(s) on form.", HelpMsg: "Enter the name of the new topic."}
+				http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+				return
+			}
+
+			s.mu.Lock()
+			if _, ok := s.TopicTree[topic]; !ok {
+				s.TopicTree.SetParent(topic, db.Topic(strings.TrimSpace(r.FormValue("parent"))))
+			}
+			s.mu.Unlock()
+		case "rename":
+			to := db.Topic(strings.Title(strings.TrimSpace(r.FormValue("to"))))
+			if from == "" || to == "" {
+				msg := customError{ErrMsg: "Empty field(s) on form.", HelpMsg: "Choose a topic, and enter the new name or the topic to merge it into."}
+				http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+				return
+			}
+
+			s.takeSnapshot(db.SnapshotBeforeRename)
+			s.mu.Lock()
+			db.RenameTopic(s.Articles, s.Topics, from, to)
+			if ready {
+				s.TopicTree.Rename(from, to)
+			}
+			s.mu.Unlock()
+
+			backup(w, r)
+		case "parent":
+			parent := db.Topic(strings.TrimSpace(r.FormValue("parent")))
+
+			s.mu.Lock()
+			err := s.TopicTree.SetParent(from, parent)
+			s.mu.Unlock()
+			if err != nil {
+				msg := customError{ErrMsg: fmt.Sprintf("Unable to move the topic - %v.", err), HelpMsg: "Choose a parent topic that is not the topic itself or one of its subtopics."}
+				http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+				return
+			}
+		}
+
+		if ready {
+			s.mu.Lock()
+			queueSheetWrites(db.BackupTopicsWrite(s.TopicTree))
+			s.mu.Unlock()
+		}
+		http.Redirect(w, r, "/topics", http.StatusSeeOther)
+		return
+	}
+
+	s.mu.Lock()
+	data := s.topicEntries()
+	s.mu.Unlock()
+
+	err := tpl.ExecuteTemplate(w, "topics.html", data)
+	if err != nil {
+		msg := customError{
+			ErrMsg:  fmt.Sprintf("%v", err),
+			HelpMsg: "",
+		}
+		http.Redirect(w, r, "/error?"+fmt.Sprintf("%v=%v&%v=%v", "ErrMsg", msg.ErrMsg, "HelpMsg", msg.HelpMsg), http.StatusSeeOther)
+		return
+	}
+}
@@ -0,0 +1,94 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jwnpoh/njcgpnewsfeed/db"
+)
+
+func TestTopicsWaitForHierarchy(t *testing.T) {
+	cfg := db.DefaultConfig()
+	cfg.SheetID = "sheet"
+	db.Configure(cfg)
+	fake := db.NewFakeSheets()
+	db.UseSheetsClient(fake)
+	t.Cleanup(func() {
+		db.UseSheetsClient(nil)
+		db.Configure(db.DefaultConfig())
+	})
+
+	useTestArticles(t, nil, cfg)
+	useTestOutbox(t)
+	oldCtx, oldTree := s.Ctx, s.TopicTree
+	s.Ctx, s.TopicTree, s.topicTreeLoaded = context.Background(), make(db.TopicTree), false
+	t.Cleanup(func() { s.Ctx, s.TopicTree, s.topicTreeLoaded = oldCtx, oldTree, false })
+	session := loginAs(t, "Mary")
+
+	addTopic := func() string {
+		form := url.Values{"action": {"add"}, "name": {"AI"}, "parent": {"Technology"}}
+		r := httptest.NewRequest("POST", "/topics", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(&http.Cookie{Name: session.Name, Value: session.Value})
+		w := httptest.NewRecorder()
+		topics(w, r)
+		return w.Header().Get("Location")
+	}
+
+	// the spreadsheet cannot be read, as when Google Sheets is down.
+	if got := addTopic(); !strings.HasPrefix(got, "/error") {
+		t.Errorf("got redirected to %q adding a topic before the hierarchy was read, want the error page", got)
+	}
+	if n := len(s.Outbox.Pending()); n != 0 {
+		t.Fatalf("got %d writes queued before the hierarchy was read, want none", n)
+	}
+
+	fake.SetValues("sheet", "Topics", [][]interface{}{{"Technology", "Science"}})
+	if got := addTopic(); got != "/topics" {
+		t.Errorf("got redirected to %q adding a topic once the Topics sheet could be read, want the topic manager", got)
+	}
+	pending := s.Outbox.Pending()
+	if len(pending) != 1 || len(pending[0].Values) != 3 {
+		t.Errorf("got writes %+v, want the Topics sheet backed up with the topics it had and the new one", pending)
+	}
+}
+
+func TestRestoreBeforeRename(t *testing.T) {
+	fake := useTestSheets(t, testArticleRows, db.DefaultConfig())
+	fake.SetValues("sheet", "Topics", [][]interface{}{{"Economy", ""}, {"Environment", "Economy"}})
+	oldTree := s.TopicTree
+	s.TopicTree, s.topicTreeLoaded = make(db.TopicTree), false
+	t.Cleanup(func() { s.TopicTree, s.topicTreeLoaded = oldTree, false })
+	session := loginAs(t, "Mary")
+
+	post(topics, "/topics", url.Values{"action": {"rename"}, "topic": {"Economy"}, "to": {"Finance"}}, session)
+	if s.Topics["Finance"] != 2 || s.TopicTree["Environment"] != "Finance" {
+		t.Fatalf("got topics %v and hierarchy %v, want Economy renamed", s.Topics, s.TopicTree)
+	}
+
+	list, err := s.Snapshots.List()
+	if err != nil || len(list) != 1 || list[0].Reason != db.SnapshotBeforeRename {
+		t.Fatalf("got snapshots %+v (%v), want the one taken before the rename", list, err)
+	}
+	sn, err := s.Snapshots.Open(list[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.restoreSnapshot(sn); err != nil {
+		t.Fatal(err)
+	}
+	if s.Topics["Economy"] != 2 || s.Topics["Finance"] != 0 {
+		t.Errorf("got topics %v, want the articles tagged with Economy again", s.Topics)
+	}
+	if _, ok := s.TopicTree["Finance"]; ok || s.TopicTree["Environment"] != "Economy" {
+		t.Errorf("got hierarchy %v, want Economy back in it", s.TopicTree)
+	}
+	pending := s.Outbox.Pending()
+	if last := pending[len(pending)-1]; last.Range != "Topics" || len(last.Values) != 2 || last.Values[0][0] != "Economy" {
+		t.Errorf("got last write %+v, want the Topics sheet restored", last)
+	}
+}